@@ -2,36 +2,51 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"go-rpc/encoding"
 	"go-rpc/interfaces"
 	"go-rpc/internal/pkg/client"
 	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/tlsconfig"
+	"go-rpc/internal/pkg/trace"
 	"go-rpc/types"
 	"log"
 	"net"
-	"net/rpc"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var isConnected bool = false
 
 var nsClient *naming.NameServerClient
-var rpcClient *rpc.Client
+var resolver *client.Resolver                // resolve as referências às sub-peças
 var currentRepo *client.PartRepositoryClient // repositório corrente
 var currentPart interfaces.Part              // peça corrente
 var currentSubcomponents []interfaces.Pair   // lista de sub-peças corrente
+var callTimeout time.Duration                // tempo máximo de espera de cada chamada remota
+var pageSize int                             // número de peças exibidas por página em listp
+
+// withTimeout retorna um contexto limitado pelo tempo máximo de espera das chamadas remotas
+// e a função que libera os seus recursos.
+func withTimeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), callTimeout)
+}
 
 // addsupart adiciona à lista de sub-peças n unidades da peça corrente.
 // Recebe como parâmetro um objeto que implementa a interface interfaces.Part e
 // um inteiro que representa a quantidade de unidades desse objeto.
+// A sub-peça é armazenada como uma referência ao repositório que a contém, e não como uma cópia.
 func addsubpart(subPart interfaces.Part, n int) {
 	// Checa se subPart já está na lista
 	for i := 0; i < len(currentSubcomponents); i++ {
-		curr := currentSubcomponents[i].GetPart()
-		if (curr).GetCode() == (subPart).GetCode() {
+		curr := currentSubcomponents[i]
+		if curr.GetPartCode() == subPart.GetCode() && curr.GetRepositoryName() == subPart.GetRepositoryName() {
 			// Apenas altera a quantidade do componente na lista
 			currentSubcomponents[i].SetQuantity(currentSubcomponents[i].GetQuantity() +
 				n)
@@ -40,31 +55,263 @@ func addsubpart(subPart interfaces.Part, n int) {
 	}
 
 	// Adiciona o novo par na lista de subcomponentes corrent
-	currentSubcomponents = append(currentSubcomponents, types.NewPairImpl(subPart, n))
+	currentSubcomponents = append(currentSubcomponents, types.NewPairRefImpl(subPart.GetRepositoryName(), subPart.GetCode(), n))
 }
 
-// listp lista as peças do repositório corrente
-func listp() {
-	parts := currentRepo.GetParts()
+// listp lista as peças do repositório corrente, uma página por vez, perguntando ao final de cada
+// página se a próxima deve ser exibida.
+func listp(scanner *bufio.Scanner) {
+	token := ""
+	for page := 1; ; page++ {
+		// Cada página tem o seu próprio prazo, já que o usuário pode demorar a pedir a próxima
+		ctx, cancel := withTimeout()
+		parts, next, err := currentRepo.ListPartsContext(ctx, pageSize, token)
+		cancel()
+		if err != nil {
+			fmt.Printf("[!] Não foi possível listar as peças: %v", err)
+			return
+		}
 
-	if len(parts) == 0 {
-		fmt.Printf("[!] Lista de peças vazia")
+		if page == 1 && len(parts) == 0 {
+			fmt.Printf("[!] Lista de peças vazia")
+			return
+		}
+		fmt.Printf("[!] Peças do repositório corrente {%s}, página %d:\n", currentRepo.GetRepositoryName(), page)
+
+		for i := 0; i < len(parts); i++ {
+			fmt.Printf("\t%v", parts[i])
+			if i < len(parts)-1 {
+				fmt.Println()
+			}
+		}
+
+		if next == "" {
+			return
+		}
+		fmt.Printf("\n[!] Mostrar a próxima página? (s/n): ")
+		if readline(scanner) != "s" {
+			return
+		}
+		token = next
+	}
+}
+
+// showp mostra a peça corrente e os seus subcomponentes, resolvendo as referências às sub-peças
+// nos repositórios que as contêm.
+func showp() {
+	fmt.Printf("[!] Peça corrente: %s", currentPart)
+
+	ctx, cancel := withTimeout()
+	defer cancel()
+	for _, pair := range currentPart.GetSubcomponents() {
+		part, err := resolver.ResolveContext(ctx, pair)
+		if err != nil {
+			fmt.Printf("\n\t%d x %s/%s: %v", pair.GetQuantity(), pair.GetRepositoryName(), pair.GetPartCode(), err)
+			continue
+		}
+		fmt.Printf("\n\t%d x %v", pair.GetQuantity(), part)
+	}
+}
+
+// bom mostra a explosão da lista de materiais da peça com o código informado, ou da peça corrente
+// caso o código seja vazio, e o resumo das suas peças primitivas.
+func bom(code string) {
+	if code == "" {
+		if currentPart == nil {
+			fmt.Printf("[!] Peça corrente ainda não foi definida.")
+			return
+		}
+		code = currentPart.GetCode()
+	}
+
+	ctx, cancel := withTimeout()
+	defer cancel()
+	b, err := currentRepo.ExplodeBOMContext(ctx, code)
+	if err != nil {
+		fmt.Printf("[!] Não foi possível explodir a lista de materiais: %v", err)
+		return
+	}
+	fmt.Printf("[!] Lista de materiais de %s:\n%s", b.Root.GetName(), b.Report())
+	fmt.Printf("[!] Peças primitivas (%d):\n%s", len(b.Primitives), strings.TrimSuffix(b.Summary(), "\n"))
+}
+
+// wused mostra as peças de todos os repositórios registrados que utilizam a peça com o código
+// informado, ou a peça corrente caso o código seja vazio. Caso recursive seja verdadeiro,
+// mostra também as peças que a utilizam indiretamente.
+func wused(code string, recursive bool) {
+	if code == "" {
+		if currentPart == nil {
+			fmt.Printf("[!] Peça corrente ainda não foi definida.")
+			return
+		}
+		code = currentPart.GetCode()
+	}
+
+	ctx, cancel := withTimeout()
+	defer cancel()
+	report, err := resolver.WhereUsedContext(ctx, code, recursive)
+	if err != nil {
+		fmt.Printf("[!] Não foi possível consultar os repositórios: %v", err)
+		return
+	}
+	for name, err := range report.Failures {
+		fmt.Printf("[!] Repositório %s não consultado: %v\n", name, err)
+	}
+	if len(report.Usages) == 0 {
+		fmt.Printf("[!] Peça %s não é utilizada por nenhuma peça", code)
+		return
+	}
+
+	fmt.Printf("[!] Peças que utilizam %s (%d):", code, len(report.Usages))
+	for _, usage := range report.Usages {
+		fmt.Printf("\n\t[nível %d] %v", usage.Level, usage.Part)
+	}
+}
+
+// findp busca peças no repositório corrente pelo nome. Caso o nome termine com "*",
+// a busca é feita pelo prefixo que o antecede.
+func findp(name string) {
+	ctx, cancel := withTimeout()
+	defer cancel()
+
+	var parts []interfaces.Part
+	var err error
+	if strings.HasSuffix(name, "*") {
+		parts, err = currentRepo.FindPartsByNamePrefixContext(ctx, strings.TrimSuffix(name, "*"))
 	} else {
-		fmt.Printf("[!] Peças do repositório corrente {%s}:\n", currentRepo.GetRepositoryName())
+		parts, err = currentRepo.FindPartsByNameContext(ctx, name)
 	}
+	if err != nil {
+		fmt.Printf("[!] Não foi possível buscar as peças: %v", err)
+		return
+	}
+	printParts(fmt.Sprintf("Peças com nome %s", name), parts)
+}
+
+// listkind lista as peças primitivas ou as peças agregadas do repositório corrente.
+func listkind(primitive bool) {
+	ctx, cancel := withTimeout()
+	defer cancel()
+
+	var parts []interfaces.Part
+	var err error
+	title := "Peças agregadas"
+	if primitive {
+		title = "Peças primitivas"
+		parts, err = currentRepo.GetPrimitivePartsContext(ctx)
+	} else {
+		parts, err = currentRepo.GetAggregatePartsContext(ctx)
+	}
+	if err != nil {
+		fmt.Printf("[!] Não foi possível listar as peças: %v", err)
+		return
+	}
+	printParts(title, parts)
+}
 
+// printParts imprime uma lista de peças precedida de um título.
+func printParts(title string, parts []interfaces.Part) {
+	if len(parts) == 0 {
+		fmt.Printf("[!] Nenhuma peça encontrada")
+		return
+	}
+	fmt.Printf("[!] %s (%d):\n", title, len(parts))
 	for i := 0; i < len(parts); i++ {
 		fmt.Printf("\t%v", parts[i])
 		if i < len(parts)-1 {
 			fmt.Println()
 		}
 	}
+}
+
+// updatep altera o nome e a descrição da peça corrente no repositório corrente.
+// Campos deixados em branco mantêm o valor atual. Caso useSubcomponents seja verdadeiro,
+// a lista de sub-peças corrente substitui os subcomponentes da peça.
+func updatep(name string, description string, useSubcomponents bool) {
+	if name == "" {
+		name = currentPart.GetName()
+	}
+	if description == "" {
+		description = currentPart.GetDescription()
+	}
+
+	updated := types.NewPartImpl(name, description)
+	updated.SetCode(currentPart.GetCode())
+	if useSubcomponents {
+		updated.SetSubcomponents(currentSubcomponents)
+	} else {
+		updated.SetSubcomponents(currentPart.GetSubcomponents())
+	}
+
+	ctx, cancel := withTimeout()
+	defer cancel()
+	part, err := currentRepo.UpdatePartContext(ctx, updated)
+	if err != nil {
+		fmt.Printf("[!] Não foi possível alterar a peça: %v", err)
+		return
+	}
+	currentPart = part
+	fmt.Printf("[!] Peça alterada com sucesso: %v", currentPart)
+}
+
+// delp remove uma peça do repositório corrente a partir do seu código.
+// Caso a peça seja sub-peça de outras peças do repositório, pergunta se a remoção
+// deve ser feita em cascata.
+func delp(scanner *bufio.Scanner, code string) {
+	ctx, cancel := withTimeout()
+	defer cancel()
+	deleted, err := currentRepo.DeletePartContext(ctx, code, false)
+	if errors.Is(err, types.ErrPartInUse) {
+		fmt.Printf("[!] %v\n", err)
+		fmt.Printf("[!] Remover também as peças que a utilizam? (s/n): ")
+		if readline(scanner) != "s" {
+			fmt.Printf("[!] Remoção cancelada.")
+			return
+		}
+		// O prazo da primeira chamada pode ter vencido enquanto o usuário respondia
+		ctx, cancel := withTimeout()
+		defer cancel()
+		deleted, err = currentRepo.DeletePartContext(ctx, code, true)
+	}
+	if err != nil {
+		fmt.Printf("[!] Não foi possível remover a peça: %v", err)
+		return
+	}
 
+	for _, c := range deleted {
+		if currentPart != nil && currentPart.GetCode() == c {
+			currentPart = nil
+		}
+	}
+	fmt.Printf("[!] Peças removidas (%d): %v", len(deleted), deleted)
+}
+
+// lsrepo lista os repositórios de peças registrados no serviço de nomes.
+func lsrepo() {
+	ctx, cancel := withTimeout()
+	defer cancel()
+	refs, err := nsClient.ListContext(ctx)
+	if err != nil {
+		fmt.Printf("[!] Não foi possível listar os repositórios: %v\n", err)
+		return
+	}
+	if len(refs) == 0 {
+		fmt.Printf("[!] Nenhum repositório registrado\n")
+		return
+	}
+
+	fmt.Printf("[!] Repositórios registrados (%d):\n", len(refs))
+	for _, ref := range refs {
+		fmt.Printf("\t%s{%s} registrado em %s, expira em %s\n", ref.GetName(), ref.GetAddress(),
+			ref.GetMetadata()[naming.META_REGISTERED_AT], ref.GetMetadata()[naming.META_EXPIRES_AT])
+	}
 }
 
 // bind tenta resolver através do cliente de serviço de nomes o servidor de repositório
 // de peças a partir de um nome e retorna o ponteiro para uma estrutura client.PartRepositoryClient
 // que fornece a API para interagir com o servidor remoto.
+// O cliente devolvido se reconecta ao servidor, ou a outra instância registrada com o mesmo nome,
+// caso a conexão seja perdida.
 // Recebe como parâmetro o nome do servidor para se conectar.
 func bind(serverName string) *client.PartRepositoryClient {
 	// Caso o cliente com o nameserver seja nulo, retorne nulo
@@ -72,35 +319,32 @@ func bind(serverName string) *client.PartRepositoryClient {
 		return nil
 	}
 
-	// Tenta fazer o lookup através do cliente do serviço de nomes
-	// usando o nome de servidor passado como parâmetro
-	address, err := nsClient.Lookup(serverName)
-
-	// Faz o logging de erro se for o caso
-	if err != nil {
+	// Tenta resolver o nome através do cliente do serviço de nomes e se conectar
+	// ao servidor rpc, reportando erros caso ocorram
+	ctx, cancel := withTimeout()
+	defer cancel()
+	repo, err := client.DialContext(ctx, nsClient, serverName)
+	if errors.Is(err, rpcerror.ErrNotFound) {
 		fmt.Printf("[!] Oops, servidor com nome %s não encontrado\n", serverName)
 		return nil
 	}
-
-	// Tenta se conectar ao endereço do servidor rpc e reporta erros
-	// caso ocorram
-	conn, err := net.Dial("tcp", address)
+	if errors.Is(err, rpcerror.ErrPermissionDenied) {
+		fmt.Printf("[!] Acesso negado ao servidor %s, verifique o token informado em -token: %v\n", serverName, err)
+		return nil
+	}
 	if err != nil {
-		log.Fatal("Connection error:", err)
+		fmt.Printf("[!] Não foi possível conectar ao servidor %s: %v\n", serverName, err)
+		return nil
 	}
 
+	// Encerra a conexão com o repositório anterior, caso exista
+	if currentRepo != nil {
+		currentRepo.Close()
+	}
 	isConnected = true
 
-	// inicializa um novo cliente rpc
-	rpcClient = rpc.NewClient(conn)
-
-	// Separa o endereço em host e port para inicializar um objeto RemoteRef
-	host, port, _ := net.SplitHostPort(address)
-
-	fmt.Printf("[!] Conectado com sucesso ao servidor %s{%s}", serverName, address)
-	// Retorna nova estrutura client.PartRepositoryClient construída a partir do cliente RPC
-	return client.NewPartRepositoryClient(rpcClient,
-		types.NewRemoteRefImpl(host, port, serverName))
+	fmt.Printf("[!] Conectado com sucesso ao servidor %s{%s}", serverName, repo.GetRef().GetAddress())
+	return repo
 }
 
 // quit encerra a execução do cliente e finaliza a aplicação
@@ -124,8 +368,14 @@ func main() {
 
 	// Define a flag nameserver do executável
 	// Caso ela seja omitida, seu valor-padrão é 127.0.0.1:8000.
-	var nameserver string
+	var nameserver, token, traceFile string
+	var tlsOptions tlsconfig.Options
 	flag.StringVar(&nameserver, "ns", "127.0.0.1:8000", "nameserver address to key resolution")
+	flag.DurationVar(&callTimeout, "timeout", 10*time.Second, "maximum time to wait for each remote call")
+	flag.IntVar(&pageSize, "page-size", 20, "number of parts shown per page by listp")
+	flag.StringVar(&token, "token", "", "API token presented to the nameserver and repositories")
+	flag.StringVar(&traceFile, "trace-file", "", "file to append finished trace spans to, as JSON lines (disabled if empty)")
+	tlsOptions.RegisterFlags(flag.CommandLine)
 
 	// Faz o parsing das flags
 	flag.Parse()
@@ -150,10 +400,33 @@ func main() {
 	// Registra tipos para correta codificação/decodificação
 	encoding.RegisterConcreteTypes()
 
+	// Identifica as etapas deste processo e as exporta ao arquivo informado, caso haja um
+	trace.SetServiceName("client")
+	if traceFile != "" {
+		exporter, err := trace.NewFileExporter(traceFile)
+		if err != nil {
+			log.Fatalln("Fatal error", err)
+		}
+		trace.SetExporter(exporter)
+	}
+
 	// Inicializa o cliente do serviço de nomes
 	nsClient = naming.NewNameServerClient(host, port)
 
+	// Utiliza TLS nas conexões com o serviço de nomes e com os repositórios, caso tenha sido configurado
+	clientTLS, err := tlsOptions.Client()
+	if err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+	if clientTLS != nil {
+		nsClient.SetTLSConfig(clientTLS)
+	}
+	// O token é enviado ao serviço de nomes e aos repositórios conectados através dele
+	nsClient.SetToken(token)
+	resolver = client.NewResolver(nsClient)
+
 	var repoName string
+	lsrepo()
 	for !isConnected {
 		fmt.Printf("[!] Digite o nome do repositório para se conectar: ")
 		fmt.Scanln(&repoName)
@@ -161,7 +434,7 @@ func main() {
 	}
 
 	var command string
-	fmt.Printf("\n[!] Comandos disponíveis: (bind|listp|getp|showp|clearlist|addsubpart|addp|quit)")
+	fmt.Printf("\n[!] Comandos disponíveis: (lsrepo|bind|listp|listprim|listagg|findp|getp|showp|bom|wused|clearlist|addsubpart|addp|updatep|delp|quit)")
 
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -169,7 +442,10 @@ func main() {
 		fmt.Printf("\n > ")
 		command = readline(scanner)
 		switch command {
+		case "lsrepo":
+			lsrepo()
 		case "bind":
+			lsrepo()
 			fmt.Printf("[!] Digite o nome do repositório para se conectar: ")
 			repoName = readline(scanner)
 			currentRepo = bind(repoName)
@@ -179,22 +455,41 @@ func main() {
 				currentRepo = bind(repoName)
 			}
 		case "listp":
-			listp()
+			listp(scanner)
+		case "listprim":
+			listkind(true)
+		case "listagg":
+			listkind(false)
+		case "findp":
+			fmt.Printf("[!] Digite o nome da peça (termine com * para buscar por prefixo): ")
+			findp(readline(scanner))
 		case "getp":
 			fmt.Printf("[!] Digite o código da peça para busca: ")
 			partCode := readline(scanner)
-			part := currentRepo.GetPart(partCode)
-			if part == nil {
+			ctx, cancel := withTimeout()
+			part, err := currentRepo.GetPartContext(ctx, partCode)
+			cancel()
+			if errors.Is(err, rpcerror.ErrNotFound) {
 				fmt.Printf("[!] Peça com código %s não encontrada.", partCode)
+			} else if err != nil {
+				fmt.Printf("[!] Não foi possível buscar a peça: %v", err)
 			} else {
 				currentPart = part
 				fmt.Printf("[!] Peça corrente definida como %v.", currentPart)
 			}
+		case "bom":
+			fmt.Printf("[!] Digite o código da peça (vazio para a peça corrente): ")
+			bom(readline(scanner))
+		case "wused":
+			fmt.Printf("[!] Digite o código da peça (vazio para a peça corrente): ")
+			code := readline(scanner)
+			fmt.Printf("[!] Incluir os usos indiretos? (s/n): ")
+			wused(code, readline(scanner) == "s")
 		case "showp":
 			if currentPart == nil {
 				fmt.Printf("[!] Peça corrente não foi definida.")
 			} else {
-				fmt.Printf("[!] Peça corrente: %s", currentPart)
+				showp()
 			}
 		case "clearlist":
 			// Note que em Go, atribuir o valor nil a um slice é equivalente a esvaziá-lo.
@@ -221,8 +516,28 @@ func main() {
 			description := readline(scanner)
 			newPart := types.NewPartImpl(name, description)
 			newPart.SetSubcomponents(currentSubcomponents)
-			p := currentRepo.AddPart(newPart)
-			fmt.Printf("[!] Peça adicionada com sucesso (código=%s)", p.GetCode())
+			ctx, cancel := withTimeout()
+			p, err := currentRepo.AddPartContext(ctx, newPart)
+			cancel()
+			if err != nil {
+				fmt.Printf("[!] Não foi possível adicionar a peça: %v", err)
+			} else {
+				fmt.Printf("[!] Peça adicionada com sucesso (código=%s)", p.GetCode())
+			}
+		case "updatep":
+			if currentPart == nil {
+				fmt.Printf("[!] Peça corrente ainda não foi definida.")
+			} else {
+				fmt.Printf("[!] Digite o novo nome da peça (vazio para manter): ")
+				name := readline(scanner)
+				fmt.Printf("[!] Digite a nova descrição da peça (vazio para manter): ")
+				description := readline(scanner)
+				fmt.Printf("[!] Substituir subcomponentes pela lista de sub-peças corrente? (s/n): ")
+				updatep(name, description, readline(scanner) == "s")
+			}
+		case "delp":
+			fmt.Printf("[!] Digite o código da peça a ser removida: ")
+			delp(scanner, readline(scanner))
 		case "quit":
 			quit()
 		default:
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"go-rpc/internal/pkg/tlsconfig"
+	"log"
+	"strings"
+	"time"
+)
+
+func main() {
+	// Define as flags do executável
+	// Caso elas sejam omitidas, os certificados são gravados em ./certs, valem por 30 dias para
+	// 127.0.0.1, ::1 e localhost, e são gerados para o serviço de nomes, o servidor e o cliente.
+	var dir, hosts, names string
+	var validity time.Duration
+	flag.StringVar(&dir, "out", "certs", "directory to write the certificates to")
+	flag.StringVar(&hosts, "hosts", "127.0.0.1,::1,localhost", "comma-separated hosts (IP addresses or DNS names) the certificates are valid for")
+	flag.StringVar(&names, "names", "naming,service,client", "comma-separated names of the certificates to issue")
+	flag.DurationVar(&validity, "validity", 30*24*time.Hour, "validity period of the certificates")
+
+	// Faz o parsing
+	flag.Parse()
+
+	// Gera a CA de desenvolvimento e os certificados emitidos por ela
+	opts, err := tlsconfig.GenerateDevCerts(dir, strings.Split(hosts, ","), strings.Split(names, ","), validity)
+	if err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+
+	log.Printf("[!] Development CA written to %s (for local testing only)", opts[0].CAFile)
+	for _, o := range opts {
+		log.Printf("[!] -tls-cert %s -tls-key %s -tls-ca %s", o.CertFile, o.KeyFile, o.CAFile)
+	}
+}
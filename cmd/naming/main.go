@@ -1,22 +1,86 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/metrics"
 	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/tlsconfig"
+	"go-rpc/internal/pkg/trace"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	// Define as flags do executável
 	// Caso elas sejam omitidas, seus valores-padrão são 127.0.0.1 (localhost)
 	// e 8000, respectivamente.
-	var host, port string
+	var host, port, tokensFile, metricsAddr, traceFile string
+	var ttl time.Duration
+	var tlsOptions tlsconfig.Options
 	flag.StringVar(&host, "host", "127.0.0.1", "host to bind to")
 	flag.StringVar(&port, "port", "8000", "port to bind to")
+	flag.DurationVar(&ttl, "ttl", naming.DefaultLeaseTTL, "default lease duration of registrations")
+	flag.StringVar(&metricsAddr, "metrics", "", "address to serve Prometheus metrics on, e.g. 127.0.0.1:9100 (disabled if empty)")
+	flag.StringVar(&traceFile, "trace-file", "", "file to append finished trace spans to, as JSON lines (disabled if empty)")
+	flag.StringVar(&tokensFile, "tokens", "", "file of API tokens and their roles (authentication disabled if empty)")
+	tlsOptions.RegisterFlags(flag.CommandLine)
 
 	// Faz o parsing
 	flag.Parse()
 
-	// Inicializa serviço de nomes no host e port designados
-	nameServer := new(naming.NameServer)
-	nameServer.Init(host, port)
+	// Identifica as etapas deste processo e as exporta ao arquivo informado, caso haja um
+	trace.SetServiceName("nameserver")
+	if traceFile != "" {
+		exporter, err := trace.NewFileExporter(traceFile)
+		if err != nil {
+			log.Fatalln("Fatal error", err)
+		}
+		trace.SetExporter(exporter)
+	}
+
+	// Carrega a configuração TLS, caso tenha sido informada
+	serverTLS, err := tlsOptions.Server()
+	if err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+
+	// Cria o serviço de nomes no host e port designados
+	nameServer := naming.NewNameServer(host, port)
+	nameServer.SetLeaseTTL(ttl)
+	nameServer.SetTLSConfig(serverTLS)
+
+	// Coleta as métricas do serviço de nomes, caso um endereço tenha sido informado
+	if metricsAddr != "" {
+		registry := metrics.NewRegistry()
+		nameServer.SetMetrics(registry)
+		go func() {
+			log.Println("[!] Metrics server stopped:", metrics.ListenAndServe(metricsAddr, registry))
+		}()
+		log.Printf("[!] Metrics available at http://%s/metrics", metricsAddr)
+	}
+
+	// Exige tokens de API nas requisições, caso um arquivo de tokens tenha sido informado
+	if tokensFile != "" {
+		authorizer, err := auth.LoadAuthorizer(tokensFile)
+		if err != nil {
+			log.Fatalln("Fatal error", err)
+		}
+		nameServer.SetAuthorizer(authorizer)
+	}
+
+	// Inicializa o serviço de nomes, que é encerrado ao receber um sinal de encerramento (Ctrl+C ou SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := nameServer.Start(ctx); err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+	if err := nameServer.Wait(); err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+	log.Println("[!] Nameserver stopped")
 }
@@ -1,26 +1,53 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"go-rpc/encoding"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/metrics"
 	"go-rpc/internal/pkg/naming"
 	s "go-rpc/internal/pkg/server"
+	"go-rpc/internal/pkg/service"
+	"go-rpc/internal/pkg/storage"
+	"go-rpc/internal/pkg/tlsconfig"
+	"go-rpc/internal/pkg/trace"
 	"go-rpc/types"
 	"log"
 	"net"
-	"net/rpc"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	// Define as flags host, port, name e nameserver do executável
 	// Caso elas sejam omitidas, seus valores-padrão são 127.0.0.1 (localhost),
 	// 8001, loremipsum e 127.0.0.1:8000, respectivamente.
-	var host, port, name, nameserver string
+	var host, port, jsonPort, restPort, name, nameserver, dataDir, tokensFile, apiToken, replicaKey, metricsAddr, traceFile string
+	var snapshotEvery, maxDepth int
+	var ttl, shutdownTimeout time.Duration
+	var tlsOptions tlsconfig.Options
 
 	flag.StringVar(&host, "host", "127.0.0.1", "host to bind to")
 	flag.StringVar(&port, "port", "8001", "port to bind to")
+	flag.StringVar(&jsonPort, "jsonport", "", "port to serve JSON-RPC on (disabled if empty)")
+	flag.StringVar(&restPort, "restport", "", "port to serve the REST API on (disabled if empty)")
 	flag.StringVar(&name, "name", "loremipsum", "name to register")
 	flag.StringVar(&nameserver, "ns", "127.0.0.1:8000", "nameserver address to register part repository server")
+	flag.StringVar(&dataDir, "data-dir", "", "directory to persist parts (in-memory only if empty)")
+	flag.DurationVar(&ttl, "ttl", naming.DefaultLeaseTTL, "lease duration of the nameserver registration")
+	flag.IntVar(&snapshotEvery, "snapshot-every", storage.DefaultSnapshotEvery, "number of log records between snapshots")
+	flag.IntVar(&maxDepth, "max-depth", s.DefaultMaxDepth, "maximum nesting depth of part compositions")
+	flag.StringVar(&tokensFile, "tokens", "", "file of API tokens accepted by this server and their roles (authentication disabled if empty)")
+	flag.StringVar(&apiToken, "token", "", "API token presented to the nameserver and other repositories")
+	flag.StringVar(&replicaKey, "replica-key", "", "shared key that lets several instances register the same name (name is exclusive if empty)")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", service.DefaultShutdownTimeout, "maximum time to wait for in-flight calls on shutdown")
+	flag.StringVar(&traceFile, "trace-file", "", "file to append finished trace spans to, as JSON lines (disabled if empty)")
+	flag.StringVar(&metricsAddr, "metrics", "", "address to serve Prometheus metrics on, e.g. 127.0.0.1:9101 (disabled if empty)")
+	tlsOptions.RegisterFlags(flag.CommandLine)
 
 	// Faz o parsing das flags
 	flag.Parse()
@@ -32,21 +59,56 @@ func main() {
 		log.Fatalln("invalid addr", host)
 	}
 
+	// Identifica as etapas deste processo e as exporta ao arquivo informado, caso haja um
+	trace.SetServiceName(name)
+	var exporter *trace.FileExporter
+	if traceFile != "" {
+		var err error
+		exporter, err = trace.NewFileExporter(traceFile)
+		if err != nil {
+			log.Fatalln("Fatal error", err)
+		}
+		trace.SetExporter(exporter)
+	}
+
+	// Carrega a configuração TLS, caso tenha sido informada. O certificado do servidor é apresentado
+	// tanto aos seus clientes quanto ao serviço de nomes e aos outros repositórios
+	serverTLS, err := tlsOptions.Server()
+	if err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+	clientTLS, err := tlsOptions.Client()
+	if err != nil {
+		log.Fatalln("Fatal error", err)
+	}
+
 	// Registra tipos para correta codificação/decodificação
 	encoding.RegisterConcreteTypes()
 
-	// Inicializa repositório de peças
-	partRepository := new(types.PartRepositoryImpl)
-
-	// Inicializa servidor de repositório de peças, passando como parâmetro o reposório inicializado previamente
-	partRepositoryServer := s.NewPartRepositoryServer(partRepository)
+	// Inicializa repositório de peças. Caso um diretório de dados tenha sido informado,
+	// utiliza o repositório persistente, recuperando as peças armazenadas previamente.
+	var partRepository interfaces.PartRepository = new(types.PartRepositoryImpl)
+	if dataDir != "" {
+		fileRepository, err := storage.Open(dataDir, snapshotEvery)
+		if err != nil {
+			log.Fatalln("Fatal error", err)
+		}
+		log.Printf("[!] Recovered %d parts from %s", fileRepository.Len(), dataDir)
+		partRepository = fileRepository
+	}
 
-	// Inicializa servidor RPC e registra/expõe o servidor de repositório de peças como um serviço com o nome "PartRepository"
-	server := rpc.NewServer()
-	server.RegisterName("PartRepository", partRepositoryServer)
+	// Coleta as métricas do servidor, caso um endereço tenha sido informado
+	var registry *metrics.Registry
+	if metricsAddr != "" {
+		registry = metrics.NewRegistry()
+		go func() {
+			log.Println("[!] Metrics server stopped:", metrics.ListenAndServe(metricsAddr, registry))
+		}()
+		log.Printf("[!] Metrics available at http://%s/metrics", metricsAddr)
+	}
 
 	// Tenta fazer a resolução do endereço host:port para checar se a porta inserida como flag já está em uso
-	_, err := net.ResolveTCPAddr("tcp", host+":"+port)
+	_, err = net.ResolveTCPAddr("tcp", host+":"+port)
 	if err != nil {
 		// Encerra o programa com a mensagem que a porta já está em uso
 		log.Fatalf("Port already in use")
@@ -63,26 +125,56 @@ func main() {
 
 	// Inicializa cliente do serviço de nomes, para resolução dos repositórios de peças
 	nsclient := naming.NewNameServerClient(nshost, string(nsport))
-
-	// Altera referência do servidor remoto do objeto partRepositoryServer
-	partRepositoryServer.SetRef(types.NewRemoteRefImpl(host, port, name))
-
-	// Começa a escutar por pacotes tcp no endereço especificado
-	listener, err := net.Listen("tcp", host+":"+port)
-	if err != nil {
-		log.Fatal("listen error:", err)
+	if clientTLS != nil {
+		nsclient.SetTLSConfig(clientTLS)
+	}
+	// O registro no serviço de nomes exige um token de escrita, caso ele exija autenticação
+	nsclient.SetToken(apiToken)
+	nsclient.SetReplicaKey(replicaKey)
+
+	// Exige tokens de API nas chamadas, caso um arquivo de tokens tenha sido informado
+	var authorizer *auth.Authorizer
+	if tokensFile != "" {
+		authorizer, err = auth.LoadAuthorizer(tokensFile)
+		if err != nil {
+			log.Fatalln("Fatal error", err)
+		}
 	}
 
-	// Registra o presente servidor no serviço de nomes e checa por erros
-	err = nsclient.Register(host, port, name)
-	if err != nil {
+	// Configura o servidor de repositório de peças (ver service.Server)
+	srv := service.NewServer(service.Config{
+		Host:            host,
+		Port:            port,
+		JSONPort:        jsonPort,
+		RESTPort:        restPort,
+		Name:            name,
+		NameServer:      nsclient,
+		TTL:             ttl,
+		Repository:      partRepository,
+		TLS:             serverTLS,
+		Authorizer:      authorizer,
+		MaxDepth:        maxDepth,
+		Metrics:         registry,
+		ShutdownTimeout: shutdownTimeout,
+	})
+
+	// Inicializa o servidor, que é encerrado gradualmente ao receber um sinal de encerramento
+	// (Ctrl+C ou SIGTERM). Um segundo sinal encerra o processo imediatamente
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Start(ctx); err != nil {
 		log.Fatalln("Fatal error", err)
 	}
 
-	log.Printf("[!] RPC server running on %s", host+":"+port)
-	log.Printf("[!] Successfully registered at nameserver with hostname %s", name)
+	<-ctx.Done()
+	stop()
+	srv.Wait()
 
-	// Liga o servidor rpc ao socket e permite que o servidor rpc aceite
-	// requisições rpc vindo desse socket.
-	server.Accept(listener)
+	// Fecha o arquivo de etapas depois que as últimas chamadas foram encerradas e exportadas
+	if exporter != nil {
+		trace.SetExporter(nil)
+		if err := exporter.Close(); err != nil {
+			log.Println("[!] Failed to close trace file:", err)
+		}
+	}
 }
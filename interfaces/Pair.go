@@ -3,8 +3,15 @@
 package interfaces
 
 // Interface Pair define os comportamentos de um Par (peça, quantidade)
+//
+// A peça do par pode ser armazenada como uma cópia da peça ou como uma referência, formada pelo
+// nome do repositório e pelo código da peça. Uma referência precisa ser resolvida no repositório
+// de origem para obter a peça, mas reflete sempre o seu estado atual.
 type Pair interface {
-	GetPart() Part            // Retorna a peça do par
-	GetQuantity() int         // Retorna a quantidade do par
-	SetQuantity(quantity int) // Altera a propriedade quantidade do par
+	GetPart() Part             // Retorna a peça do par, ou nil caso o par seja uma referência
+	GetPartCode() string       // Retorna o código da peça do par
+	GetRepositoryName() string // Retorna o nome do repositório que contém a peça do par
+	IsReference() bool         // Retorna se o par é uma referência à peça, e não uma cópia
+	GetQuantity() int          // Retorna a quantidade do par
+	SetQuantity(quantity int)  // Altera a propriedade quantidade do par
 }
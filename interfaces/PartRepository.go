@@ -2,7 +2,26 @@ package interfaces
 
 // Interface PartRepository define os comportamentos de um repositório de peças
 type PartRepository interface {
-	AddPart(part Part)        // Adiciona uma Peça ao repositório de peças
+	AddPart(part Part) error  // Adiciona uma Peça ao repositório de peças
 	GetPart(code string) Part // Consulta uma peça pelo código no repositório e a retorna
 	GetParts() []Part         // Retorna a lista de peças do repositório
+	Len() int                 // Retorna o número de peças do repositório
+	// Retorna até limit peças (todas, caso limit não seja positivo) com código maior que after,
+	// em ordem crescente de código, permitindo percorrer o repositório em páginas
+	ListParts(after string, limit int) []Part
+	// Retorna as peças cujo nome é igual ao nome informado
+	FindPartsByName(name string) []Part
+	// Retorna as peças cujo nome começa com o prefixo informado
+	FindPartsByNamePrefix(prefix string) []Part
+	GetPrimitiveParts() []Part // Retorna as peças primitivas do repositório
+	GetAggregateParts() []Part // Retorna as peças agregadas do repositório
+	// Substitui a peça de mesmo código no repositório
+	UpdatePart(part Part) error
+	// Remove uma peça pelo código e retorna os códigos removidos. Caso a peça seja subcomponente
+	// de outras peças do repositório, a remoção é recusada, a menos que cascade seja verdadeiro,
+	// situação em que as peças que a utilizam também são removidas.
+	DeletePart(code string, cascade bool) ([]string, error)
+	// Retorna as peças do repositório que utilizam a peça com o código informado como subcomponente
+	// direto ou, caso recursive seja verdadeiro, direta ou indiretamente
+	WhereUsed(code string, recursive bool) []Part
 }
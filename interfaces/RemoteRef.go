@@ -6,4 +6,6 @@ type RemoteRef interface {
 	GetHost() string    // retorna o host do servidor remoto
 	GetPort() string    // retorna a porta do servidor remoto
 	GetAddress() string // retorna o endereço do servidor remoto no formato host:port
+	// retorna os metadados do servidor remoto (ex.: instante de registro no serviço de nomes)
+	GetMetadata() map[string]string
 }
@@ -0,0 +1,171 @@
+// O pacote auth fornece a autenticação por tokens de API e a autorização por papéis utilizadas
+// pelos servidores de repositório e pelo serviço de nomes.
+//
+// Cada token é associado a um papel, e os papéis são ordenados: um papel concede todas as
+// permissões dos papéis anteriores. Consultas exigem RoleReader, alterações de peças e o registro
+// de servidores no serviço de nomes RoleWriter, e operações que afetam peças de outros usuários,
+// como a remoção de peças em cascata, RoleAdmin.
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrPermissionDenied é devolvido pelos servidores quando o token da chamada não foi informado,
+// não é conhecido ou não possui o papel exigido pela operação.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Tipo Role representa o papel associado a um token.
+type Role int
+
+// Papéis, em ordem crescente de permissões
+const (
+	RoleNone   Role = iota // nenhuma permissão, atribuído aos tokens desconhecidos
+	RoleReader             // consultas
+	RoleWriter             // consultas, alterações de peças e registro de servidores
+	RoleAdmin              // todas as operações, inclusive as remoções em cascata
+)
+
+// Nomes dos papéis no arquivo de tokens
+var roleNames = map[Role]string{
+	RoleNone:   "none",
+	RoleReader: "reader",
+	RoleWriter: "writer",
+	RoleAdmin:  "admin",
+}
+
+// String retorna o nome do papel.
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole retorna o papel com o nome informado (reader, writer ou admin).
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == name && role != RoleNone {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// Estrutura grant associa um token ao seu papel.
+type grant struct {
+	token string // token de API
+	role  Role   // papel do token
+}
+
+// Estrutura Authorizer verifica se o token de uma chamada possui o papel exigido pela operação.
+// Um Authorizer nulo autoriza todas as chamadas, o que corresponde à ausência de autenticação.
+// A estrutura não é alterada após a sua criação e é segura para uso concorrente.
+type Authorizer struct {
+	grants []grant // tokens conhecidos e os seus papéis
+}
+
+// NewAuthorizer retorna o ponteiro para uma estrutura Authorizer que reconhece os tokens
+// informados, associados aos seus papéis.
+func NewAuthorizer(tokens map[string]Role) *Authorizer {
+	a := &Authorizer{}
+	for token, role := range tokens {
+		a.grants = append(a.grants, grant{token: token, role: role})
+	}
+	return a
+}
+
+// LoadAuthorizer retorna o ponteiro para uma estrutura Authorizer com os tokens do arquivo file.
+// Cada linha do arquivo contém um token e o nome do seu papel, separados por espaços; linhas
+// vazias e iniciadas por # são ignoradas:
+//
+//	# token           papel
+//	3f9c1e...         reader
+//	a81d07...         admin
+func LoadAuthorizer(file string) (*Authorizer, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tokens := make(map[string]Role)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected token and role", file, line)
+		}
+		role, err := ParseRole(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+		if _, ok := tokens[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate token", file, line)
+		}
+		tokens[fields[0]] = role
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewAuthorizer(tokens), nil
+}
+
+// Role retorna o papel do token informado, ou RoleNone caso o token não seja conhecido.
+// Todos os tokens são comparados em tempo constante, de forma que o tempo da verificação não
+// revele quais tokens existem.
+func (a *Authorizer) Role(token string) Role {
+	role := RoleNone
+	for _, g := range a.grants {
+		if subtle.ConstantTimeCompare([]byte(g.token), []byte(token)) == 1 {
+			role = g.role
+		}
+	}
+	return role
+}
+
+// Authorize retorna nulo caso o token informado possua o papel required, ou um papel superior,
+// ou ErrPermissionDenied caso contrário. Caso a estrutura seja nula, todas as chamadas são autorizadas.
+func (a *Authorizer) Authorize(token string, required Role) error {
+	if a == nil {
+		return nil
+	}
+	if token == "" {
+		return fmt.Errorf("%w: missing token, %s role required", ErrPermissionDenied, required)
+	}
+	if a.Role(token) < required {
+		return fmt.Errorf("%w: %s role required", ErrPermissionDenied, required)
+	}
+	return nil
+}
+
+// Prefixo do esquema de autenticação Bearer no cabeçalho Authorization das requisições HTTP
+const bearerPrefix = "Bearer "
+
+// BearerToken retorna o token do cabeçalho Authorization da requisição HTTP, no esquema Bearer,
+// ou uma string vazia caso não tenha sido informado.
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):])
+}
+
+// SetBearerToken define o token no cabeçalho Authorization da requisição HTTP, no esquema Bearer.
+// Um token vazio não é enviado.
+func SetBearerToken(r *http.Request, token string) {
+	if token != "" {
+		r.Header.Set("Authorization", bearerPrefix+token)
+	}
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/trace"
+	"go-rpc/types"
+	"sort"
+	"strings"
+)
+
+// ErrCycle é devolvido pela explosão da lista de materiais quando uma peça é, direta ou
+// indiretamente, subcomponente de si mesma. É o mesmo erro devolvido pelo servidor quando
+// recusa uma composição com ciclo, de forma que ambos sejam identificados com errors.Is.
+var ErrCycle = types.ErrPartCycle
+
+// Estrutura BOMLine representa uma linha da explosão multinível da lista de materiais (BOM):
+// uma ocorrência de uma peça na estrutura da peça raiz.
+type BOMLine struct {
+	Depth    int             // nível da peça na estrutura, zero para a peça raiz
+	Part     interfaces.Part // peça
+	Quantity int             // quantidade da peça em uma unidade da peça que a contém
+	Total    int             // quantidade da peça em uma unidade da peça raiz, ao longo deste caminho
+}
+
+// Estrutura BOMItem representa uma peça primitiva do resumo da lista de materiais e a sua
+// quantidade total em uma unidade da peça raiz, somando todos os caminhos em que aparece.
+type BOMItem struct {
+	Part     interfaces.Part // peça primitiva
+	Quantity int             // quantidade total
+}
+
+// Estrutura BOM representa a explosão da lista de materiais de uma peça.
+type BOM struct {
+	Root       interfaces.Part // peça raiz
+	Lines      []BOMLine       // explosão multinível, em pré-ordem, começando pela peça raiz
+	Primitives []BOMItem       // resumo das peças primitivas, ordenado pelo nome e pelo código
+}
+
+// Report retorna a explosão multinível da lista de materiais, com uma linha por ocorrência de
+// peça, indentada de acordo com o seu nível.
+func (b *BOM) Report() string {
+	var sb strings.Builder
+	for _, line := range b.Lines {
+		fmt.Fprintf(&sb, "%s- %s [%s] quantidade: %d, total: %d\n",
+			strings.Repeat("  ", line.Depth), line.Part.GetName(), partKey(line.Part), line.Quantity, line.Total)
+	}
+	return sb.String()
+}
+
+// Summary retorna o resumo das peças primitivas da lista de materiais, com uma linha por peça.
+func (b *BOM) Summary() string {
+	var sb strings.Builder
+	for _, item := range b.Primitives {
+		fmt.Fprintf(&sb, "%d x %s [%s]\n", item.Quantity, item.Part.GetName(), partKey(item.Part))
+	}
+	return sb.String()
+}
+
+// ExplodeBOM explode a lista de materiais da peça com o código informado: percorre recursivamente
+// os seus subcomponentes, multiplicando as quantidades ao longo de cada caminho, e retorna a
+// explosão multinível e o resumo das peças primitivas.
+// As referências a peças de outros repositórios são resolvidas através do serviço de nomes, o que
+// exige que o cliente tenha sido criado com Dial. Cada peça é consultada uma única vez.
+// Retorna ErrCycle caso uma peça seja subcomponente de si mesma, ou o erro da consulta de uma
+// peça que não pôde ser obtida.
+func (p *PartRepositoryClient) ExplodeBOM(code string) (*BOM, error) {
+	return p.ExplodeBOMContext(context.Background(), code)
+}
+
+// ExplodeBOMContext é a variante de ExplodeBOM que recebe um contexto.
+// A explosão é uma etapa do trace do contexto (ver o pacote trace), da qual todas as consultas,
+// em todos os repositórios percorridos, são etapas filhas.
+func (p *PartRepositoryClient) ExplodeBOMContext(ctx context.Context, code string) (bom *BOM, err error) {
+	ctx, span := trace.Start(ctx, "ExplodeBOM")
+	span.SetAttribute("repository", p.GetRepositoryName())
+	span.SetAttribute("code", code)
+	defer func() { span.End(err) }()
+
+	root, err := p.GetPartContext(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &explosion{ctx: ctx, repo: p, parts: make(map[string]interfaces.Part), totals: make(map[string]*BOMItem)}
+	defer e.close()
+
+	bom = &BOM{Root: root}
+	if err := e.walk(bom, root, 0, 1, 1, []string{partKey(root)}); err != nil {
+		return nil, err
+	}
+
+	for _, item := range e.totals {
+		bom.Primitives = append(bom.Primitives, *item)
+	}
+	sort.Slice(bom.Primitives, func(i, j int) bool {
+		a, b := bom.Primitives[i].Part, bom.Primitives[j].Part
+		if a.GetName() != b.GetName() {
+			return a.GetName() < b.GetName()
+		}
+		return partKey(a) < partKey(b)
+	})
+	return bom, nil
+}
+
+// Estrutura explosion mantém o estado de uma explosão da lista de materiais.
+type explosion struct {
+	ctx      context.Context            // contexto das consultas
+	repo     *PartRepositoryClient      // repositório da peça raiz
+	resolver *Resolver                  // resolve as referências a outros repositórios, criado quando necessário
+	parts    map[string]interfaces.Part // peças já consultadas, indexadas por partKey
+	totals   map[string]*BOMItem        // quantidades totais das peças primitivas, indexadas por partKey
+}
+
+// walk inclui na lista de materiais a peça part, com a quantidade quantity na peça que a contém e
+// a quantidade total total na peça raiz, e percorre os seus subcomponentes.
+// O caminho path contém as chaves das peças da raiz até a peça, e é utilizado para detectar ciclos.
+func (e *explosion) walk(bom *BOM, part interfaces.Part, depth int, quantity int, total int, path []string) error {
+	bom.Lines = append(bom.Lines, BOMLine{Depth: depth, Part: part, Quantity: quantity, Total: total})
+
+	if part.IsPrimitive() {
+		key := partKey(part)
+		if e.totals[key] == nil {
+			e.totals[key] = &BOMItem{Part: part}
+		}
+		e.totals[key].Quantity += total
+		return nil
+	}
+
+	for _, pair := range part.GetSubcomponents() {
+		key := pair.GetRepositoryName() + "/" + pair.GetPartCode()
+		for _, k := range path {
+			if k == key {
+				return fmt.Errorf("%w: %s -> %s", ErrCycle, strings.Join(path, " -> "), key)
+			}
+		}
+
+		sub, err := e.resolve(pair, key)
+		if err != nil {
+			return fmt.Errorf("subpart %s of %s: %w", key, path[len(path)-1], err)
+		}
+		// O caminho é copiado para que os ramos irmãos não compartilhem o mesmo vetor
+		if err := e.walk(bom, sub, depth+1, pair.GetQuantity(), total*pair.GetQuantity(), append(path[:len(path):len(path)], key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve retorna a peça do par, identificada pela chave key, consultando o repositório que a
+// contém caso o par seja uma referência que ainda não foi consultada.
+func (e *explosion) resolve(pair interfaces.Pair, key string) (interfaces.Part, error) {
+	if !pair.IsReference() {
+		return pair.GetPart(), nil
+	}
+	if part, ok := e.parts[key]; ok {
+		return part, nil
+	}
+
+	var part interfaces.Part
+	var err error
+	switch {
+	case pair.GetRepositoryName() == e.repo.GetRepositoryName():
+		part, err = e.repo.GetPartContext(e.ctx, pair.GetPartCode())
+	case e.repo.ns == nil:
+		err = fmt.Errorf("no name server to resolve repository %s", pair.GetRepositoryName())
+	default:
+		if e.resolver == nil {
+			e.resolver = NewResolver(e.repo.ns)
+		}
+		part, err = e.resolver.ResolveContext(e.ctx, pair)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.parts[key] = part
+	return part, nil
+}
+
+// close encerra as conexões abertas com outros repositórios durante a explosão.
+func (e *explosion) close() {
+	if e.resolver != nil {
+		e.resolver.Close()
+	}
+}
+
+// partKey retorna a chave que identifica a peça entre todos os repositórios, no formato repositório/código.
+func partKey(part interfaces.Part) string {
+	return part.GetRepositoryName() + "/" + part.GetCode()
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/trace"
+	"sync"
+	"time"
+)
+
+// resolverDialTimeout é o tempo máximo da conexão a um repositório feita pelo Resolver, que não
+// depende do prazo de nenhuma das resoluções que aguardam por ela.
+const resolverDialTimeout = 10 * time.Second
+
+// errResolverClosed sinaliza que o Resolver foi encerrado com Close.
+var errResolverClosed = errors.New("resolver closed")
+
+// Estrutura Resolver resolve as referências de peças armazenadas nos pares de subcomponentes
+// (ver interfaces.Pair), obtendo a peça atual do repositório que a contém.
+//
+// Os repositórios são resolvidos no serviço de nomes apenas quando uma referência a eles é
+// resolvida pela primeira vez, e as conexões são mantidas para as resoluções seguintes. Os clientes
+// dos repositórios que deixam de responder são descartados, e o repositório é resolvido novamente
+// na resolução seguinte; o cliente descartado só é fechado quando nenhuma resolução o utiliza, de
+// forma que as chamadas em andamento ainda possam recorrer a outras instâncias do repositório.
+// A estrutura é segura para uso concorrente.
+type Resolver struct {
+	ns      *naming.NameServerClient   // cliente do serviço de nomes
+	mu      sync.Mutex                 // protege os mapas de clientes e de conexões em andamento e o encerramento
+	clients map[string]*resolvedClient // clientes dos repositórios já conectados, indexados pelo nome
+	dialing map[string]*dialCall       // conexões em andamento, indexadas pelo nome do repositório
+	closed  bool                       // se Close foi chamado
+}
+
+// Estrutura resolvedClient representa o cliente de um repositório mantido pelo Resolver e as
+// resoluções que o utilizam.
+type resolvedClient struct {
+	repo  *PartRepositoryClient // cliente conectado ao repositório
+	users int                   // número de resoluções em andamento que utilizam o cliente
+	stale bool                  // se o cliente foi descartado; é fechado quando users chega a zero
+}
+
+// Estrutura dialCall representa uma conexão em andamento a um repositório, compartilhada pelas
+// resoluções concorrentes do mesmo repositório.
+type dialCall struct {
+	done chan struct{} // fechado ao término da conexão
+	err  error         // erro da conexão
+}
+
+// NewResolver retorna o ponteiro para uma estrutura Resolver.
+// Ela recebe como parâmetro o cliente do serviço de nomes utilizado para localizar os repositórios.
+func NewResolver(ns *naming.NameServerClient) *Resolver {
+	return &Resolver{ns: ns, clients: make(map[string]*resolvedClient), dialing: make(map[string]*dialCall)}
+}
+
+// Resolve retorna a peça do par informado. Caso o par armazene uma cópia da peça, a cópia é
+// devolvida; caso seja uma referência, a peça é consultada no repositório que a contém.
+// Retorna um erro do tipo *rpcerror.Error caso o repositório ou a peça não sejam encontrados.
+func (r *Resolver) Resolve(pair interfaces.Pair) (interfaces.Part, error) {
+	return r.ResolveContext(context.Background(), pair)
+}
+
+// ResolveContext é a variante de Resolve que recebe um contexto.
+func (r *Resolver) ResolveContext(ctx context.Context, pair interfaces.Pair) (interfaces.Part, error) {
+	if !pair.IsReference() {
+		return pair.GetPart(), nil
+	}
+
+	return r.ResolvePart(ctx, pair.GetRepositoryName(), pair.GetPartCode())
+}
+
+// ResolvePart retorna a peça com o código informado do repositório com o nome informado.
+// Ela implementa a interface server.PartResolver, permitindo que um servidor de repositório
+// valide composições que referenciam peças de outros repositórios.
+// Caso o repositório não responda, mesmo após as novas tentativas do cliente, o cliente é descartado.
+func (r *Resolver) ResolvePart(ctx context.Context, repository string, code string) (interfaces.Part, error) {
+	c, err := r.acquire(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(c)
+
+	part, err := c.repo.GetPartContext(ctx, code)
+	if rpcerror.IsConnectionError(err) {
+		r.evict(repository, c)
+	}
+	return part, err
+}
+
+// Client retorna o cliente do repositório com o nome informado, conectando-se a ele caso seja
+// a primeira vez que o repositório é utilizado ou caso o cliente anterior tenha sido descartado.
+// O cliente pertence ao Resolver e é fechado por Close, ou caso seja descartado por falhas.
+// Retorna um erro da categoria rpcerror.ErrCanceled caso o Resolver tenha sido encerrado.
+func (r *Resolver) Client(ctx context.Context, name string) (*PartRepositoryClient, error) {
+	c, err := r.acquire(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r.release(c)
+	return c.repo, nil
+}
+
+// acquire retorna o cliente do repositório com o nome informado, que passa a ser utilizado por
+// mais uma resolução até a chamada de release.
+// A conexão é feita fora do mutex, para que um repositório lento não bloqueie a resolução dos
+// demais, e é compartilhada pelas chamadas concorrentes para o mesmo repositório. Como ela não
+// depende do contexto de nenhuma delas (ver dial), o cancelamento de uma chamada não afeta as demais.
+func (r *Resolver) acquire(ctx context.Context, name string) (*resolvedClient, error) {
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, rpcerror.New("Dial", rpcerror.ErrCanceled, errResolverClosed)
+		}
+		if c, ok := r.clients[name]; ok {
+			c.users++
+			r.mu.Unlock()
+			return c, nil
+		}
+		call, ok := r.dialing[name]
+		if !ok {
+			call = &dialCall{done: make(chan struct{})}
+			r.dialing[name] = call
+			go r.dial(ctx, name, call)
+		}
+		r.mu.Unlock()
+
+		// Após a conexão, o cliente é obtido do mapa, a menos que já tenha sido descartado
+		select {
+		case <-call.done:
+			if call.err != nil {
+				return nil, call.err
+			}
+		case <-ctx.Done():
+			return nil, rpcerror.Transport("Dial", ctx.Err())
+		}
+	}
+}
+
+// dial conecta-se ao repositório com o nome informado e inclui o cliente no mapa, a menos que o
+// Resolver tenha sido encerrado nesse intervalo. A conexão utiliza um prazo próprio, e não o do
+// contexto parent, do qual herda apenas a etapa corrente do trace.
+func (r *Resolver) dial(parent context.Context, name string, call *dialCall) {
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.FromContext(parent))
+	ctx, cancel := context.WithTimeout(ctx, resolverDialTimeout)
+	defer cancel()
+	repo, err := DialContext(ctx, r.ns, name)
+
+	r.mu.Lock()
+	delete(r.dialing, name)
+	closed := r.closed
+	if err == nil && !closed {
+		r.clients[name] = &resolvedClient{repo: repo}
+	}
+	if err == nil && closed {
+		err = rpcerror.New("Dial", rpcerror.ErrCanceled, errResolverClosed)
+	}
+	call.err = err
+	r.mu.Unlock()
+
+	if repo != nil && closed {
+		repo.Close()
+	}
+	close(call.done)
+}
+
+// release sinaliza que uma resolução deixou de utilizar o cliente c, fechando-o caso ele tenha
+// sido descartado e não seja mais utilizado.
+func (r *Resolver) release(c *resolvedClient) {
+	r.mu.Lock()
+	c.users--
+	unused := c.stale && c.users == 0
+	r.mu.Unlock()
+	if unused {
+		c.repo.Close()
+	}
+}
+
+// evict descarta o cliente c do repositório com o nome informado, caso ainda seja o cliente
+// corrente, para que o repositório seja resolvido novamente na resolução seguinte. O cliente é
+// fechado por release, quando a última resolução deixar de utilizá-lo.
+func (r *Resolver) evict(name string, c *resolvedClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.clients[name] == c {
+		delete(r.clients, name)
+		c.stale = true
+	}
+}
+
+// Close encerra as conexões com todos os repositórios utilizados. As conexões utilizadas por
+// resoluções em andamento são encerradas ao término delas, e as conexões ainda em andamento são
+// encerradas assim que estabelecidas. As resoluções seguintes falham com um erro da categoria
+// rpcerror.ErrCanceled.
+func (r *Resolver) Close() {
+	r.mu.Lock()
+	r.closed = true
+	var unused []*resolvedClient
+	for name, c := range r.clients {
+		delete(r.clients, name)
+		c.stale = true
+		if c.users == 0 {
+			unused = append(unused, c)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, c := range unused {
+		c.repo.Close()
+	}
+}
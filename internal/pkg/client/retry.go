@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Estrutura RetryPolicy define como uma chamada remota é repetida quando falha por perda da
+// conexão com o servidor (ver rpcerror.IsConnectionError). Antes de cada nova tentativa, o
+// cliente aguarda um intervalo que dobra a cada tentativa, de InitialBackoff até MaxBackoff,
+// e se reconecta ao servidor, possivelmente a outra instância registrada com o mesmo nome.
+//
+// Apenas chamadas idempotentes devem ser repetidas: como a resposta pode ter sido perdida depois
+// que o servidor atendeu a chamada, uma nova tentativa pode executá-la novamente.
+type RetryPolicy struct {
+	MaxAttempts    int           // número máximo de tentativas, incluindo a primeira
+	InitialBackoff time.Duration // intervalo antes da segunda tentativa
+	MaxBackoff     time.Duration // intervalo máximo entre tentativas
+}
+
+// Políticas de repetição predefinidas
+var (
+	// DefaultRetryPolicy é a política das chamadas idempotentes, que são repetidas até quatro vezes.
+	DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+	// NoRetry é a política das chamadas que não são idempotentes, que não são repetidas.
+	// A conexão perdida é, ainda assim, restabelecida na chamada seguinte.
+	NoRetry = RetryPolicy{MaxAttempts: 1}
+)
+
+// defaultRetryPolicies define as políticas dos métodos que não utilizam DefaultRetryPolicy.
+// AddPart não é idempotente, já que cada chamada cria uma peça com um novo código, e uma nova
+// tentativa de DeletePart, depois de uma remoção bem sucedida, seria recusada com types.ErrPartNotFound.
+var defaultRetryPolicies = map[string]RetryPolicy{
+	"AddPart":    NoRetry,
+	"DeletePart": NoRetry,
+}
+
+// backoff retorna o intervalo de espera antes da tentativa seguinte à tentativa attempt,
+// contada a partir de zero. Um MaxBackoff nulo não limita o intervalo.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	d := r.InitialBackoff
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if r.MaxBackoff > 0 && d >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return d
+}
+
+// sleep aguarda o intervalo d, retornando o erro do contexto caso ele seja cancelado ou o seu
+// prazo vença antes.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+	"go-rpc/interfaces"
+	"go-rpc/types"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 6, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, w := range want {
+		if got := policy.backoff(attempt); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+
+	// Sem MaxBackoff, o intervalo continua dobrando
+	policy.MaxBackoff = 0
+	if got := policy.backoff(5); got != 3200*time.Millisecond {
+		t.Errorf("backoff(5) without MaxBackoff = %v, want 3.2s", got)
+	}
+}
+
+func TestFailoverOrder(t *testing.T) {
+	a := types.NewRemoteRefImpl("127.0.0.1", "9001", "server1")
+	b := types.NewRemoteRefImpl("127.0.0.1", "9002", "server1")
+	c := types.NewRemoteRefImpl("127.0.0.1", "9003", "server1")
+	refs := []interfaces.RemoteRef{a, b, c}
+
+	tests := []struct {
+		current interfaces.RemoteRef
+		want    []interfaces.RemoteRef
+	}{
+		{nil, []interfaces.RemoteRef{a, b, c}},
+		{a, []interfaces.RemoteRef{b, c, a}},
+		{b, []interfaces.RemoteRef{a, c, b}},
+		{types.NewRemoteRefImpl("127.0.0.1", "9004", "server1"), []interfaces.RemoteRef{a, b, c}},
+	}
+	for _, tt := range tests {
+		if got := failoverOrder(refs, tt.current); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("failoverOrder(%v) = %v, want %v", tt.current, got, tt.want)
+		}
+	}
+}
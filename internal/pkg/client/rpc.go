@@ -3,72 +3,543 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/server"
+	"go-rpc/internal/pkg/tlsconfig"
+	"go-rpc/internal/pkg/trace"
+	"go-rpc/types"
 	"log"
+	"net"
 	"net/rpc"
+	"strings"
+	"sync"
 )
 
+// Erros do repositório de peças que podem ser devolvidos pelo servidor remoto, incluindo o erro
+// devolvido quando o servidor descarta uma chamada cujo prazo venceu.
+var repositoryErrors = []error{
+	types.ErrPartNotFound, types.ErrPartAlreadyExists, types.ErrPartInUse, types.ErrPartCycle, types.ErrPartTooDeep,
+	types.ErrInvalidPageToken, server.ErrInvalidRequest, auth.ErrPermissionDenied, context.DeadlineExceeded,
+}
+
 // Estrutura PartRepositoryClient representa um cliente do servidor servidor PartRepositoryServer.
 // Essa é a estrutura do objeto que será registrada e exposta via RPC. Em geral, ela converte
 // uma chamada local p.AddPart numa chamada rpc definida pela API net/rpc.
+//
+// Quando a conexão com o servidor é perdida (por exemplo, quando o servidor é reiniciado), o cliente
+// se reconecta na chamada seguinte. Caso tenha sido criado com Dial, o nome do servidor é resolvido
+// novamente no serviço de nomes e, caso haja várias instâncias registradas com o nome, o cliente
+// recorre a outra instância. As chamadas que falham por perda da conexão são repetidas de acordo
+// com a política de repetição do método (ver RetryPolicy e SetRetryPolicy).
+//
+// Caso o servidor exija autenticação, as chamadas carregam o token de API do cliente (ver SetToken)
+// e as chamadas recusadas falham com um erro da categoria rpcerror.ErrPermissionDenied.
+//
+// Cada chamada é uma etapa do trace do contexto recebido (ver o pacote trace), e o seu
+// atendimento no servidor é uma etapa filha dela.
+//
+// A estrutura é segura para uso concorrente.
 type PartRepositoryClient struct {
-	ref    interfaces.RemoteRef
-	client *rpc.Client // ponteiro para cliente RPC
+	mu       sync.Mutex               // protege a conexão, a referência, as políticas de repetição e o token
+	ref      interfaces.RemoteRef     // referência do servidor conectado
+	client   *rpc.Client              // ponteiro para cliente RPC, nulo caso a conexão tenha sido perdida
+	ns       *naming.NameServerClient // cliente do serviço de nomes, nulo caso o cliente não tenha sido criado com Dial
+	name     string                   // nome do servidor no serviço de nomes
+	policies map[string]RetryPolicy   // políticas de repetição, indexadas pelo nome do método
+	token    string                   // token de API enviado em todas as chamadas
+	closed   bool                     // se o cliente foi encerrado com Close
 }
 
 // NewPartRepositoryClient retorna o ponteiro para uma estrutura PartRepositoryClient.
-// Ela recebe como parâmetro um ponteiro para um cliente RPC.
+// Ela recebe como parâmetro um ponteiro para um cliente RPC conectado ao servidor remoto e a
+// referência desse servidor. Caso a conexão seja perdida, o cliente se reconecta ao mesmo endereço.
 func NewPartRepositoryClient(client *rpc.Client, ref interfaces.RemoteRef) *PartRepositoryClient {
-	return &PartRepositoryClient{ref, client}
+	return &PartRepositoryClient{ref: ref, client: client, name: ref.GetName()}
+}
+
+// Dial resolve o nome do servidor no serviço de nomes e retorna o ponteiro para uma estrutura
+// PartRepositoryClient conectada a uma das instâncias registradas com esse nome, na ordem de registro.
+// Retorna um erro da categoria rpcerror.ErrNotFound caso o nome não esteja registrado, ou
+// rpcerror.ErrConnectionLost caso não seja possível se conectar a nenhuma instância.
+func Dial(ns *naming.NameServerClient, name string) (*PartRepositoryClient, error) {
+	return DialContext(context.Background(), ns, name)
+}
+
+// DialContext é a variante de Dial que recebe um contexto.
+func DialContext(ctx context.Context, ns *naming.NameServerClient, name string) (*PartRepositoryClient, error) {
+	refs, err := ns.LookupAllContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PartRepositoryClient{ns: ns, name: name, token: ns.Token()}
+	if p.ref, p.client, err = p.connect(ctx, refs); err != nil {
+		return nil, rpcerror.Transport("Dial", err)
+	}
+	return p, nil
+}
+
+// SetRetryPolicy altera a política de repetição do método informado, identificado pelo nome
+// sem o prefixo do serviço (ex.: "GetPart"). Por padrão, as chamadas são repetidas de acordo
+// com DefaultRetryPolicy, exceto AddPart e DeletePart, que utilizam NoRetry.
+func (p *PartRepositoryClient) SetRetryPolicy(method string, policy RetryPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.policies == nil {
+		p.policies = make(map[string]RetryPolicy)
+	}
+	p.policies[method] = policy
+}
+
+// SetToken altera o token de API enviado em todas as chamadas. Os clientes criados com Dial
+// utilizam inicialmente o token do cliente do serviço de nomes (ver naming.NameServerClient.SetToken).
+func (p *PartRepositoryClient) SetToken(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.token = token
 }
 
+// header retorna o cabeçalho de uma chamada, com o prazo e a etapa corrente do contexto ctx e o
+// token do cliente.
+func (p *PartRepositoryClient) header(ctx context.Context) server.Header {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return server.NewHeader(ctx).WithToken(p.token)
+}
+
+// Close encerra a conexão com o servidor. Chamadas posteriores falham, sem novas tentativas, com um
+// erro da categoria rpcerror.ErrCanceled.
+func (p *PartRepositoryClient) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.client == nil {
+		return nil
+	}
+	err := p.client.Close()
+	p.client = nil
+	return err
+}
+
+// Todos os métodos possuem uma variante com o sufixo Context, que recebe um context.Context.
+// A chamada é abandonada caso o contexto seja cancelado ou o seu prazo vença antes da resposta,
+// devolvendo um erro da categoria rpcerror.ErrCanceled ou rpcerror.ErrTimeout, respectivamente.
+// O prazo também é enviado ao servidor (ver server.Header), que descarta as chamadas que o
+// cliente já abandonou. As variantes sem contexto utilizam context.Background() e, portanto,
+// não possuem prazo.
+
 // AddPart adiciona uma peça ao repositório de peças.
 // Ela recebe como parâmetro um objeto que implementa a interface interfaces.Part
-// e faz uma chamada RPC via cliente RPC passando o ponteiro para a peça a ser
+// e faz uma chamada RPC via cliente RPC passando os argumentos com a peça a ser
 // adicionada e o ponteiro para a peça a ser devolvida com informações adicionais, respectivamente.
-// Ela retorna o objeto devolvido, que implementa a interface interfaces.Part.
-func (p *PartRepositoryClient) AddPart(part interfaces.Part) interfaces.Part {
+// Ela retorna o objeto devolvido, que implementa a interface interfaces.Part, ou um erro do tipo
+// *rpcerror.Error caso a chamada falhe.
+func (p *PartRepositoryClient) AddPart(part interfaces.Part) (interfaces.Part, error) {
+	return p.AddPartContext(context.Background(), part)
+}
+
+// AddPartContext é a variante de AddPart que recebe um contexto.
+func (p *PartRepositoryClient) AddPartContext(ctx context.Context, part interfaces.Part) (interfaces.Part, error) {
+	var reply interfaces.Part
 	// Faz chamada RPC
-	err := p.client.Call("PartRepository.AddPart", &part, &part)
-	// Sinaliza erros no canal
-	if err != nil {
-		log.Fatal("Fatal error:", err)
+	if err := p.call(ctx, "PartRepository.AddPart", &server.PartArgs{Part: part}, &reply); err != nil {
+		return nil, err
 	}
-	return part
+	return reply, nil
 }
 
 // GetPart recupera uma peça do repositório de peças a partir do seu código.
 // Ela recebe como parâmetro uma string contendo o código a ser buscado
-// e faz uma chamada RPC via cliente RPC passando o ponteiro para o código a ser buscado
+// e faz uma chamada RPC via cliente RPC passando os argumentos com o código a ser buscado
 // o ponteiro para a peça a ser devolvida, caso encontrada, respectivamente.
-// Ela retorna o objeto devolvido, que implementa a interface interfaces.Part.
-func (p *PartRepositoryClient) GetPart(code string) interfaces.Part {
+// Ela retorna o objeto devolvido, que implementa a interface interfaces.Part, ou um erro
+// da categoria rpcerror.ErrNotFound caso a peça não exista.
+func (p *PartRepositoryClient) GetPart(code string) (interfaces.Part, error) {
+	return p.GetPartContext(context.Background(), code)
+}
+
+// GetPartContext é a variante de GetPart que recebe um contexto.
+func (p *PartRepositoryClient) GetPartContext(ctx context.Context, code string) (interfaces.Part, error) {
 	var part interfaces.Part
 	// Faz chamada RPC
-	err := p.client.Call("PartRepository.GetPart", &code, &part)
-	// SInaliza erros no canal
-	if err != nil {
-		log.Fatal("Fatal error:", err)
+	if err := p.call(ctx, "PartRepository.GetPart", &server.CodeArgs{Code: code}, &part); err != nil {
+		return nil, err
 	}
-	return part
+	return part, nil
 }
 
 // GetParts recupera a lista de peças do repositório de peças a partir do seu código.
-// Ela faz uma chamada RPC via cliente RPC passando o ponteiro uma string dummy, que será ignorada,
-// e um ponteiro para a lista de peças a ser devolvida, respectivamente.
-// Ela retorna uma lista de objetos que implementam a interface interfaces.Part.
-func (p *PartRepositoryClient) GetParts() []interfaces.Part {
+// Ela faz uma chamada RPC via cliente RPC passando o cabeçalho da chamada, já que o método não
+// possui outros argumentos, e um ponteiro para a lista de peças a ser devolvida, respectivamente.
+// Ela retorna uma lista de objetos que implementam a interface interfaces.Part, ou um erro
+// do tipo *rpcerror.Error caso a chamada falhe.
+func (p *PartRepositoryClient) GetParts() ([]interfaces.Part, error) {
+	return p.GetPartsContext(context.Background())
+}
+
+// GetPartsContext é a variante de GetParts que recebe um contexto.
+func (p *PartRepositoryClient) GetPartsContext(ctx context.Context) ([]interfaces.Part, error) {
+	return p.query(ctx, "PartRepository.GetParts", &server.Header{})
+}
+
+// ListParts retorna uma página das peças do repositório, em ordem crescente de código, com até
+// pageSize peças (zero indica o tamanho padrão do servidor), e o token de continuação da próxima
+// página, vazio caso não haja mais peças. A primeira página é obtida com pageToken vazio, e as
+// seguintes com o token devolvido pela página anterior.
+// Ao contrário de GetParts, permite percorrer repositórios grandes sem obtê-los em uma única resposta.
+func (p *PartRepositoryClient) ListParts(pageSize int, pageToken string) ([]interfaces.Part, string, error) {
+	return p.ListPartsContext(context.Background(), pageSize, pageToken)
+}
+
+// ListPartsContext é a variante de ListParts que recebe um contexto.
+func (p *PartRepositoryClient) ListPartsContext(ctx context.Context, pageSize int, pageToken string) ([]interfaces.Part, string, error) {
+	var reply server.ListPartsReply
+	args := &server.ListPartsArgs{PageSize: pageSize, PageToken: pageToken}
+	// Faz chamada RPC
+	if err := p.call(ctx, "PartRepository.ListParts", args, &reply); err != nil {
+		return nil, "", err
+	}
+	return reply.Parts, reply.NextPageToken, nil
+}
+
+// FindPartsByName retorna as peças do repositório cujo nome é exatamente igual ao nome informado.
+func (p *PartRepositoryClient) FindPartsByName(name string) ([]interfaces.Part, error) {
+	return p.FindPartsByNameContext(context.Background(), name)
+}
+
+// FindPartsByNameContext é a variante de FindPartsByName que recebe um contexto.
+func (p *PartRepositoryClient) FindPartsByNameContext(ctx context.Context, name string) ([]interfaces.Part, error) {
+	return p.query(ctx, "PartRepository.FindPartsByName", &server.NameArgs{Name: name})
+}
+
+// FindPartsByNamePrefix retorna as peças do repositório cujo nome começa com o prefixo informado.
+func (p *PartRepositoryClient) FindPartsByNamePrefix(prefix string) ([]interfaces.Part, error) {
+	return p.FindPartsByNamePrefixContext(context.Background(), prefix)
+}
+
+// FindPartsByNamePrefixContext é a variante de FindPartsByNamePrefix que recebe um contexto.
+func (p *PartRepositoryClient) FindPartsByNamePrefixContext(ctx context.Context, prefix string) ([]interfaces.Part, error) {
+	return p.query(ctx, "PartRepository.FindPartsByNamePrefix", &server.NameArgs{Name: prefix})
+}
+
+// GetPrimitiveParts retorna as peças primitivas do repositório.
+func (p *PartRepositoryClient) GetPrimitiveParts() ([]interfaces.Part, error) {
+	return p.GetPrimitivePartsContext(context.Background())
+}
+
+// GetPrimitivePartsContext é a variante de GetPrimitiveParts que recebe um contexto.
+func (p *PartRepositoryClient) GetPrimitivePartsContext(ctx context.Context) ([]interfaces.Part, error) {
+	return p.query(ctx, "PartRepository.GetPrimitiveParts", &server.Header{})
+}
+
+// GetAggregateParts retorna as peças agregadas do repositório.
+func (p *PartRepositoryClient) GetAggregateParts() ([]interfaces.Part, error) {
+	return p.GetAggregatePartsContext(context.Background())
+}
+
+// GetAggregatePartsContext é a variante de GetAggregateParts que recebe um contexto.
+func (p *PartRepositoryClient) GetAggregatePartsContext(ctx context.Context) ([]interfaces.Part, error) {
+	return p.query(ctx, "PartRepository.GetAggregateParts", &server.Header{})
+}
+
+// WhereUsed retorna as peças do repositório que utilizam a peça com o código informado como
+// subcomponente direto ou, caso recursive seja verdadeiro, direta ou indiretamente.
+// A consulta se limita a este repositório (ver Resolver.WhereUsed para a consulta a todos os repositórios).
+func (p *PartRepositoryClient) WhereUsed(code string, recursive bool) ([]interfaces.Part, error) {
+	return p.WhereUsedContext(context.Background(), code, recursive)
+}
+
+// WhereUsedContext é a variante de WhereUsed que recebe um contexto.
+func (p *PartRepositoryClient) WhereUsedContext(ctx context.Context, code string, recursive bool) ([]interfaces.Part, error) {
+	return p.query(ctx, "PartRepository.WhereUsed", &server.WhereUsedArgs{Code: code, Recursive: recursive})
+}
+
+// query faz uma chamada RPC de consulta que devolve uma lista de peças.
+func (p *PartRepositoryClient) query(ctx context.Context, method string, args server.Args) ([]interfaces.Part, error) {
 	var parts []interfaces.Part
 	// Faz chamada RPC
-	err := p.client.Call("PartRepository.GetParts", "dummy", &parts)
-	// Sinaliza erros no canal
-	if err != nil {
-		log.Fatal("RPC error:", err)
+	if err := p.call(ctx, method, args, &parts); err != nil {
+		return nil, err
 	}
-	return parts
+	return parts, nil
+}
+
+// UpdatePart substitui uma peça do repositório de peças, identificada pelo seu código.
+// Ela recebe como parâmetro um objeto que implementa a interface interfaces.Part e
+// retorna a peça armazenada pelo servidor, ou um erro caso a alteração seja recusada
+// (por exemplo, types.ErrPartNotFound).
+func (p *PartRepositoryClient) UpdatePart(part interfaces.Part) (interfaces.Part, error) {
+	return p.UpdatePartContext(context.Background(), part)
+}
+
+// UpdatePartContext é a variante de UpdatePart que recebe um contexto.
+func (p *PartRepositoryClient) UpdatePartContext(ctx context.Context, part interfaces.Part) (interfaces.Part, error) {
+	var reply interfaces.Part
+	// Faz chamada RPC
+	if err := p.call(ctx, "PartRepository.UpdatePart", &server.PartArgs{Part: part}, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// DeletePart remove uma peça do repositório de peças a partir do seu código e retorna
+// os códigos das peças removidas.
+// Caso a peça seja subcomponente de outras peças do repositório, a remoção é recusada com
+// types.ErrPartInUse, a menos que cascade seja verdadeiro.
+func (p *PartRepositoryClient) DeletePart(code string, cascade bool) ([]string, error) {
+	return p.DeletePartContext(context.Background(), code, cascade)
+}
+
+// DeletePartContext é a variante de DeletePart que recebe um contexto.
+func (p *PartRepositoryClient) DeletePartContext(ctx context.Context, code string, cascade bool) ([]string, error) {
+	var deleted []string
+	args := &server.DeletePartArgs{Code: code, Cascade: cascade}
+	// Faz chamada RPC
+	if err := p.call(ctx, "PartRepository.DeletePart", args, &deleted); err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
 // GetRepositoryName retorna o nome do repositório de peças atualmente conectado
-func (p PartRepositoryClient) GetRepositoryName() string {
-	return p.ref.GetName()
+func (p *PartRepositoryClient) GetRepositoryName() string {
+	return p.name
+}
+
+// GetRef retorna a referência da instância do servidor atualmente conectada, que pode mudar
+// quando o cliente se reconecta.
+func (p *PartRepositoryClient) GetRef() interfaces.RemoteRef {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ref
+}
+
+// call faz a chamada RPC ao método informado, repetindo-a de acordo com a política de repetição
+// do método caso a conexão com o servidor seja perdida. Antes de cada nova tentativa, o cliente
+// aguarda o intervalo definido pela política e se reconecta ao servidor.
+// A chamada, incluindo as novas tentativas, é uma etapa filha da etapa corrente de ctx (ver o
+// pacote trace), identificada no cabeçalho dos argumentos junto com o prazo restante e o token do cliente.
+func (p *PartRepositoryClient) call(ctx context.Context, method string, args server.Args, reply interface{}) (err error) {
+	ctx, span := trace.Start(ctx, method)
+	defer func() { span.End(err) }()
+
+	policy := p.retryPolicy(method)
+	for attempt := 0; ; attempt++ {
+		client, err := p.conn(ctx, method)
+		if err == nil {
+			// O cabeçalho é refeito a cada tentativa, para que o servidor receba o tempo restante
+			// até o prazo, descontadas as tentativas anteriores e os intervalos entre elas
+			*args.GetHeader() = p.header(ctx)
+			err = p.invoke(ctx, client, method, args, reply)
+			if rpcerror.IsConnectionError(err) {
+				p.disconnect(client)
+			}
+		}
+		if !rpcerror.IsConnectionError(err) || attempt+1 >= policy.MaxAttempts {
+			return err
+		}
+
+		if err := sleep(ctx, policy.backoff(attempt)); err != nil {
+			return rpcerror.Transport(method, err)
+		}
+	}
+}
+
+// invoke faz a chamada RPC ao método informado com o cliente RPC client e aguarda a resposta até
+// que o contexto seja cancelado ou o seu prazo vença. Uma eventual falha é convertida num erro do tipo
+// *rpcerror.Error: erros devolvidos pelo servidor são classificados como rpcerror.ErrNotFound,
+// rpcerror.ErrTimeout (caso o servidor tenha descartado a chamada pelo prazo) ou rpcerror.ErrServer,
+// e os demais como falhas de comunicação (ver rpcerror.Transport).
+// Uma chamada abandonada não é interrompida no cliente RPC: a resposta, caso chegue, é descartada.
+func (p *PartRepositoryClient) invoke(ctx context.Context, client *rpc.Client, method string, args interface{}, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return rpcerror.Transport(method, err)
+	}
+
+	var err error
+	c := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-c.Done:
+		err = c.Error
+	case <-ctx.Done():
+		return rpcerror.Transport(method, ctx.Err())
+	}
+	if err == nil {
+		return nil
+	}
+
+	serverErr, ok := err.(rpc.ServerError)
+	if !ok {
+		return rpcerror.Transport(method, err)
+	}
+
+	err = serverError(serverErr)
+	switch {
+	case errors.Is(err, types.ErrPartNotFound):
+		return rpcerror.New(method, rpcerror.ErrNotFound, err)
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return rpcerror.New(method, rpcerror.ErrPermissionDenied, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return rpcerror.New(method, rpcerror.ErrTimeout, err)
+	}
+	return rpcerror.New(method, rpcerror.ErrServer, err)
+}
+
+// retryPolicy retorna a política de repetição do método informado, identificado pelo nome completo.
+func (p *PartRepositoryClient) retryPolicy(method string) RetryPolicy {
+	name := strings.TrimPrefix(method, "PartRepository.")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if policy, ok := p.policies[name]; ok {
+		return policy
+	}
+	if policy, ok := defaultRetryPolicies[name]; ok {
+		return policy
+	}
+	return DefaultRetryPolicy
+}
+
+// conn retorna o cliente RPC conectado ao servidor, reconectando-se caso a conexão tenha sido perdida.
+// Caso o cliente tenha sido criado com Dial, o nome do servidor é resolvido novamente; caso contrário,
+// o cliente se reconecta ao último endereço conhecido. A resolução e a conexão são feitas fora do
+// mutex, para que uma instância lenta não bloqueie as demais chamadas; caso outra chamada tenha se
+// reconectado nesse intervalo, a nova conexão é descartada e a existente é utilizada.
+// Retorna um erro da categoria rpcerror.ErrCanceled, que não é repetido, caso o cliente tenha sido
+// encerrado com Close.
+func (p *PartRepositoryClient) conn(ctx context.Context, method string) (*rpc.Client, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, rpcerror.New(method, rpcerror.ErrCanceled, rpc.ErrShutdown)
+	}
+	if p.client != nil {
+		defer p.mu.Unlock()
+		return p.client, nil
+	}
+	current := p.ref
+	p.mu.Unlock()
+
+	refs := []interfaces.RemoteRef{current}
+	if p.ns != nil {
+		var err error
+		refs, err = p.ns.LookupAllContext(ctx, p.name)
+		if err != nil {
+			// O servidor pode estar sendo reiniciado e ainda não ter se registrado novamente
+			if errors.Is(err, rpcerror.ErrNotFound) {
+				return nil, rpcerror.New(method, rpcerror.ErrConnectionLost, err)
+			}
+			return nil, err
+		}
+	}
+
+	ref, client, err := p.connect(ctx, failoverOrder(refs, current))
+	if err != nil {
+		return nil, rpcerror.Transport(method, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		client.Close()
+		return nil, rpcerror.New(method, rpcerror.ErrCanceled, rpc.ErrShutdown)
+	}
+	if p.client != nil {
+		// Outra chamada se reconectou enquanto esta se conectava
+		client.Close()
+		return p.client, nil
+	}
+	if p.ref != nil && p.ref.GetAddress() != ref.GetAddress() {
+		log.Printf("[!] Failing over %s from %s to %s", p.name, p.ref.GetAddress(), ref.GetAddress())
+	}
+	p.ref, p.client = ref, client
+	return client, nil
+}
+
+// connect se conecta à primeira instância da lista refs que aceitar a conexão e retorna a sua
+// referência e o cliente RPC conectado a ela, sem alterar a estrutura.
+// Retorna o erro do contexto, caso ele seja cancelado ou o seu prazo vença, ou as falhas de
+// conexão com cada instância.
+func (p *PartRepositoryClient) connect(ctx context.Context, refs []interfaces.RemoteRef) (interfaces.RemoteRef, *rpc.Client, error) {
+	var dialer net.Dialer
+	var errs []string
+	for _, ref := range refs {
+		conn, err := dialer.DialContext(ctx, "tcp", ref.GetAddress())
+		if err == nil && p.ns != nil && p.ns.TLSConfig() != nil {
+			// Os repositórios utilizam a mesma configuração TLS do serviço de nomes
+			conn, err = handshake(ctx, conn, tlsconfig.ForHost(p.ns.TLSConfig(), ref.GetHost()))
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return ref, rpc.NewClient(conn), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, errors.New(strings.Join(errs, "; "))
+}
+
+// handshake estabelece uma sessão TLS sobre a conexão conn, com a configuração cfg, e retorna a
+// conexão TLS. A conexão é fechada caso o handshake falhe, por exemplo porque o certificado do
+// servidor não foi emitido pela CA configurada.
+func handshake(ctx context.Context, conn net.Conn, cfg *tls.Config) (net.Conn, error) {
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// disconnect descarta o cliente RPC client, caso ainda seja o cliente corrente, para que a chamada
+// seguinte se reconecte ao servidor.
+func (p *PartRepositoryClient) disconnect(client *rpc.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client == client {
+		p.client.Close()
+		p.client = nil
+	}
+}
+
+// failoverOrder retorna as instâncias refs com a instância current, que perdeu a conexão, ao final
+// da lista, de forma que a reconexão dê preferência às demais instâncias.
+func failoverOrder(refs []interfaces.RemoteRef, current interfaces.RemoteRef) []interfaces.RemoteRef {
+	ordered := make([]interfaces.RemoteRef, 0, len(refs))
+	var last []interfaces.RemoteRef
+	for _, ref := range refs {
+		if current != nil && ref.GetAddress() == current.GetAddress() {
+			last = append(last, ref)
+			continue
+		}
+		ordered = append(ordered, ref)
+	}
+	return append(ordered, last...)
+}
+
+// serverError converte um erro devolvido pelo servidor remoto no erro correspondente do repositório
+// de peças, mantendo a mensagem original, de forma que possa ser identificado com errors.Is.
+// Como a biblioteca net/rpc transmite erros apenas como texto, a identificação é feita pela mensagem.
+// Os tokens de continuação malformados são convertidos de volta num *server.PageTokenError, que
+// também é identificado como server.ErrInvalidRequest.
+func serverError(err rpc.ServerError) error {
+	if token := strings.TrimPrefix(string(err), types.ErrInvalidPageToken.Error()+": "); token != string(err) {
+		return &server.PageTokenError{Token: token}
+	}
+	for _, known := range repositoryErrors {
+		if strings.HasPrefix(string(err), known.Error()) {
+			return fmt.Errorf("%w%s", known, strings.TrimPrefix(string(err), known.Error()))
+		}
+	}
+	return err
 }
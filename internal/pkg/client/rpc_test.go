@@ -0,0 +1,496 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"go-rpc/encoding"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/server"
+	"go-rpc/types"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/rpc"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTypedErrors(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("PartRepository", server.NewPartRepositoryServer(new(types.PartRepositoryImpl))); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Accept(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	c := NewPartRepositoryClient(rpc.NewClient(conn), types.NewRemoteRefImpl(host, port, "repo"))
+
+	wheel, err := c.AddPart(types.NewPartImpl("wheel", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	axle := types.NewPartImpl("axle", "")
+	axle.SetSubcomponents([]interfaces.Pair{types.NewPairImpl(wheel, 2)})
+	if _, err := c.AddPart(axle); err != nil {
+		t.Fatal(err)
+	}
+
+	// Os erros do repositório são classificados pela categoria e mantêm o erro original
+	if _, err := c.GetPart("missing"); !errors.Is(err, rpcerror.ErrNotFound) || !errors.Is(err, types.ErrPartNotFound) {
+		t.Errorf("GetPart of a missing part = %v, want ErrNotFound wrapping ErrPartNotFound", err)
+	}
+	if _, err := c.DeletePart(wheel.GetCode(), false); !errors.Is(err, rpcerror.ErrServer) || !errors.Is(err, types.ErrPartInUse) {
+		t.Errorf("DeletePart of a part in use = %v, want ErrServer wrapping ErrPartInUse", err)
+	}
+
+	// A perda da conexão, sem que o servidor volte a aceitar conexões, é devolvida como erro,
+	// sem encerrar o processo
+	l.Close()
+	conn.Close()
+	if _, err := c.GetPart(wheel.GetCode()); !errors.Is(err, rpcerror.ErrConnectionLost) {
+		t.Errorf("GetPart after the connection closed = %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	partRepositoryServer := server.NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	partRepositoryServer.SetAuthorizer(auth.NewAuthorizer(map[string]auth.Role{"reader": auth.RoleReader, "writer": auth.RoleWriter}))
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("PartRepository", partRepositoryServer); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Accept(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	c := NewPartRepositoryClient(rpc.NewClient(conn), types.NewRemoteRefImpl(host, port, "repo"))
+	defer c.Close()
+
+	// Sem token, e com um token apenas de leitura, a inclusão é recusada
+	for _, token := range []string{"", "reader"} {
+		c.SetToken(token)
+		if _, err := c.AddPart(types.NewPartImpl("wheel", "")); !errors.Is(err, rpcerror.ErrPermissionDenied) || !errors.Is(err, auth.ErrPermissionDenied) {
+			t.Errorf("AddPart with token %q = %v, want ErrPermissionDenied", token, err)
+		}
+	}
+
+	c.SetToken("writer")
+	wheel, err := c.AddPart(types.NewPartImpl("wheel", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetToken("reader")
+	if _, err := c.GetPart(wheel.GetCode()); err != nil {
+		t.Errorf("GetPart with a reader token = %v", err)
+	}
+	if _, err := c.DeletePart(wheel.GetCode(), false); !errors.Is(err, rpcerror.ErrPermissionDenied) {
+		t.Errorf("DeletePart with a reader token = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestCallDeadline(t *testing.T) {
+	ref := stalledServer(t)
+	conn, err := net.Dial("tcp", ref.GetAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := NewPartRepositoryClient(rpc.NewClient(conn), ref)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.GetPartContext(ctx, "code"); !errors.Is(err, rpcerror.ErrTimeout) {
+		t.Errorf("GetPart without a reply = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetPart returned after %v, want about 50ms", elapsed)
+	}
+
+	// Um contexto já cancelado não chega a enviar a chamada
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetPartContext(canceled, "code"); !errors.Is(err, rpcerror.ErrCanceled) {
+		t.Errorf("GetPart with a canceled context = %v, want ErrCanceled", err)
+	}
+}
+
+// stalledServer inicia um servidor TCP que aceita as conexões e nunca responde, e retorna a sua
+// referência.
+func stalledServer(t *testing.T) *types.RemoteRefImpl {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return types.NewRemoteRefImpl(host, port, "server1")
+}
+
+func TestClosedClientIsNotRetried(t *testing.T) {
+	ref := stalledServer(t)
+	conn, err := net.Dial("tcp", ref.GetAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPartRepositoryClient(rpc.NewClient(conn), ref)
+	p.SetRetryPolicy("GetPart", RetryPolicy{MaxAttempts: 10, InitialBackoff: 50 * time.Millisecond})
+
+	// Uma chamada em andamento é interrompida pelo encerramento do cliente, sem novas tentativas
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.GetPartContext(context.Background(), "code")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	p.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, rpcerror.ErrCanceled) {
+			t.Errorf("in-flight GetPart = %v, want ErrCanceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight GetPart did not return after Close")
+	}
+
+	start := time.Now()
+	if _, err := p.GetPart("code"); !errors.Is(err, rpcerror.ErrCanceled) || rpcerror.IsConnectionError(err) {
+		t.Errorf("GetPart after Close = %v, want ErrCanceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("GetPart after Close took %v, want no retries", elapsed)
+	}
+}
+
+// Estrutura flakyCodec envolve o codec de uma conexão com o servidor, registrando o prazo de cada
+// chamada recebida e fechando a conexão na primeira delas, como numa perda de conexão.
+type flakyCodec struct {
+	rpc.ServerCodec
+	timeouts chan<- time.Duration // prazos das chamadas recebidas
+	fail     bool                 // se a conexão é fechada na leitura dos argumentos
+}
+
+func (c *flakyCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	if args, ok := body.(server.Args); ok {
+		c.timeouts <- args.GetHeader().Timeout
+	}
+	if c.fail {
+		c.ServerCodec.Close()
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func TestRetrySendsRemainingTimeout(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("PartRepository", server.NewPartRepositoryServer(new(types.PartRepositoryImpl))); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// A primeira conexão é fechada ao receber a chamada, e as seguintes atendem normalmente
+	timeouts := make(chan time.Duration, 2)
+	go func() {
+		for first := true; ; first = false {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go rpcServer.ServeCodec(&flakyCodec{ServerCodec: server.NewGobServerCodec(conn), timeouts: timeouts, fail: first})
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPartRepositoryClient(rpc.NewClient(conn), types.NewRemoteRefImpl(host, port, "server1"))
+	defer p.Close()
+	const backoff = 100 * time.Millisecond
+	p.SetRetryPolicy("GetPart", RetryPolicy{MaxAttempts: 2, InitialBackoff: backoff})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := p.GetPartContext(ctx, "code"); !errors.Is(err, rpcerror.ErrNotFound) {
+		t.Fatalf("GetPart = %v, want ErrNotFound from the second attempt", err)
+	}
+	first, second := <-timeouts, <-timeouts
+	if second > first-backoff {
+		t.Errorf("second attempt timeout = %v, want at most %v", second, first-backoff)
+	}
+}
+
+// startNameServer inicia um serviço de nomes numa porta livre, encerrado ao final do teste, e
+// retorna um cliente conectado a ele.
+func startNameServer(t *testing.T) *naming.NameServerClient {
+	t.Helper()
+	nameServer := naming.NewNameServer("127.0.0.1", "0")
+	if err := nameServer.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nameServer.Close() })
+	host, port, _ := net.SplitHostPort(nameServer.Addr())
+	return naming.NewNameServerClient(host, port)
+}
+
+// startRepository inicia um servidor de repositório de peças numa porta livre, registrado no
+// serviço de nomes com o nome informado, e retorna a função que o encerra abruptamente,
+// fechando as conexões abertas.
+func startRepository(t *testing.T, ns *naming.NameServerClient, name string, repo interfaces.PartRepository) (stop func()) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	partRepositoryServer := server.NewPartRepositoryServer(repo)
+	partRepositoryServer.SetRef(types.NewRemoteRefImpl(host, port, name))
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("PartRepository", partRepositoryServer); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go rpcServer.ServeCodec(server.NewGobServerCodec(conn))
+		}
+	}()
+	token, err := ns.Register(host, port, name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			listener.Close()
+			mu.Lock()
+			for _, conn := range conns {
+				conn.Close()
+			}
+			mu.Unlock()
+			ns.Deregister(name, token)
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestResolverSharesAndEvictsClients(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	ns := startNameServer(t)
+
+	repo := new(types.PartRepositoryImpl)
+	part := types.NewPartImpl("bolt", "m6")
+	part.SetCode("bolt")
+	repo.AddPart(part)
+	stop := startRepository(t, ns, "resolver1", repo)
+
+	r := NewResolver(ns)
+	defer r.Close()
+
+	// As resoluções concorrentes do mesmo repositório compartilham uma única conexão
+	const callers = 8
+	clients := make(chan *PartRepositoryClient, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			c, err := r.Client(context.Background(), "resolver1")
+			if err != nil {
+				t.Error(err)
+			}
+			clients <- c
+		}()
+	}
+	first := <-clients
+	for i := 1; i < callers; i++ {
+		if c := <-clients; c != first {
+			t.Fatal("concurrent Client calls returned different clients")
+		}
+	}
+
+	// O cliente de um repositório que deixou de responder é descartado
+	stop()
+	first.SetRetryPolicy("GetPart", NoRetry)
+	ref := types.NewPairRefImpl("resolver1", "bolt", 1)
+	if _, err := r.ResolveContext(context.Background(), ref); !rpcerror.IsConnectionError(err) {
+		t.Fatalf("Resolve of stopped repository = %v, want ErrConnectionLost", err)
+	}
+	startRepository(t, ns, "resolver1", repo)
+	c, err := r.Client(context.Background(), "resolver1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == first {
+		t.Error("failed client was not evicted")
+	}
+	if got, err := r.ResolveContext(context.Background(), ref); err != nil || got.GetCode() != "bolt" {
+		t.Errorf("Resolve after restart = %v, %v", got, err)
+	}
+}
+
+// slowNameServer inicia um serviço de nomes e um proxy que atrasa cada consulta a ele em delay.
+// Retorna o cliente do serviço de nomes e o cliente do proxy.
+func slowNameServer(t *testing.T, delay time.Duration) (ns *naming.NameServerClient, slow *naming.NameServerClient) {
+	t.Helper()
+	nameServer := naming.NewNameServer("127.0.0.1", "0")
+	if err := nameServer.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nameServer.Close() })
+	host, port, _ := net.SplitHostPort(nameServer.Addr())
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: nameServer.Addr()})
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(delay)
+		proxy.ServeHTTP(w, req)
+	}))
+	t.Cleanup(proxyServer.Close)
+	proxyHost, proxyPort, _ := net.SplitHostPort(proxyServer.Listener.Addr().String())
+	return naming.NewNameServerClient(host, port), naming.NewNameServerClient(proxyHost, proxyPort)
+}
+
+func TestResolverDialIgnoresCanceledCaller(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	ns, slow := slowNameServer(t, 200*time.Millisecond)
+	startRepository(t, ns, "resolver2", new(types.PartRepositoryImpl))
+
+	r := NewResolver(slow)
+	defer r.Close()
+
+	// A segunda chamada aguarda a conexão iniciada pela primeira, que é cancelada em seguida
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := r.Client(ctx, "resolver2")
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	second := make(chan error, 1)
+	go func() {
+		_, err := r.Client(context.Background(), "resolver2")
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-first; !errors.Is(err, rpcerror.ErrCanceled) {
+		t.Errorf("canceled Client = %v, want ErrCanceled", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("Client sharing the canceled caller's dial = %v, want success", err)
+	}
+}
+
+func TestResolverCloseDuringDial(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	ns, slow := slowNameServer(t, 200*time.Millisecond)
+	startRepository(t, ns, "resolver3", new(types.PartRepositoryImpl))
+
+	r := NewResolver(slow)
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Client(context.Background(), "resolver3")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	r.Close()
+
+	// O cliente conectado após o encerramento é fechado, e não incluído no mapa
+	if err := <-done; !errors.Is(err, rpcerror.ErrCanceled) {
+		t.Errorf("Client during Close = %v, want ErrCanceled", err)
+	}
+	r.mu.Lock()
+	n := len(r.clients)
+	r.mu.Unlock()
+	if n != 0 {
+		t.Errorf("resolver holds %d clients after Close, want 0", n)
+	}
+	if _, err := r.Client(context.Background(), "resolver3"); !errors.Is(err, rpcerror.ErrCanceled) {
+		t.Errorf("Client after Close = %v, want ErrCanceled", err)
+	}
+}
+
+func TestResolverEvictKeepsClientUntilReleased(t *testing.T) {
+	ref := stalledServer(t)
+	conn, err := net.Dial("tcp", ref.GetAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPartRepositoryClient(rpc.NewClient(conn), ref)
+	isClosed := func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.closed
+	}
+
+	// O cliente descartado por uma resolução continua disponível para a outra que o utiliza
+	r := NewResolver(nil)
+	c := &resolvedClient{repo: p, users: 2}
+	r.clients["server1"] = c
+	r.evict("server1", c)
+	if _, ok := r.clients["server1"]; ok {
+		t.Error("evicted client is still in the resolver")
+	}
+	r.release(c)
+	if isClosed() {
+		t.Fatal("evicted client was closed while still in use")
+	}
+	r.release(c)
+	if !isClosed() {
+		t.Error("evicted client was not closed after its last use")
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"go-rpc/encoding"
+	"go-rpc/internal/pkg/server"
+	"go-rpc/internal/pkg/trace"
+	"go-rpc/types"
+	"io"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+// spanRecorder é um trace.Exporter que entrega as etapas encerradas num canal.
+type spanRecorder chan *trace.Span
+
+func (r spanRecorder) Export(span *trace.Span) error {
+	r <- span
+	return nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	spans := make(spanRecorder, 16)
+	trace.SetExporter(spans)
+	defer trace.SetExporter(nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("PartRepository", server.NewPartRepositoryServer(new(types.PartRepositoryImpl))); err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeCodec(srv, l, func(conn io.ReadWriteCloser) rpc.ServerCodec {
+		return server.NewTraceServerCodec(server.NewGobServerCodec(conn))
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	c := NewPartRepositoryClient(rpc.NewClient(conn), types.NewRemoteRefImpl(host, port, "repo"))
+	defer c.Close()
+
+	ctx, root := trace.Start(context.Background(), "test")
+	if _, err := c.AddPartContext(ctx, types.NewPartImpl("bolt", "")); err != nil {
+		t.Fatal(err)
+	}
+	root.End(nil)
+
+	// A chamada do cliente é filha da etapa do contexto, e o atendimento no servidor é filho dela
+	byParent := make(map[string]*trace.Span)
+	for len(byParent) < 3 {
+		select {
+		case span := <-spans:
+			byParent[span.ParentID] = span
+		case <-time.After(5 * time.Second):
+			t.Fatalf("spans = %v, want the root, the client call and the server call", byParent)
+		}
+	}
+	call := byParent[root.SpanID]
+	if call == nil || call.TraceID != root.TraceID || call.Name != "PartRepository.AddPart" {
+		t.Fatalf("client call span = %v, want a child of %v", call, root)
+	}
+	if handled := byParent[call.SpanID]; handled == nil || handled.TraceID != root.TraceID || handled.Name != "PartRepository.AddPart" {
+		t.Errorf("server call span = %v, want a child of %v", handled, call)
+	}
+}
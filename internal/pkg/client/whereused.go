@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/trace"
+)
+
+// Estrutura Usage representa uma peça que utiliza, direta ou indiretamente, a peça consultada.
+type Usage struct {
+	Part  interfaces.Part // peça que utiliza a peça consultada
+	Level int             // nível do uso: 1 para uso direto, 2 para o uso por uma peça de nível 1, e assim por diante
+}
+
+// Estrutura WhereUsedReport representa o resultado de uma consulta de uso a todos os repositórios.
+type WhereUsedReport struct {
+	Usages   []Usage          // peças que utilizam a peça consultada, ordenadas pelo nível e pelo repositório
+	Failures map[string]error // erros dos repositórios que não puderam ser consultados, indexados pelo nome
+}
+
+// WhereUsed consulta, em todos os repositórios registrados no serviço de nomes, as peças que
+// utilizam a peça com o código informado como subcomponente direto ou, caso recursive seja
+// verdadeiro, direta ou indiretamente, inclusive através de peças de outros repositórios.
+// Os repositórios que não puderem ser consultados são ignorados e reportados em Failures.
+// Retorna um erro apenas caso a lista de repositórios não possa ser obtida.
+func (r *Resolver) WhereUsed(code string, recursive bool) (*WhereUsedReport, error) {
+	return r.WhereUsedContext(context.Background(), code, recursive)
+}
+
+// WhereUsedContext é a variante de WhereUsed que recebe um contexto.
+// A consulta é uma etapa do trace do contexto (ver o pacote trace), da qual as consultas a cada
+// repositório são etapas filhas.
+func (r *Resolver) WhereUsedContext(ctx context.Context, code string, recursive bool) (report *WhereUsedReport, err error) {
+	ctx, span := trace.Start(ctx, "WhereUsed")
+	span.SetAttribute("code", code)
+	defer func() { span.End(err) }()
+
+	refs, err := r.ns.ListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Instâncias de um mesmo repositório são consultadas uma única vez
+	var names []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if !seen[ref.GetName()] {
+			seen[ref.GetName()] = true
+			names = append(names, ref.GetName())
+		}
+	}
+
+	// Consulta os usos diretos nível a nível, de forma que os usos através de peças de outros
+	// repositórios também sejam encontrados
+	report = &WhereUsedReport{Failures: make(map[string]error)}
+	visited := map[string]bool{code: true}
+	frontier := []string{code}
+	for level := 1; len(frontier) > 0; level++ {
+		var next []string
+		for _, name := range names {
+			if report.Failures[name] != nil {
+				continue
+			}
+			rc, err := r.acquire(ctx, name)
+			if err != nil {
+				report.Failures[name] = err
+				continue
+			}
+
+			for _, c := range frontier {
+				parts, err := rc.repo.WhereUsedContext(ctx, c, false)
+				if err != nil {
+					if rpcerror.IsConnectionError(err) {
+						r.evict(name, rc)
+					}
+					report.Failures[name] = err
+					break
+				}
+				for _, part := range parts {
+					if !visited[part.GetCode()] {
+						visited[part.GetCode()] = true
+						report.Usages = append(report.Usages, Usage{Part: part, Level: level})
+						next = append(next, part.GetCode())
+					}
+				}
+			}
+			r.release(rc)
+		}
+
+		if !recursive {
+			break
+		}
+		frontier = next
+	}
+	return report, nil
+}
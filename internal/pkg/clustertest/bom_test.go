@@ -0,0 +1,110 @@
+package clustertest
+
+import (
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/types"
+	"testing"
+	"time"
+)
+
+// addPart adiciona ao nó uma peça com o nome e os subcomponentes informados, através do seu
+// cliente, e retorna a peça armazenada.
+func addPart(t *testing.T, node *Node, name string, subs ...interfaces.Pair) interfaces.Part {
+	t.Helper()
+	part := types.NewPartImpl(name, "")
+	part.SetSubcomponents(subs)
+	added, err := node.Client().AddPart(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return added
+}
+
+// ref retorna o par que referencia quantity unidades da peça part, no repositório que a contém.
+func ref(part interfaces.Part, quantity int) interfaces.Pair {
+	return types.NewPairRefImpl(part.GetRepositoryName(), part.GetCode(), quantity)
+}
+
+func TestExplodeBOMAcrossRepositories(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 2})
+	server1, server2 := c.Node(0), c.Node(1)
+
+	// wheel, de server2, é utilizada por axle, de server1, e por frame, de server2:
+	// cart = 2 axle + 1 frame; axle = 2 wheel; frame = 3 wheel + 5 bolt
+	wheel := addPart(t, server2, "wheel")
+	bolt := addPart(t, server2, "bolt")
+	frame := addPart(t, server2, "frame", ref(wheel, 3), ref(bolt, 5))
+	axle := addPart(t, server1, "axle", ref(wheel, 2))
+	cart := addPart(t, server1, "cart", ref(axle, 2), ref(frame, 1))
+
+	bom, err := server1.Client().ExplodeBOM(cart.GetCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As quantidades são multiplicadas ao longo de cada caminho
+	want := []struct {
+		depth           int
+		name            string
+		quantity, total int
+	}{
+		{0, "cart", 1, 1},
+		{1, "axle", 2, 2},
+		{2, "wheel", 2, 4},
+		{1, "frame", 1, 1},
+		{2, "wheel", 3, 3},
+		{2, "bolt", 5, 5},
+	}
+	if len(bom.Lines) != len(want) {
+		t.Fatalf("BOM has %d lines, want %d:\n%s", len(bom.Lines), len(want), bom.Report())
+	}
+	for i, w := range want {
+		line := bom.Lines[i]
+		if line.Depth != w.depth || line.Part.GetName() != w.name || line.Quantity != w.quantity || line.Total != w.total {
+			t.Errorf("line %d = depth %d %s x%d (total %d), want depth %d %s x%d (total %d)",
+				i, line.Depth, line.Part.GetName(), line.Quantity, line.Total, w.depth, w.name, w.quantity, w.total)
+		}
+	}
+
+	// O resumo soma as quantidades das peças primitivas de todos os caminhos
+	totals := map[string]int{}
+	for _, item := range bom.Primitives {
+		totals[item.Part.GetName()] = item.Quantity
+	}
+	if len(bom.Primitives) != 2 || totals["wheel"] != 7 || totals["bolt"] != 5 {
+		t.Errorf("primitive totals = %v, want wheel 7 and bolt 5", totals)
+	}
+}
+
+func TestExplodeBOMReportsCycle(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 2})
+	server1, server2 := c.Node(0), c.Node(1)
+
+	// a, de server1, utiliza b, de server2, que utiliza a. Como a validação dos servidores recusa o
+	// ciclo, ele é criado diretamente no repositório de server1, como num estado anterior à validação
+	a := addPart(t, server1, "a")
+	b := addPart(t, server2, "b", ref(a, 1))
+	cyclic := types.NewPartImpl("a", "")
+	cyclic.SetCode(a.GetCode())
+	cyclic.SetRef(types.NewRemoteRefImpl(host, "0", server1.Name()))
+	cyclic.SetSubcomponents([]interfaces.Pair{ref(b, 1)})
+	if err := server1.Repository().UpdatePart(cyclic); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := server1.Client().ExplodeBOM(a.GetCode())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, client.ErrCycle) {
+			t.Errorf("ExplodeBOM of a cyclic part = %v, want ErrCycle", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExplodeBOM of a cyclic part did not return")
+	}
+}
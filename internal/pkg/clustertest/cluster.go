@@ -0,0 +1,321 @@
+// O pacote clustertest fornece um cluster completo, executado num único processo, para testes de
+// integração: um serviço de nomes e vários servidores de repositório de peças escutando em portas
+// livres do endereço de loopback, acompanhados de clientes já conectados. Cada nó pode ser
+// encerrado abruptamente, reiniciado ou isolado da rede, para exercitar a recuperação de falhas
+// dos clientes sem executar os binários manualmente.
+//
+// Exemplo de uso num teste:
+//
+//	c, err := clustertest.Start(ctx, clustertest.Config{Nodes: 3})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer c.Close()
+//	c.Node(0).Client().AddPart(part)
+//	c.Node(1).Partition()
+package clustertest
+
+import (
+	"context"
+	"fmt"
+	"go-rpc/encoding"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/service"
+	"go-rpc/types"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Valores-padrão da configuração de um cluster, menores que os dos executáveis para que os
+// registros de nós encerrados abruptamente expirem e os encerramentos terminem rapidamente.
+const (
+	DefaultTTL             = 3 * time.Second
+	DefaultShutdownTimeout = time.Second
+)
+
+// host é o endereço de loopback em que todos os servidores do cluster escutam.
+const host = "127.0.0.1"
+
+// Estrutura Config representa a configuração de um cluster.
+type Config struct {
+	Nodes           int           // número de repositórios, nomeados "server1", "server2" etc.; ignorado caso Names seja informado
+	Names           []string      // nomes dos repositórios, um por nó; nomes repetidos formam instâncias de um mesmo repositório
+	TTL             time.Duration // tempo de validade dos registros no serviço de nomes; zero para DefaultTTL
+	ShutdownTimeout time.Duration // tempo máximo de espera pelas chamadas em atendimento no encerramento de um nó; zero para DefaultShutdownTimeout
+}
+
+// Estrutura Cluster representa um serviço de nomes e os nós de repositório de peças registrados
+// nele, executados no processo corrente. O cluster é encerrado com Close.
+type Cluster struct {
+	cfg        Config                   // configuração do cluster
+	nameServer *naming.NameServer       // serviço de nomes
+	nsclient   *naming.NameServerClient // cliente do serviço de nomes
+	nodes      []*Node                  // nós do cluster, na ordem da configuração
+}
+
+// Start inicia o serviço de nomes e os nós do cluster, aguardando o registro de todos eles, e
+// conecta um cliente a cada nó. Os tipos concretos das peças são registrados no gob (ver
+// encoding.RegisterConcreteTypes). O cancelamento do contexto ctx encerra os servidores do
+// cluster; Close também fecha os clientes. Caso algum servidor não possa ser iniciado, os já
+// iniciados são encerrados e o erro é devolvido.
+func Start(ctx context.Context, cfg Config) (c *Cluster, err error) {
+	encoding.RegisterConcreteTypes()
+	if len(cfg.Names) == 0 {
+		for i := 1; i <= cfg.Nodes; i++ {
+			cfg.Names = append(cfg.Names, "server"+strconv.Itoa(i))
+		}
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	c = &Cluster{cfg: cfg, nameServer: naming.NewNameServer(host, "0")}
+	c.nameServer.SetLeaseTTL(cfg.TTL)
+	if err := c.nameServer.Start(ctx); err != nil {
+		return nil, err
+	}
+	// Os retornos com erro anulam c, por isso o cluster a ser encerrado é guardado à parte
+	started := c
+	defer func() {
+		if err != nil {
+			started.Close()
+		}
+	}()
+
+	_, nsPort, _ := net.SplitHostPort(c.nameServer.Addr())
+	c.nsclient = naming.NewNameServerClient(host, nsPort)
+	// Os nós de mesmo nome são registrados como réplicas de um mesmo repositório
+	c.nsclient.SetReplicaKey(uuid.New().String())
+
+	for _, name := range cfg.Names {
+		node := &Node{cluster: c, name: name, repository: new(types.PartRepositoryImpl)}
+		c.nodes = append(c.nodes, node)
+		if err := node.start(ctx); err != nil {
+			return nil, fmt.Errorf("failed to start %s: %w", name, err)
+		}
+	}
+	for _, node := range c.nodes {
+		if node.client, err = client.DialContext(ctx, c.nsclient, node.name); err != nil {
+			return nil, fmt.Errorf("failed to dial %s: %w", node.name, err)
+		}
+	}
+	return c, nil
+}
+
+// NameServer retorna o serviço de nomes do cluster.
+func (c *Cluster) NameServer() *naming.NameServer {
+	return c.nameServer
+}
+
+// NameServerClient retorna um cliente do serviço de nomes do cluster, utilizado para conectar
+// outros clientes (ver client.Dial e client.NewResolver).
+func (c *Cluster) NameServerClient() *naming.NameServerClient {
+	return c.nsclient
+}
+
+// Node retorna o i-ésimo nó do cluster, a partir de zero.
+func (c *Cluster) Node(i int) *Node {
+	return c.nodes[i]
+}
+
+// Nodes retorna os nós do cluster, na ordem da configuração.
+func (c *Cluster) Nodes() []*Node {
+	return c.nodes
+}
+
+// Close fecha os clientes, encerra gradualmente os nós em execução e, por fim, o serviço de nomes.
+// Retorna o primeiro erro dos encerramentos.
+func (c *Cluster) Close() error {
+	var errs []error
+	for _, node := range c.nodes {
+		if node.client != nil {
+			node.client.Close()
+		}
+		if err := node.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.nameServer.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Estrutura Node representa um nó do cluster: um servidor de repositório de peças e o proxy à
+// sua frente, cujo endereço é o registrado no serviço de nomes (ver Partition e Blackhole).
+// Cada execução do nó escuta em novas portas, como um processo reiniciado, e o repositório de
+// peças é preservado entre as execuções, como num armazenamento persistente.
+// A estrutura é segura para uso concorrente.
+type Node struct {
+	cluster     *Cluster                     // cluster ao qual o nó pertence
+	name        string                       // nome registrado no serviço de nomes
+	repository  interfaces.PartRepository    // repositório de peças, compartilhado pelas execuções do nó
+	client      *client.PartRepositoryClient // cliente conectado ao nó pelo nome
+	mu          sync.Mutex                   // protege a execução corrente e o estado da rede
+	server      *service.Server              // servidor da execução corrente, nulo caso o nó esteja parado
+	proxy       *proxy                       // proxy da execução corrente
+	partitioned bool                         // se o nó está isolado
+	blackholed  bool                         // se o nó deixou de responder sem fechar as conexões
+}
+
+// Name retorna o nome com que o nó é registrado no serviço de nomes.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Client retorna o cliente conectado ao nó pelo seu nome no serviço de nomes, criado por Start.
+// Como os demais clientes criados com client.Dial, ele se reconecta após uma reinicialização do
+// nó, ou a outra instância de mesmo nome.
+func (n *Node) Client() *client.PartRepositoryClient {
+	return n.client
+}
+
+// Repository retorna o repositório de peças do nó, que pode ser inspecionado diretamente.
+func (n *Node) Repository() interfaces.PartRepository {
+	return n.repository
+}
+
+// Server retorna o servidor da execução corrente do nó, ou nulo caso o nó esteja parado.
+func (n *Node) Server() *service.Server {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.server
+}
+
+// Addr retorna o endereço registrado no serviço de nomes pela execução corrente do nó, ou uma
+// string vazia caso o nó esteja parado.
+func (n *Node) Addr() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.server == nil {
+		return ""
+	}
+	return n.proxy.listener.Addr().String()
+}
+
+// Running retorna true caso o nó esteja em execução.
+func (n *Node) Running() bool {
+	return n.Server() != nil
+}
+
+// Kill encerra o nó abruptamente, simulando a falha do processo: as conexões são fechadas sem
+// resposta e o registro permanece no serviço de nomes até expirar (ver service.Server.Abort).
+// Não faz nada caso o nó esteja parado.
+func (n *Node) Kill() error {
+	return n.terminate(false)
+}
+
+// Stop encerra o nó gradualmente, removendo o seu registro do serviço de nomes e aguardando as
+// chamadas em atendimento (ver service.Server.Close). Não faz nada caso o nó esteja parado.
+func (n *Node) Stop() error {
+	return n.terminate(true)
+}
+
+// Restart encerra o nó gradualmente, caso esteja em execução, e o inicia novamente em novas
+// portas, com o mesmo nome e o mesmo repositório de peças. O isolamento e a retenção dos bytes do
+// nó são mantidos.
+func (n *Node) Restart(ctx context.Context) error {
+	if err := n.Stop(); err != nil {
+		return err
+	}
+	return n.start(ctx)
+}
+
+// Partition isola o nó da rede: as conexões abertas com ele são fechadas e as novas são
+// recusadas, tanto as dos clientes quanto as dos outros repositórios. O nó continua renovando
+// o seu registro, de forma que o serviço de nomes ainda o anuncia, e as suas próprias chamadas
+// aos outros repositórios não são afetadas.
+func (n *Node) Partition() {
+	n.setNetwork(true, false)
+}
+
+// Blackhole faz com que o nó deixe de responder sem fechar as conexões, como numa rede que descarta
+// os pacotes: os bytes enviados ao nó e as suas respostas deixam de ser repassados, e as novas
+// conexões são aceitas, mas também não são atendidas. Ao contrário de Partition, os clientes não
+// percebem a falha como uma perda de conexão, e as suas chamadas falham apenas quando o prazo vence,
+// com um erro da categoria rpcerror.ErrTimeout. Assim como em Partition, o nó continua renovando o
+// seu registro.
+func (n *Node) Blackhole() {
+	n.setNetwork(false, true)
+}
+
+// Heal desfaz o isolamento do nó, voltando a aceitar conexões, ou a retenção dos seus bytes,
+// entregando os bytes retidos.
+func (n *Node) Heal() {
+	n.setNetwork(false, false)
+}
+
+// setNetwork altera o estado da rede do nó e o aplica ao proxy da execução corrente.
+func (n *Node) setNetwork(partitioned bool, blackholed bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.partitioned, n.blackholed = partitioned, blackholed
+	if n.proxy != nil {
+		n.proxy.setState(partitioned, blackholed)
+	}
+}
+
+// start inicia uma nova execução do nó: o proxy começa a escutar numa porta livre, anunciada ao
+// serviço de nomes pelo servidor, e passa a repassar as conexões assim que o servidor é iniciado.
+// Retorna um erro caso o nó já esteja em execução.
+func (n *Node) start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.server != nil {
+		return fmt.Errorf("node %s already running", n.name)
+	}
+
+	p, err := newProxy(host, n.partitioned, n.blackholed)
+	if err != nil {
+		return err
+	}
+	srv := service.NewServer(service.Config{
+		Host:            host,
+		Port:            "0",
+		AdvertisePort:   p.port(),
+		Name:            n.name,
+		NameServer:      n.cluster.nsclient,
+		TTL:             n.cluster.cfg.TTL,
+		Repository:      n.repository,
+		ShutdownTimeout: n.cluster.cfg.ShutdownTimeout,
+	})
+	if err := srv.Start(ctx); err != nil {
+		p.close()
+		return err
+	}
+	p.serve(srv.Addr())
+	n.server, n.proxy = srv, p
+	return nil
+}
+
+// terminate encerra a execução corrente do nó, gradualmente ou não, e fecha o seu proxy.
+func (n *Node) terminate(graceful bool) error {
+	n.mu.Lock()
+	srv, p := n.server, n.proxy
+	n.server, n.proxy = nil, nil
+	n.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+
+	if !graceful {
+		// As conexões com o nó são interrompidas antes do encerramento do servidor, como numa falha de rede
+		p.close()
+		return srv.Abort()
+	}
+	err := srv.Close()
+	p.close()
+	return err
+}
@@ -0,0 +1,116 @@
+package clustertest
+
+import (
+	"context"
+	"errors"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/types"
+	"testing"
+	"time"
+)
+
+// startCluster inicia um cluster com a configuração cfg, encerrado ao final do teste.
+func startCluster(t *testing.T, cfg Config) *Cluster {
+	t.Helper()
+	c, err := Start(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientFailsOverAfterKill(t *testing.T) {
+	c := startCluster(t, Config{Names: []string{"repo", "repo"}})
+	client := c.Node(0).Client()
+
+	// O cliente está conectado à instância registrada primeiro
+	var victim, survivor *Node
+	for _, node := range c.Nodes() {
+		if node.Addr() == client.GetRef().GetAddress() {
+			victim = node
+		} else {
+			survivor = node
+		}
+	}
+	if victim == nil || survivor == nil {
+		t.Fatalf("client connected to %s, not to a single node", client.GetRef().GetAddress())
+	}
+	part := types.NewPartImpl("bolt", "m6")
+	part.SetCode("bolt")
+	if err := survivor.Repository().AddPart(part); err != nil {
+		t.Fatal(err)
+	}
+
+	// O registro da instância encerrada permanece no serviço de nomes, mas o cliente recorre à outra
+	if err := victim.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetPart("bolt")
+	if err != nil {
+		t.Fatalf("GetPart after kill = %v", err)
+	}
+	if got.GetCode() != "bolt" || client.GetRef().GetAddress() != survivor.Addr() {
+		t.Errorf("GetPart after kill = %s from %s, want bolt from %s", got.GetCode(), client.GetRef().GetAddress(), survivor.Addr())
+	}
+}
+
+func TestRestartedNodeKeepsParts(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 1})
+	node := c.Node(0)
+	added, err := node.Client().AddPart(types.NewPartImpl("bolt", "m6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := node.Addr()
+	if err := node.Restart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if node.Addr() == before {
+		t.Errorf("restarted node kept address %s", before)
+	}
+	if _, err := node.Client().GetPart(added.GetCode()); err != nil {
+		t.Errorf("GetPart after restart = %v", err)
+	}
+}
+
+func TestBlackholedNodeTimesOut(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 1})
+	node := c.Node(0)
+	if _, err := node.Client().GetParts(); err != nil {
+		t.Fatal(err)
+	}
+
+	// As chamadas a um nó que não responde falham pelo prazo, e não por perda de conexão
+	node.Blackhole()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := node.Client().GetPartsContext(ctx); !errors.Is(err, rpcerror.ErrTimeout) {
+		t.Fatalf("GetParts on blackholed node = %v, want ErrTimeout", err)
+	}
+
+	// Os bytes retidos são entregues, e a mesma conexão volta a ser utilizada
+	node.Heal()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := node.Client().GetPartsContext(ctx); err != nil {
+		t.Errorf("GetParts after heal = %v", err)
+	}
+}
+
+func TestPartitionedNodeRefusesConnections(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 1})
+	node := c.Node(0)
+	node.Client().SetRetryPolicy("GetParts", client.NoRetry)
+
+	node.Partition()
+	if _, err := node.Client().GetParts(); !rpcerror.IsConnectionError(err) {
+		t.Fatalf("GetParts on partitioned node = %v, want ErrConnectionLost", err)
+	}
+	node.Heal()
+	if _, err := node.Client().GetParts(); err != nil {
+		t.Errorf("GetParts after heal = %v", err)
+	}
+}
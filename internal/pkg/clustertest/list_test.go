@@ -0,0 +1,77 @@
+package clustertest
+
+import (
+	"errors"
+	"go-rpc/internal/pkg/server"
+	"go-rpc/types"
+	"testing"
+)
+
+func TestListPartsWhileWriting(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 1})
+	node := c.Node(0)
+	repo := node.Client()
+
+	const total, pageSize = 10, 3
+	for i := 0; i < total; i++ {
+		addPart(t, node, "bolt")
+	}
+	initial := make(map[string]bool)
+	for _, part := range node.Repository().GetParts() {
+		initial[part.GetCode()] = true
+	}
+
+	// Entre as páginas, uma peça é adicionada e uma das peças ainda não listadas é removida
+	seen := make(map[string]bool)
+	deleted := make(map[string]bool)
+	last, token := "", ""
+	for pages := 1; ; pages++ {
+		parts, next, err := repo.ListParts(pageSize, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, part := range parts {
+			code := part.GetCode()
+			if seen[code] || code <= last {
+				t.Fatalf("page %d repeats or reorders part %s", pages, code)
+			}
+			if deleted[code] {
+				t.Fatalf("page %d lists deleted part %s", pages, code)
+			}
+			seen[code], last = true, code
+		}
+		if next == "" {
+			break
+		}
+		token = next
+
+		addPart(t, node, "nut")
+		for code := range initial {
+			if code > last && !deleted[code] {
+				if _, err := repo.DeletePart(code, false); err != nil {
+					t.Fatal(err)
+				}
+				deleted[code] = true
+				break
+			}
+		}
+	}
+
+	// Todas as peças presentes do início ao fim da listagem são listadas
+	for code := range initial {
+		if !deleted[code] && !seen[code] {
+			t.Errorf("part %s was skipped", code)
+		}
+	}
+	if len(deleted) == 0 {
+		t.Error("no part was deleted between pages")
+	}
+}
+
+func TestListPartsRejectsMalformedToken(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 1})
+	_, _, err := c.Node(0).Client().ListParts(3, "!!!")
+	if !errors.Is(err, server.ErrInvalidRequest) || !errors.Is(err, types.ErrInvalidPageToken) {
+		t.Errorf("ListParts with a malformed token = %v, want ErrInvalidRequest and ErrInvalidPageToken", err)
+	}
+}
@@ -0,0 +1,181 @@
+package clustertest
+
+import (
+	"net"
+	"sync"
+)
+
+// Estrutura proxy representa um proxy TCP posicionado à frente do servidor RPC de um nó, cujo
+// endereço é o registrado no serviço de nomes. Ele repassa os bytes de cada conexão ao servidor
+// e permite isolar o nó, recusando as conexões dos clientes e dos outros repositórios, ou retê-las,
+// deixando de repassar os bytes sem fechar as conexões.
+// A estrutura é segura para uso concorrente.
+type proxy struct {
+	listener    net.Listener          // listener do endereço anunciado
+	target      string                // endereço do servidor RPC do nó
+	mu          sync.Mutex            // protege as conexões e o estado do proxy
+	resumed     *sync.Cond            // sinaliza o fim da retenção dos bytes, associado a mu
+	conns       map[net.Conn]struct{} // conexões abertas, de ambos os lados
+	partitioned bool                  // se as conexões são recusadas
+	blackholed  bool                  // se os bytes das conexões são retidos
+	closed      bool                  // se o proxy foi fechado
+}
+
+// newProxy começa a escutar por conexões numa porta livre do host informado e retorna o ponteiro
+// para uma estrutura proxy, que só as aceita a partir da chamada de serve.
+func newProxy(host string, partitioned bool, blackholed bool) (*proxy, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	p := &proxy{listener: listener, conns: make(map[net.Conn]struct{}), partitioned: partitioned, blackholed: blackholed}
+	p.resumed = sync.NewCond(&p.mu)
+	return p, nil
+}
+
+// port retorna a porta em que o proxy escuta.
+func (p *proxy) port() string {
+	_, port, _ := net.SplitHostPort(p.listener.Addr().String())
+	return port
+}
+
+// serve começa a aceitar conexões em segundo plano e a repassá-las ao endereço target. As
+// conexões recebidas antes disso aguardam na fila do listener.
+func (p *proxy) serve(target string) {
+	p.target = target
+	go func() {
+		for {
+			conn, err := p.listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.handle(conn)
+		}
+	}()
+}
+
+// handle conecta a conexão conn ao servidor e copia os bytes em ambos os sentidos, até que um dos
+// lados a feche. Enquanto o nó estiver isolado, a conexão é fechada imediatamente, de forma que os
+// clientes percebam a falha sem aguardar timeouts.
+func (p *proxy) handle(conn net.Conn) {
+	if !p.track(conn) {
+		conn.Close()
+		return
+	}
+	defer p.untrack(conn)
+
+	backend, err := net.Dial("tcp", p.target)
+	if err != nil {
+		return
+	}
+	if !p.track(backend) {
+		backend.Close()
+		return
+	}
+	defer p.untrack(backend)
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst net.Conn, src net.Conn) {
+		p.copy(dst, src)
+		done <- struct{}{}
+	}
+	go pipe(backend, conn)
+	go pipe(conn, backend)
+
+	// O término de um dos sentidos encerra a conexão inteira, como numa falha de rede
+	<-done
+}
+
+// copy copia os bytes de src para dst até que um dos lados seja fechado. Enquanto os bytes forem
+// retidos, os bytes lidos aguardam o fim da retenção, e os seguintes permanecem nos buffers do
+// sistema operacional, de forma que o fluxo seja retomado intacto, como numa rede que descartou os
+// pacotes por algum tempo e cujas retransmissões voltaram a ser entregues.
+func (p *proxy) copy(dst net.Conn, src net.Conn) {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if !p.wait() {
+				return
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait aguarda o fim da retenção dos bytes. Retorna false caso o proxy seja isolado ou fechado.
+func (p *proxy) wait() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.blackholed && !p.partitioned && !p.closed {
+		p.resumed.Wait()
+	}
+	return !p.partitioned && !p.closed
+}
+
+// track passa a acompanhar a conexão conn. Retorna false, sem acompanhá-la, caso o proxy esteja
+// isolado ou fechado.
+func (p *proxy) track(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.partitioned || p.closed {
+		return false
+	}
+	p.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack fecha a conexão conn e deixa de acompanhá-la.
+func (p *proxy) untrack(conn net.Conn) {
+	p.mu.Lock()
+	delete(p.conns, conn)
+	p.mu.Unlock()
+	conn.Close()
+}
+
+// setState isola o nó, fechando as conexões abertas e recusando as novas, retém os bytes das
+// conexões, ou restabelece o repasse das conexões.
+func (p *proxy) setState(partitioned bool, blackholed bool) {
+	p.mu.Lock()
+	p.partitioned, p.blackholed = partitioned, blackholed
+	conns := p.drop()
+	p.resumed.Broadcast()
+	p.mu.Unlock()
+	closeAll(conns)
+}
+
+// close deixa de aceitar conexões e fecha as conexões abertas.
+func (p *proxy) close() {
+	p.listener.Close()
+	p.mu.Lock()
+	p.closed = true
+	conns := p.drop()
+	p.resumed.Broadcast()
+	p.mu.Unlock()
+	closeAll(conns)
+}
+
+// drop retorna as conexões abertas caso o proxy esteja isolado ou fechado, para que sejam
+// fechadas fora do mutex. Deve ser chamada com o mutex adquirido.
+func (p *proxy) drop() []net.Conn {
+	if !p.partitioned && !p.closed {
+		return nil
+	}
+	conns := make([]net.Conn, 0, len(p.conns))
+	for conn := range p.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// closeAll fecha as conexões conns.
+func closeAll(conns []net.Conn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
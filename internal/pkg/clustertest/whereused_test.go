@@ -0,0 +1,47 @@
+package clustertest
+
+import (
+	"go-rpc/internal/pkg/client"
+	"testing"
+)
+
+func TestWhereUsedAcrossRepositories(t *testing.T) {
+	c := startCluster(t, Config{Nodes: 2})
+	server1, server2 := c.Node(0), c.Node(1)
+
+	// wheel, de server1, é utilizada por axle, de server2, que é utilizada por cart, de server1
+	wheel := addPart(t, server1, "wheel")
+	axle := addPart(t, server2, "axle", ref(wheel, 2))
+	cart := addPart(t, server1, "cart", ref(axle, 2))
+	addPart(t, server2, "bolt")
+
+	r := client.NewResolver(c.NameServerClient())
+	defer r.Close()
+
+	tests := []struct {
+		recursive bool
+		want      []client.Usage
+	}{
+		{false, []client.Usage{{Part: axle, Level: 1}}},
+		{true, []client.Usage{{Part: axle, Level: 1}, {Part: cart, Level: 2}}},
+	}
+	for _, tt := range tests {
+		report, err := r.WhereUsed(wheel.GetCode(), tt.recursive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Failures) != 0 {
+			t.Errorf("WhereUsed(recursive=%v) failures = %v", tt.recursive, report.Failures)
+		}
+		if len(report.Usages) != len(tt.want) {
+			t.Fatalf("WhereUsed(recursive=%v) = %d usages, want %d", tt.recursive, len(report.Usages), len(tt.want))
+		}
+		for i, want := range tt.want {
+			got := report.Usages[i]
+			if got.Part.GetCode() != want.Part.GetCode() || got.Level != want.Level {
+				t.Errorf("WhereUsed(recursive=%v) usage %d = %s at level %d, want %s at level %d",
+					tt.recursive, i, got.Part.GetName(), got.Level, want.Part.GetName(), want.Level)
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// O pacote metrics fornece contadores, histogramas e medidores (gauges) exportados no formato
+// de texto do Prometheus, utilizados pelos servidores de repositório e pelo serviço de nomes.
+//
+// O pacote implementa apenas o necessário para a exposição das métricas destes servidores, sem
+// depender da biblioteca cliente do Prometheus: as métricas são registradas numa estrutura
+// Registry, servida por Registry.Handler numa rota HTTP (tipicamente /metrics).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets são os limites superiores, em segundos, dos intervalos padrão dos histogramas
+// de latência.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Interface collector define uma métrica registrada numa estrutura Registry.
+type collector interface {
+	// write escreve a métrica no formato de texto do Prometheus.
+	write(w *bufio.Writer)
+}
+
+// Estrutura Registry representa um conjunto de métricas, exportadas na ordem de registro.
+// A estrutura é segura para uso concorrente.
+type Registry struct {
+	mu      sync.Mutex  // protege a lista de métricas
+	metrics []collector // métricas registradas
+}
+
+// NewRegistry retorna o ponteiro para uma estrutura Registry vazia.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register inclui a métrica m no conjunto.
+func (r *Registry) register(m collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo escreve todas as métricas em w no formato de texto do Prometheus.
+// Implementa a interface io.WriterTo.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]collector(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler retorna um http.Handler que responde com todas as métricas no formato de texto do Prometheus.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := r.WriteTo(w); err != nil {
+			log.Println("[!] Failed to write metrics:", err)
+		}
+	})
+}
+
+// Estrutura desc representa o nome, a descrição e os nomes dos rótulos de uma métrica.
+type desc struct {
+	name   string   // nome da métrica
+	help   string   // descrição da métrica
+	kind   string   // tipo da métrica: counter, gauge ou histogram
+	labels []string // nomes dos rótulos
+}
+
+// writeHeader escreve as linhas HELP e TYPE da métrica.
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// labelPairs retorna os rótulos da série com os valores values, no formato nome="valor",
+// acrescidos dos pares extra.
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	var pairs []string
+	for i, name := range d.labels {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	pairs = append(pairs, extra...)
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// key retorna a chave que identifica a série com os valores de rótulo values, verificando o
+// número de valores.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Estrutura CounterVec representa um contador, com uma série para cada combinação de valores
+// dos seus rótulos.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex          // protege as séries
+	series map[string]*counter // séries, indexadas pela chave dos valores dos rótulos
+}
+
+// Estrutura counter representa uma série de um contador.
+type counter struct {
+	values []string // valores dos rótulos
+	value  float64  // valor do contador
+}
+
+// NewCounterVec registra e retorna um contador com os rótulos informados.
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, kind: "counter", labels: labels}, series: make(map[string]*counter)}
+	r.register(c)
+	return c
+}
+
+// Inc incrementa a série com os valores de rótulo informados.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add soma v, que não deve ser negativo, à série com os valores de rótulo informados.
+func (c *CounterVec) Add(v float64, values ...string) {
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counter{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// write escreve o contador no formato de texto do Prometheus, com as séries ordenadas pelos rótulos.
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(s.values), formatFloat(s.value))
+	}
+}
+
+// Estrutura HistogramVec representa um histograma, com uma série para cada combinação de valores
+// dos seus rótulos.
+type HistogramVec struct {
+	desc
+	buckets []float64             // limites superiores dos intervalos, em ordem crescente
+	mu      sync.Mutex            // protege as séries
+	series  map[string]*histogram // séries, indexadas pela chave dos valores dos rótulos
+}
+
+// Estrutura histogram representa uma série de um histograma.
+type histogram struct {
+	values []string // valores dos rótulos
+	counts []uint64 // número de observações em cada intervalo (não cumulativo)
+	count  uint64   // número total de observações
+	sum    float64  // soma das observações
+}
+
+// NewHistogramVec registra e retorna um histograma com os intervalos e os rótulos informados.
+// Caso buckets seja nulo, utiliza DefaultBuckets.
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe inclui a observação v na série com os valores de rótulo informados.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// write escreve o histograma no formato de texto do Prometheus, com os intervalos cumulativos.
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.values, `le="`+formatFloat(le)+`"`), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.values, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(s.values), s.count)
+	}
+}
+
+// Estrutura GaugeFunc representa um medidor sem rótulos cujo valor é obtido no momento da exportação.
+type GaugeFunc struct {
+	desc
+	value func() float64 // função que retorna o valor atual do medidor
+}
+
+// NewGaugeFunc registra e retorna um medidor cujo valor é obtido pela função value a cada exportação.
+func (r *Registry) NewGaugeFunc(name string, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}, value: value}
+	r.register(g)
+	return g
+}
+
+// write escreve o medidor no formato de texto do Prometheus.
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value()))
+}
+
+// formatFloat formata o valor v como no formato de texto do Prometheus.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapa as barras invertidas, aspas e quebras de linha do valor de um rótulo.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Estrutura countingWriter conta os bytes escritos no io.Writer w.
+type countingWriter struct {
+	w io.Writer // destino da escrita
+	n int64     // número de bytes escritos
+}
+
+// Write escreve p em w e contabiliza os bytes escritos. Implementa a interface io.Writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ListenAndServe atende, no endereço addr, a rota /metrics com as métricas do conjunto r.
+// Bloqueia até que o servidor HTTP falhe, e retorna o erro.
+func ListenAndServe(addr string, r *Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/rpc"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Estrutura RPCMetrics representa as métricas das chamadas atendidas por um servidor net/rpc:
+// o número de chamadas, o número de chamadas que falharam e a latência de cada método.
+//
+// As métricas são coletadas envolvendo o rpc.ServerCodec de cada conexão (ver Wrap), de forma
+// que os métodos expostos não precisem ser alterados. A latência é medida da leitura do
+// cabeçalho da requisição até a escrita da resposta, e inclui a decodificação dos argumentos.
+type RPCMetrics struct {
+	calls    *CounterVec   // número de chamadas, por método
+	errors   *CounterVec   // número de chamadas que devolveram um erro, por método
+	duration *HistogramVec // latência das chamadas em segundos, por método
+}
+
+// NewRPCMetrics registra no conjunto r as métricas das chamadas RPC, com o prefixo namespace
+// (ex.: "partrepository"), e retorna o ponteiro para a estrutura RPCMetrics que as coleta.
+func NewRPCMetrics(r *Registry, namespace string) *RPCMetrics {
+	return &RPCMetrics{
+		calls:    r.NewCounterVec(namespace+"_rpc_calls_total", "Number of RPC calls handled, by method.", "method"),
+		errors:   r.NewCounterVec(namespace+"_rpc_errors_total", "Number of RPC calls that returned an error, by method.", "method"),
+		duration: r.NewHistogramVec(namespace+"_rpc_duration_seconds", "Latency of RPC calls in seconds, by method.", nil, "method"),
+	}
+}
+
+// Wrap retorna um rpc.ServerCodec que delega a codificação ao codec informado e coleta as
+// métricas de cada chamada atendida através dele.
+func (m *RPCMetrics) Wrap(codec rpc.ServerCodec) rpc.ServerCodec {
+	return &serverCodec{ServerCodec: codec, metrics: m, pending: make(map[uint64]call)}
+}
+
+// Estrutura call representa uma chamada em atendimento.
+type call struct {
+	method string    // nome do método
+	start  time.Time // instante da leitura do cabeçalho da requisição
+}
+
+// Estrutura serverCodec envolve um rpc.ServerCodec, registrando o início de cada chamada na
+// leitura do cabeçalho e o seu término na escrita da resposta.
+// O rpc.Server lê as requisições numa única goroutine e escreve as respostas em outras, por isso
+// as chamadas em atendimento são protegidas por um mutex.
+type serverCodec struct {
+	rpc.ServerCodec
+	metrics *RPCMetrics
+	mu      sync.Mutex      // protege as chamadas em atendimento
+	pending map[uint64]call // chamadas em atendimento, indexadas pelo número de sequência
+}
+
+// ReadRequestHeader lê o cabeçalho da requisição e registra o início da chamada.
+// Implementa a interface rpc.ServerCodec.
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.pending[r.Seq] = call{method: r.ServiceMethod, start: time.Now()}
+	c.mu.Unlock()
+	return nil
+}
+
+// WriteResponse escreve a resposta e contabiliza a chamada correspondente.
+// Implementa a interface rpc.ServerCodec.
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mu.Lock()
+	pending, ok := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.mu.Unlock()
+
+	err := c.ServerCodec.WriteResponse(r, body)
+	if ok {
+		// As requisições recusadas pela própria biblioteca net/rpc (método inexistente ou mal formado)
+		// não utilizam o nome do método como rótulo, já que ele é escolhido livremente pelo cliente
+		// e criaria um número ilimitado de séries
+		if strings.HasPrefix(r.Error, "rpc: ") {
+			pending.method = "unknown"
+		}
+		c.metrics.calls.Inc(pending.method)
+		if r.Error != "" {
+			c.metrics.errors.Inc(pending.method)
+		}
+		c.metrics.duration.Observe(time.Since(pending.start).Seconds(), pending.method)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"errors"
+	"go-rpc/encoding"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/internal/pkg/metrics"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/server"
+	"go-rpc/types"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+)
+
+func TestRPCMetrics(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	repo := new(types.PartRepositoryImpl)
+	for _, code := range []string{"bolt", "nut"} {
+		part := types.NewPartImpl(code, "")
+		part.SetCode(code)
+		repo.AddPart(part)
+	}
+	registry := metrics.NewRegistry()
+	rpcMetrics := metrics.NewRPCMetrics(registry, "partrepository")
+	registry.NewGaugeFunc("partrepository_parts", "Number of parts stored in the repository.", func() float64 {
+		return float64(repo.Len())
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("PartRepository", server.NewPartRepositoryServer(repo)); err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeCodec(rpcServer, listener, func(conn io.ReadWriteCloser) rpc.ServerCodec {
+		return rpcMetrics.Wrap(server.NewGobServerCodec(conn))
+	})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	c := client.NewPartRepositoryClient(rpc.NewClient(conn), types.NewRemoteRefImpl(host, port, "repo"))
+	defer c.Close()
+	if _, err := c.GetPart("bolt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPart("missing"); !errors.Is(err, rpcerror.ErrNotFound) {
+		t.Fatalf("GetPart of a missing part = %v, want ErrNotFound", err)
+	}
+
+	metricsServer := httptest.NewServer(registry.Handler())
+	defer metricsServer.Close()
+	resp, err := http.Get(metricsServer.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As chamadas, os erros e as latências são contabilizados por método, e o gauge reflete o repositório
+	for _, line := range []string{
+		`partrepository_rpc_calls_total{method="PartRepository.GetPart"} 2`,
+		`partrepository_rpc_errors_total{method="PartRepository.GetPart"} 1`,
+		`partrepository_rpc_duration_seconds_count{method="PartRepository.GetPart"} 2`,
+		`partrepository_rpc_duration_seconds_bucket{method="PartRepository.GetPart",le="+Inf"} 2`,
+		`partrepository_parts 2`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", line, body)
+		}
+	}
+}
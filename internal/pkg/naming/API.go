@@ -0,0 +1,261 @@
+package naming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/types"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Versão da API JSON do serviço de nomes e o prefixo das rotas que a implementam.
+// As rotas sem prefixo (/lookup, /register, ...) são mantidas com o protocolo legado,
+// baseado em formulários e respostas em texto, para os clientes antigos.
+const (
+	API_VERSION = 1
+	API_PREFIX  = "/v1"
+)
+
+// Erros do serviço de nomes. As mensagens coincidem com as respostas do protocolo legado.
+var (
+	ErrNotRegistered      = errors.New(ERR_KEY_NOT_REGISTERED)
+	ErrAlreadyRegistered  = errors.New(ERR_KEY_ALREADY_REGISTERED)
+	ErrInvalidToken       = errors.New(ERR_INVALID_TOKEN)
+	ErrInvalidTTL         = errors.New(ERR_INVALID_TTL)
+	ErrInvalidRequest     = errors.New(ERR_INVALID_REQUEST)
+	ErrUnsupportedVersion = errors.New(ERR_UNSUPPORTED_VERSION)
+)
+
+// Estrutura apiError associa um erro do serviço de nomes ao código de status HTTP e ao código
+// de erro devolvidos pela API JSON.
+type apiError struct {
+	err    error  // erro do serviço de nomes
+	status int    // código de status HTTP
+	code   string // código do erro no corpo da resposta
+}
+
+// Tabela de erros da API JSON
+var apiErrors = []apiError{
+	{ErrNotRegistered, http.StatusNotFound, "not_registered"},
+	{ErrAlreadyRegistered, http.StatusConflict, "already_registered"},
+	{ErrInvalidToken, http.StatusForbidden, "invalid_token"},
+	{ErrInvalidTTL, http.StatusBadRequest, "invalid_ttl"},
+	{ErrInvalidRequest, http.StatusBadRequest, "invalid_request"},
+	{ErrUnsupportedVersion, http.StatusBadRequest, "unsupported_version"},
+	{auth.ErrPermissionDenied, http.StatusForbidden, "permission_denied"},
+}
+
+// Estrutura RegisterRequest representa o corpo de uma requisição a /v1/register.
+type RegisterRequest struct {
+	Version int    `json:"version,omitempty"` // versão da API utilizada pelo cliente
+	Name    string `json:"name"`              // nome a ser registrado
+	Host    string `json:"host"`              // host do servidor
+	Port    string `json:"port"`              // porta do servidor
+	TTL     string `json:"ttl,omitempty"`     // tempo de validade do registro (ex.: "30s")
+
+	// Chave de réplica do nome. Na primeira instância, permite que outras instâncias se registrem
+	// com o nome; nas demais, deve ser igual à da primeira. Vazia caso o nome não seja replicado.
+	ReplicaKey string `json:"replica_key,omitempty"`
+}
+
+// Estrutura LookupRequest representa o corpo de uma requisição a /v1/lookup.
+type LookupRequest struct {
+	Version int    `json:"version,omitempty"` // versão da API utilizada pelo cliente
+	Name    string `json:"name"`              // nome a ser resolvido
+}
+
+// Estrutura TokenRequest representa o corpo de uma requisição a /v1/heartbeat e /v1/deregister.
+type TokenRequest struct {
+	Version int    `json:"version,omitempty"` // versão da API utilizada pelo cliente
+	Name    string `json:"name"`              // nome registrado
+	Token   string `json:"token"`             // token de posse do registro
+}
+
+// Estrutura UpdateRequest representa o corpo de uma requisição a /v1/update.
+type UpdateRequest struct {
+	Version int    `json:"version,omitempty"` // versão da API utilizada pelo cliente
+	Name    string `json:"name"`              // nome registrado
+	Host    string `json:"host"`              // novo host do servidor
+	Port    string `json:"port"`              // nova porta do servidor
+	Token   string `json:"token"`             // token de posse do registro
+}
+
+// Estrutura Response representa o corpo de todas as respostas da API JSON.
+// Apenas os campos pertinentes à rota são preenchidos.
+type Response struct {
+	Version int                    `json:"version"`         // versão da API utilizada pelo servidor
+	Ref     *types.RemoteRefImpl   `json:"ref,omitempty"`   // primeira instância resolvida (/v1/lookup)
+	Refs    []*types.RemoteRefImpl `json:"refs,omitempty"`  // instâncias resolvidas (/v1/lookup) ou registradas (/v1/list)
+	Token   string                 `json:"token,omitempty"` // token de posse emitido (/v1/register)
+	Error   *ErrorBody             `json:"error,omitempty"` // erro, caso a requisição tenha falhado
+}
+
+// Estrutura ErrorBody representa o erro de uma resposta da API JSON.
+type ErrorBody struct {
+	Code    string `json:"code"`    // código do erro (ex.: "not_registered")
+	Message string `json:"message"` // descrição do erro
+}
+
+// errorResponse retorna o código de status HTTP e o corpo da resposta correspondentes ao erro.
+// Erros desconhecidos são tratados como erros internos do servidor.
+func errorResponse(err error) (int, *Response) {
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			return e.status, &Response{Version: API_VERSION, Error: &ErrorBody{Code: e.code, Message: err.Error()}}
+		}
+	}
+	return http.StatusInternalServerError, &Response{Version: API_VERSION, Error: &ErrorBody{Code: "internal", Message: err.Error()}}
+}
+
+// errorCode retorna o código do erro na API JSON, ou "internal" para os erros desconhecidos.
+func errorCode(err error) string {
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return "internal"
+}
+
+// Err converte o erro do corpo de uma resposta no erro correspondente do serviço de nomes,
+// de forma que possa ser identificado com errors.Is. Os detalhes da mensagem, caso haja, são preservados.
+func (e *ErrorBody) Err() error {
+	for _, known := range apiErrors {
+		if known.code == e.Code {
+			if detail := strings.TrimPrefix(e.Message, known.err.Error()); detail != e.Message && detail != "" {
+				return fmt.Errorf("%w%s", known.err, detail)
+			}
+			return known.err
+		}
+	}
+	return errors.New(e.Message)
+}
+
+// handleAPI define no multiplexador mux os handlers da API JSON versionada:
+//
+//	POST API_PREFIX/lookup     LookupRequest   -> Response{Ref, Refs}
+//	POST API_PREFIX/register   RegisterRequest -> Response{Token}
+//	POST API_PREFIX/heartbeat  TokenRequest    -> Response{}
+//	POST API_PREFIX/update     UpdateRequest   -> Response{}
+//	POST API_PREFIX/deregister TokenRequest    -> Response{}
+//	GET  API_PREFIX/list                       -> Response{Refs}
+//
+// Falhas são sinalizadas com o código de status HTTP correspondente e o campo Error da resposta.
+//
+// Caso um auth.Authorizer tenha sido definido (ver SetAuthorizer), as requisições devem carregar um
+// token no cabeçalho Authorization, no esquema Bearer: lookup e list exigem auth.RoleReader, e as
+// rotas que alteram registros, auth.RoleWriter. Como cada registro só pode ser alterado com o seu
+// token de posse, os servidores de repositório não precisam de um token auth.RoleAdmin, que também
+// permitiria remoções em cascata nos outros repositórios.
+func (n *NameServer) handleAPI(mux *http.ServeMux) {
+	mux.HandleFunc(API_PREFIX+"/lookup", n.apiHandler("POST", auth.RoleReader, func(r *http.Request) (*Response, error) {
+		var req LookupRequest
+		if err := decodeRequest(r, &req, &req.Version); err != nil {
+			return nil, err
+		}
+		refs, err := n.resolveAll(req.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Ref: refs[0], Refs: refs}, nil
+	}))
+
+	mux.HandleFunc(API_PREFIX+"/register", n.apiHandler("POST", auth.RoleWriter, func(r *http.Request) (*Response, error) {
+		var req RegisterRequest
+		if err := decodeRequest(r, &req, &req.Version); err != nil {
+			return nil, err
+		}
+		ttl, err := n.parseTTL(req.TTL)
+		if err != nil {
+			return nil, err
+		}
+		token, err := n.register(req.Name, req.Host, req.Port, req.ReplicaKey, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Token: token}, nil
+	}))
+
+	mux.HandleFunc(API_PREFIX+"/heartbeat", n.apiHandler("POST", auth.RoleWriter, func(r *http.Request) (*Response, error) {
+		var req TokenRequest
+		if err := decodeRequest(r, &req, &req.Version); err != nil {
+			return nil, err
+		}
+		return &Response{}, n.heartbeat(req.Name, req.Token)
+	}))
+
+	mux.HandleFunc(API_PREFIX+"/update", n.apiHandler("POST", auth.RoleWriter, func(r *http.Request) (*Response, error) {
+		var req UpdateRequest
+		if err := decodeRequest(r, &req, &req.Version); err != nil {
+			return nil, err
+		}
+		return &Response{}, n.update(req.Name, req.Token, req.Host, req.Port)
+	}))
+
+	mux.HandleFunc(API_PREFIX+"/deregister", n.apiHandler("POST", auth.RoleWriter, func(r *http.Request) (*Response, error) {
+		var req TokenRequest
+		if err := decodeRequest(r, &req, &req.Version); err != nil {
+			return nil, err
+		}
+		return &Response{}, n.deregister(req.Name, req.Token)
+	}))
+
+	mux.HandleFunc(API_PREFIX+"/list", n.apiHandler("GET", auth.RoleReader, func(r *http.Request) (*Response, error) {
+		return &Response{Refs: n.list()}, nil
+	}))
+}
+
+// apiHandler envolve a função handler, que implementa uma rota da API JSON, verificando o método
+// da requisição e o papel role do seu token, e escrevendo a resposta, ou o erro, com o código de
+// status correspondente.
+func (n *NameServer) apiHandler(method string, role auth.Role, handler func(r *http.Request) (*Response, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, &Response{
+				Version: API_VERSION,
+				Error:   &ErrorBody{Code: "method_not_allowed", Message: "only " + method + " method is supported"},
+			})
+			return
+		}
+
+		if err := n.auth.Authorize(auth.BearerToken(r), role); err != nil {
+			status, body := errorResponse(err)
+			writeJSON(w, status, body)
+			return
+		}
+
+		resp, err := handler(r)
+		if err != nil {
+			status, body := errorResponse(err)
+			writeJSON(w, status, body)
+			return
+		}
+		resp.Version = API_VERSION
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// decodeRequest faz o parsing do corpo JSON da requisição em v e verifica a versão da API
+// informada pelo cliente, armazenada em version. A ausência da versão equivale à versão atual.
+func decodeRequest(r *http.Request, v interface{}, version *int) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return ErrInvalidRequest
+	}
+	if *version != 0 && *version != API_VERSION {
+		return ErrUnsupportedVersion
+	}
+	return nil
+}
+
+// writeJSON escreve v como o corpo JSON da resposta, com o código de status informado.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("[!] Failed to write response:", err)
+	}
+}
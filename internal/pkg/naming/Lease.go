@@ -0,0 +1,122 @@
+package naming
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Estrutura Lease representa a renovação periódica, em segundo plano, do registro de um
+// servidor no serviço de nomes. Ela é criada por NameServerClient.KeepAlive.
+type Lease struct {
+	client *NameServerClient // cliente do serviço de nomes
+	host   string            // host do servidor registrado
+	port   string            // porta do servidor registrado
+	name   string            // nome registrado
+	ttl    time.Duration     // tempo de validade do registro
+	mu     sync.Mutex        // protege o token de posse
+	token  string            // token de posse do registro, substituído quando o servidor se registra novamente
+	stop   chan struct{}     // sinaliza o encerramento das renovações
+	done   chan struct{}     // fechado quando a goroutine de renovação termina
+}
+
+// KeepAlive inicia a renovação periódica do registro do nome informado, que deve ter sido
+// registrado previamente com Register e o mesmo tempo de validade ttl (zero para o valor padrão).
+// Recebe também o token de posse devolvido por Register.
+// As renovações são feitas a cada terço do tempo de validade, de forma que a perda de uma
+// renovação isolada não faça o registro expirar. Caso o registro tenha expirado (por exemplo,
+// após uma reinicialização do serviço de nomes), o servidor é registrado novamente.
+// Retorna um ponteiro para uma estrutura Lease, que permite encerrar as renovações.
+func (n *NameServerClient) KeepAlive(host string, port string, name string, token string, ttl time.Duration) *Lease {
+	l := &Lease{
+		client: n,
+		host:   host,
+		port:   port,
+		name:   name,
+		ttl:    ttl,
+		token:  token,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// run renova o registro periodicamente até que Stop seja chamado.
+// Cada renovação é limitada ao intervalo entre renovações, para que uma renovação lenta não
+// atrase a seguinte, e é abandonada caso Stop seja chamado durante a sua execução.
+func (l *Lease) run() {
+	defer close(l.done)
+
+	ttl := l.ttl
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	interval := ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			renewCtx, renewCancel := context.WithTimeout(ctx, interval)
+			l.renew(renewCtx)
+			renewCancel()
+		}
+	}
+}
+
+// renew renova o registro, registrando o servidor novamente caso o registro tenha expirado.
+// Falhas são apenas reportadas no log, já que a próxima renovação tentará novamente.
+func (l *Lease) renew(ctx context.Context) {
+	err := l.client.HeartbeatContext(ctx, l.name, l.Token())
+	if err == nil {
+		return
+	}
+
+	// O registro expirou: o nome deixou de estar registrado ou, caso outras instâncias do nome
+	// continuem registradas, o token deixou de corresponder a algum registro
+	if !errors.Is(err, ErrNotRegistered) && !errors.Is(err, ErrInvalidToken) {
+		log.Printf("[!] Heartbeat for %s failed: %v", l.name, err)
+		return
+	}
+
+	log.Printf("[!] Lease for %s expired, registering again", l.name)
+	token, err := l.client.RegisterContext(ctx, l.host, l.port, l.name, l.ttl)
+	if err != nil {
+		log.Printf("[!] Registration of %s failed: %v", l.name, err)
+		return
+	}
+
+	l.mu.Lock()
+	l.token = token
+	l.mu.Unlock()
+}
+
+// Token retorna o token de posse atual do registro, necessário para alterá-lo ou removê-lo.
+func (l *Lease) Token() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// Stop encerra as renovações do registro e aguarda o término da goroutine de renovação.
+// O registro não é removido do serviço de nomes e expira ao final do seu tempo de validade.
+func (l *Lease) Stop() {
+	close(l.stop)
+	<-l.done
+}
@@ -0,0 +1,49 @@
+package naming
+
+import (
+	"go-rpc/internal/pkg/metrics"
+	"time"
+)
+
+// Estrutura nameServerMetrics representa as métricas do serviço de nomes.
+type nameServerMetrics struct {
+	requests *metrics.CounterVec // número de operações atendidas, por operação e resultado
+}
+
+// SetMetrics registra no conjunto r as métricas do serviço de nomes: o número de operações
+// atendidas (lookup, register, heartbeat, update, deregister e list), por operação e resultado,
+// somando a API JSON e o protocolo legado, e o número de registros válidos.
+// Deve ser chamada antes de Start.
+func (n *NameServer) SetMetrics(r *metrics.Registry) {
+	n.metrics = &nameServerMetrics{
+		requests: r.NewCounterVec("nameserver_requests_total", "Number of nameserver operations handled, by operation and result.", "operation", "result"),
+	}
+	r.NewGaugeFunc("nameserver_registrations", "Number of live registrations.", func() float64 {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		now := time.Now()
+		count := 0
+		for _, regs := range n.servers {
+			for _, reg := range regs {
+				if !reg.expired(now) {
+					count++
+				}
+			}
+		}
+		return float64(count)
+	})
+}
+
+// observe contabiliza uma operação do serviço de nomes com o erro err, nulo em caso de sucesso.
+// O resultado é "ok" ou o código do erro na API JSON (ex.: "not_registered").
+// Não faz nada caso as métricas não tenham sido definidas.
+func (m *nameServerMetrics) observe(operation string, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = errorCode(err)
+	}
+	m.requests.Inc(operation, result)
+}
@@ -3,11 +3,24 @@
 package naming
 
 import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/trace"
 	"go-rpc/types"
 	"log"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // Constantes que sinalizam respostas do serviço de nome
@@ -16,108 +29,616 @@ const (
 	ERR_KEY_ALREADY_REGISTERED  = "key already registered"
 	KEY_REGISTERED_SUCCESSFULLY = "success"
 	ERR_POST_ONLY               = "Sorry, only POST method is supported."
+	ERR_GET_ONLY                = "Sorry, only GET method is supported."
 	ERR_PARSING                 = "ParseForm() err"
+	ERR_INVALID_TTL             = "invalid ttl"
+	ERR_INVALID_TOKEN           = "invalid ownership token"
+	ERR_INVALID_REQUEST         = "invalid request"
+	ERR_UNSUPPORTED_VERSION     = "unsupported api version"
+)
+
+// OWNERSHIP_TOKEN_HEADER é o cabeçalho da resposta de /register que contém o token de posse
+// emitido para o registro. O token é exigido para renovar (/heartbeat), alterar (/update) e
+// remover (/deregister) o registro, impedindo que um servidor se aproprie do nome de outro.
+const OWNERSHIP_TOKEN_HEADER = "X-Ownership-Token"
+
+// DefaultLeaseTTL é o tempo de validade padrão de um registro no serviço de nomes.
+// Um registro que não é renovado (via /heartbeat) dentro desse intervalo expira e é removido.
+const DefaultLeaseTTL = 30 * time.Second
+
+// Estrutura registration representa o registro de um servidor remoto no serviço de nomes,
+// válido até o instante expires, a menos que seja renovado. Um registro com ttl nulo não expira.
+type registration struct {
+	ref        interfaces.RemoteRef // referência ao servidor remoto
+	token      string               // token de posse emitido no registro
+	replicaKey string               // chave de réplica do nome, exigida para registrar outras instâncias; vazia caso o nome não aceite outras instâncias
+	ttl        time.Duration        // tempo de validade do registro; zero caso não expire
+	registered time.Time            // instante do registro
+	expires    time.Time            // instante em que o registro expira; zero caso não expire
+}
+
+// renew estende a validade do registro pelo seu TTL, caso ele expire.
+func (r *registration) renew() {
+	if r.ttl > 0 {
+		r.expires = time.Now().Add(r.ttl)
+	}
+}
+
+// Chaves dos metadados devolvidos por /list para cada registro
+const (
+	META_REGISTERED_AT = "registered_at" // instante do registro, no formato RFC 3339
+	META_EXPIRES_AT    = "expires_at"    // instante em que o registro expira, caso não seja renovado, ou "never"
+	META_TTL           = "ttl"           // tempo de validade do registro
 )
 
+// describe retorna uma cópia da referência remota do registro acrescida dos seus metadados.
+func (r *registration) describe() *types.RemoteRefImpl {
+	ref := types.NewRemoteRefImpl(r.ref.GetHost(), r.ref.GetPort(), r.ref.GetName())
+	ref.Metadata = map[string]string{
+		META_REGISTERED_AT: r.registered.Format(time.RFC3339),
+		META_EXPIRES_AT:    "never",
+		META_TTL:           r.ttl.String(),
+	}
+	if r.ttl > 0 {
+		ref.Metadata[META_EXPIRES_AT] = r.expires.Format(time.RFC3339)
+	}
+	return ref
+}
+
+// expired retorna true caso o registro tenha expirado no instante now.
+func (r *registration) expired(now time.Time) bool {
+	return r.ttl > 0 && !now.Before(r.expires)
+}
+
 // Estrutura NameServer representa um servidor http para resolução de nomes
 // que utiliza uma solução simples de tabela centralizada de nome e endereço
 // Name-to-address binding interna para resolução.
 // As referências remotas dos servidores devem implementar a interface interfaces.RemoteRef.
+//
+// Um mesmo nome pode ser registrado por várias instâncias, em endereços distintos, de um mesmo
+// servidor replicado. A replicação é opcional: a primeira instância define uma chave de réplica,
+// que as demais devem apresentar para se registrar com o nome; sem ela, o nome é recusado como já
+// registrado, de forma que um servidor não possa receber parte das chamadas destinadas a outro.
+// Cada instância recebe o seu próprio token de posse e é resolvida na ordem de registro, o que
+// permite aos clientes recorrer a outra instância caso uma delas falhe.
+//
+// Cada registro possui um tempo de validade (TTL) e precisa ser renovado periodicamente pelo
+// servidor registrado. Registros expirados deixam de ser resolvidos e são removidos. Os registros
+// feitos pelo protocolo legado sem o campo ttl não expiram, já que os clientes anteriores aos
+// heartbeats não os renovam; eles permanecem até serem removidos com /deregister.
+//
+// O serviço atende a dois protocolos sobre as mesmas operações: a API JSON versionada, sob o
+// prefixo API_PREFIX (ver API.go), e o protocolo legado baseado em formulários, nas rotas sem prefixo.
 type NameServer struct {
-	host    string                          // host do serviço de nomes
-	port    string                          // porta do serviço de nomes
-	servers map[string]interfaces.RemoteRef // mapa de referências aos servidores remotos registrados.
+	host     string                     // host do serviço de nomes
+	port     string                     // porta do serviço de nomes
+	ttl      time.Duration              // tempo de validade padrão dos registros
+	tls      *tls.Config                // configuração TLS do servidor HTTP, nula para HTTP sem TLS
+	auth     *auth.Authorizer           // verifica os papéis dos tokens das requisições, nulo caso não haja autenticação
+	metrics  *nameServerMetrics         // métricas das operações, nulo caso não sejam coletadas (ver SetMetrics)
+	server   *http.Server               // servidor HTTP, nulo antes de Start
+	listener net.Listener               // listener do servidor HTTP, sem TLS
+	stop     chan struct{}              // fechado por Close, encerra a remoção dos registros expirados
+	done     chan struct{}              // fechado quando o servidor HTTP termina
+	serveErr error                      // erro que encerrou o servidor HTTP, nulo caso tenha sido encerrado por Close
+	closed   bool                       // se Close foi chamado
+	mu       sync.Mutex                 // protege o mapa de registros, acessado concorrentemente pelos handlers
+	servers  map[string][]*registration // registros das instâncias dos servidores remotos, indexados pelo nome, na ordem de registro
 }
 
-// Lookup faz uma busca O(1) no mapa de referências aos servidores, retornando uma estrutura
-// que implementa a interface interfaces.RemoteRef.
-func (n *NameServer) lookup(key string) interfaces.RemoteRef {
-	return n.servers[key]
+// SetLeaseTTL altera o tempo de validade padrão dos registros, utilizado quando o servidor
+// que se registra não informa um. Deve ser chamada antes de Start.
+func (n *NameServer) SetLeaseTTL(ttl time.Duration) {
+	n.ttl = ttl
 }
 
-// Init define os handlers para as rotas /lookup e /register do serviço de nomes
-// e inicializa o servidor HTTP no host e porta designada.
-func (n *NameServer) Init(host string, port string) {
+// SetTLSConfig define a configuração TLS do servidor HTTP (ver tlsconfig.Options.Server), que
+// passa a atender apenas HTTPS. Caso a configuração exija certificados de cliente, apenas os
+// componentes com um certificado emitido pela CA configurada podem se registrar e resolver nomes.
+// Deve ser chamada antes de Start.
+func (n *NameServer) SetTLSConfig(cfg *tls.Config) {
+	n.tls = cfg
+}
+
+// SetAuthorizer define o objeto que verifica os papéis dos tokens das requisições, tanto na API
+// JSON (ver handleAPI) quanto no protocolo legado. Sem ele, todas as requisições são aceitas.
+// Deve ser chamada antes de Start.
+func (n *NameServer) SetAuthorizer(authorizer *auth.Authorizer) {
+	n.auth = authorizer
+}
+
+// lookup faz uma busca O(1) no mapa de registros, retornando os registros válidos das instâncias
+// do nome informado, na ordem de registro. Retorna uma lista vazia caso o nome não esteja
+// registrado ou todos os seus registros tenham expirado.
+// Deve ser chamada com o mutex adquirido.
+func (n *NameServer) lookup(key string) []*registration {
+	now := time.Now()
+	var regs []*registration
+	for _, reg := range n.servers[key] {
+		if !reg.expired(now) {
+			regs = append(regs, reg)
+		}
+	}
+	return regs
+}
+
+// owned retorna o registro válido da instância do nome informado cujo token de posse
+// corresponde ao token informado. Retorna ErrNotRegistered caso o nome não possua registros
+// válidos, ou ErrInvalidToken caso o token não corresponda a nenhum deles.
+// Deve ser chamada com o mutex adquirido.
+func (n *NameServer) owned(key string, token string) (*registration, error) {
+	regs := n.lookup(key)
+	if len(regs) == 0 {
+		return nil, ErrNotRegistered
+	}
+	for _, reg := range regs {
+		if subtle.ConstantTimeCompare([]byte(reg.token), []byte(token)) == 1 {
+			return reg, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// registeredAt retorna true caso o nome informado possua um registro válido, diferente de except,
+// no endereço host:port. Deve ser chamada com o mutex adquirido.
+func (n *NameServer) registeredAt(key string, host string, port string, except *registration) bool {
+	for _, reg := range n.lookup(key) {
+		if reg != except && reg.ref.GetHost() == host && reg.ref.GetPort() == port {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTTL converte o tempo de validade informado por um cliente (ex.: "30s"),
+// utilizando o tempo de validade padrão caso ele seja vazio ou nulo.
+func (n *NameServer) parseTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return n.ttl, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, ErrInvalidTTL
+	}
+	if ttl == 0 {
+		return n.ttl, nil
+	}
+	return ttl, nil
+}
+
+// resolve retorna a referência, acrescida dos metadados, da primeira instância registrada com o nome informado.
+func (n *NameServer) resolve(key string) (*types.RemoteRefImpl, error) {
+	refs, err := n.resolveAll(key)
+	if err != nil {
+		return nil, err
+	}
+	return refs[0], nil
+}
+
+// resolveAll retorna as referências, acrescidas dos metadados, de todas as instâncias registradas
+// com o nome informado, na ordem de registro.
+func (n *NameServer) resolveAll(key string) (refs []*types.RemoteRefImpl, err error) {
+	defer func() { n.metrics.observe("lookup", err) }()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	regs := n.lookup(key)
+	if len(regs) == 0 {
+		return nil, ErrNotRegistered
+	}
+	refs = make([]*types.RemoteRefImpl, len(regs))
+	for i, reg := range regs {
+		refs[i] = reg.describe()
+	}
+	return refs, nil
+}
+
+// register registra uma instância do servidor no endereço host:port com o nome informado e retorna
+// o token de posse emitido. Caso o nome já possua instâncias registradas, a nova instância só é
+// aceita caso elas tenham sido registradas com uma chave de réplica e replicaKey seja igual a ela.
+// Retorna ErrAlreadyRegistered caso o nome já esteja registrado nesse endereço, ou registrado sem
+// chave de réplica ou por um servidor que não informou uma, e ErrInvalidToken caso a chave não confira.
+func (n *NameServer) register(key string, host string, port string, replicaKey string, ttl time.Duration) (token string, err error) {
+	defer func() { n.metrics.observe("register", err) }()
+	if key == "" || host == "" || port == "" {
+		return "", ErrInvalidRequest
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.registeredAt(key, host, port, nil) {
+		return "", ErrAlreadyRegistered
+	}
+	if regs := n.lookup(key); len(regs) > 0 {
+		// Todas as instâncias do nome compartilham a chave de réplica da primeira
+		if regs[0].replicaKey == "" || replicaKey == "" {
+			return "", ErrAlreadyRegistered
+		}
+		if subtle.ConstantTimeCompare([]byte(regs[0].replicaKey), []byte(replicaKey)) != 1 {
+			return "", ErrInvalidToken
+		}
+	}
+
+	// Cria uma instância de RemoteRef e acrescenta o registro às instâncias do nome,
+	// emitindo um novo token de posse. Os registros expirados do nome são descartados.
+	now := time.Now()
+	reg := &registration{
+		ref:        types.NewRemoteRefImpl(host, port, key),
+		token:      uuid.New().String(),
+		replicaKey: replicaKey,
+		ttl:        ttl,
+		registered: now,
+	}
+	reg.renew()
+	n.servers[key] = append(n.lookup(key), reg)
+
+	log.Println("[!] Server at " + host + ":" + port + " registered with hostname " + key + " (ttl " + ttl.String() + ")")
+	return reg.token, nil
+}
+
+// heartbeat estende a validade do registro do nome informado pelo seu TTL.
+// Registros expirados não podem ser renovados, o servidor precisa se registrar novamente.
+func (n *NameServer) heartbeat(key string, token string) (err error) {
+	defer func() { n.metrics.observe("heartbeat", err) }()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reg, err := n.owned(key, token)
+	if err != nil {
+		return err
+	}
+	reg.renew()
+	return nil
+}
+
+// update associa a instância do nome informado ao novo endereço host:port e renova o registro.
+// Apenas o dono do registro pode alterá-lo. Retorna ErrAlreadyRegistered caso outra instância
+// do nome esteja registrada no novo endereço.
+func (n *NameServer) update(key string, token string, host string, port string) (err error) {
+	defer func() { n.metrics.observe("update", err) }()
+	if host == "" || port == "" {
+		return ErrInvalidRequest
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reg, err := n.owned(key, token)
+	if err != nil {
+		return err
+	}
+	if n.registeredAt(key, host, port, reg) {
+		return ErrAlreadyRegistered
+	}
+
+	// Substitui a referência ao servidor remoto e renova o registro
+	old := reg.ref.GetAddress()
+	reg.ref = types.NewRemoteRefImpl(host, port, key)
+	reg.renew()
+
+	log.Println("[!] Server with hostname " + key + " moved from " + old + " to " + host + ":" + port)
+	return nil
+}
+
+// deregister remove o registro da instância do nome informado.
+// Apenas o dono do registro pode removê-lo.
+func (n *NameServer) deregister(key string, token string) (err error) {
+	defer func() { n.metrics.observe("deregister", err) }()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reg, err := n.owned(key, token)
+	if err != nil {
+		return err
+	}
+	n.remove(key, reg)
+
+	log.Println("[!] Server at " + reg.ref.GetAddress() + " with hostname " + key + " deregistered")
+	return nil
+}
+
+// remove retira o registro das instâncias do nome informado, removendo o nome do mapa caso
+// não restem instâncias. A lista de instâncias é substituída, e não alterada, de forma que
+// possa ser percorrida durante a remoção. Deve ser chamada com o mutex adquirido.
+func (n *NameServer) remove(key string, reg *registration) {
+	var regs []*registration
+	for _, other := range n.servers[key] {
+		if other != reg {
+			regs = append(regs, other)
+		}
+	}
+	if len(regs) == 0 {
+		delete(n.servers, key)
+		return
+	}
+	n.servers[key] = regs
+}
+
+// list retorna as referências, acrescidas dos metadados, de todos os registros válidos,
+// ordenadas pelo nome e, para as instâncias de um mesmo nome, pela ordem de registro.
+func (n *NameServer) list() []*types.RemoteRefImpl {
+	defer n.metrics.observe("list", nil)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	keys := make([]string, 0, len(n.servers))
+	for key := range n.servers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	refs := make([]*types.RemoteRefImpl, 0, len(keys))
+	for _, key := range keys {
+		for _, reg := range n.lookup(key) {
+			refs = append(refs, reg.describe())
+		}
+	}
+	return refs
+}
+
+// evictExpired remove do mapa os registros expirados.
+func (n *NameServer) evictExpired() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	for key, regs := range n.servers {
+		for _, reg := range regs {
+			if reg.expired(now) {
+				n.remove(key, reg)
+				log.Println("[!] Lease of server " + reg.ref.GetAddress() + " with hostname " + key + " expired")
+			}
+		}
+	}
+}
+
+// evictLoop remove periodicamente os registros expirados, até que o serviço seja encerrado.
+func (n *NameServer) evictLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.evictExpired()
+		}
+	}
+}
+
+// NewNameServer retorna o ponteiro para uma estrutura NameServer que atenderá no host e na porta
+// informados. A porta "0" escolhe uma porta livre, informada por Addr após Start.
+func NewNameServer(host string, port string) *NameServer {
+	return &NameServer{host: host, port: port}
+}
+
+// Start começa a escutar no host e na porta do serviço de nomes e a atender, em segundo plano, a
+// API JSON (ver API.go) e as rotas legadas /lookup, /register, /heartbeat, /update, /deregister e
+// /list, com um http.ServeMux próprio, de forma que vários serviços de nomes possam ser executados
+// no mesmo processo. O contexto ctx limita a inicialização, e o seu cancelamento encerra o serviço,
+// assim como Close.
+// Retorna um erro caso não seja possível escutar no endereço ou o serviço já tenha sido iniciado.
+func (n *NameServer) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.server != nil {
+		return errors.New("nameserver already started")
+	}
+
 	// Aloca memória para um mapa cujas chaves são strings e representam os nomes dos servidores
-	// e os valores são ponteiros para objetos que implementam interfaces.RemoteRef
-	n.servers = make(map[string]interfaces.RemoteRef)
+	// e os valores são listas de ponteiros para os registros das instâncias dos servidores remotos
+	n.servers = make(map[string][]*registration)
+
+	if n.ttl <= 0 {
+		n.ttl = DefaultLeaseTTL
+	}
+
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", net.JoinHostPort(n.host, n.port))
+	if err != nil {
+		return err
+	}
+	n.listener = listener
+	scheme := "http"
+	if n.tls != nil {
+		listener = tls.NewListener(listener, n.tls)
+		scheme = "https"
+	}
+
+	// Atende cada requisição numa etapa do trace do chamador, registrada no log (ver trace.Handler)
+	n.server = &http.Server{Handler: trace.Handler(n.handler())}
+	n.stop = make(chan struct{})
+	n.done = make(chan struct{})
 
+	// Remove os registros expirados em segundo plano
+	go n.evictLoop(time.Second)
+
+	go func() {
+		defer close(n.done)
+		if err := n.server.Serve(listener); err != http.ErrServerClosed {
+			n.serveErr = err
+			log.Println("[!] Nameserver stopped:", err)
+		}
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.Close()
+		case <-n.stop:
+		}
+	}()
+
+	log.Printf("[!] %s server running on %s://%s", strings.ToUpper(scheme), scheme, listener.Addr())
+	return nil
+}
+
+// Addr retorna o endereço, no formato host:porta, em que o serviço de nomes escuta, ou uma string
+// vazia caso ele não tenha sido iniciado.
+func (n *NameServer) Addr() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.listener == nil {
+		return ""
+	}
+	return n.listener.Addr().String()
+}
+
+// Close encerra o serviço de nomes, fechando o listener e as conexões abertas, e interrompe a
+// remoção dos registros expirados. Os registros são descartados. Chamadas posteriores não têm efeito.
+func (n *NameServer) Close() error {
+	n.mu.Lock()
+	if n.server == nil || n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.stop)
+	n.mu.Unlock()
+
+	err := n.server.Close()
+	<-n.done
+	return err
+}
+
+// Init inicializa o serviço de nomes no host e porta designados (ver Start) e bloqueia enquanto
+// ele estiver em execução, encerrando o processo caso o servidor HTTP falhe.
+// É mantida para os programas que não precisam encerrar o serviço.
+func (n *NameServer) Init(host string, port string) {
 	// Altera os valores das propriedades host e port para os valores recebidos por parâmetro
 	n.host = host
 	n.port = port
 
-	// Define comportamento para o endpoint /lookup, que serve para fazer a resolução do endereço
-	// associado a um nome
-	http.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		// Método POST
-		case "POST":
-
-			// Faz o parsing do formulário da requisição e sinaliza um erro caso seja encontrado
-			if err := r.ParseForm(); err != nil {
-				fmt.Fprint(w, ERR_PARSING)
-				return
-			}
+	if err := n.Start(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(n.Wait())
+}
 
-			// Recupera o atributo key, que sinaliza o nome de um servidor e faz o lookup no mapa
-			key := r.FormValue("key")
-			ref := n.lookup(key)
+// Wait bloqueia até que o serviço de nomes seja encerrado e retorna o erro que encerrou o servidor
+// HTTP, ou nulo caso tenha sido encerrado por Close ou pelo cancelamento do contexto de Start.
+// Deve ser chamada após Start.
+func (n *NameServer) Wait() error {
+	<-n.done
+	return n.serveErr
+}
 
-			// Se a referência encontrada não for nula, escreve no corpo da resposta o endereço do servidor
-			if ref != nil {
-				fmt.Fprintf(w, "%s", ref.GetAddress())
-			} else {
-				// Caso contrário escreve que o serviço não foi encontrado
-				fmt.Fprint(w, ERR_KEY_NOT_REGISTERED)
-			}
+// handler retorna um http.ServeMux com os handlers da API JSON (ver API.go) e das rotas legadas.
+func (n *NameServer) handler() http.Handler {
+	mux := http.NewServeMux()
 
-		default:
-			// Caso a requisição seja feita à rota por outro método sinaliza que apenas o método POST é suprotado
-			fmt.Fprint(w, ERR_POST_ONLY)
+	// Define os handlers da API JSON versionada
+	n.handleAPI(mux)
+
+	// Define comportamento para o endpoint /lookup, que serve para fazer a resolução do endereço
+	// associado a um nome
+	mux.HandleFunc("/lookup", n.legacyHandler(auth.RoleReader, func(w http.ResponseWriter, r *http.Request) {
+		// Recupera o atributo key, que sinaliza o nome de um servidor e faz o lookup no mapa
+		ref, err := n.resolve(r.FormValue("key"))
+
+		// Se a referência for encontrada, escreve no corpo da resposta o endereço do servidor
+		if err != nil {
+			fmt.Fprint(w, err)
+			return
 		}
-	})
+		fmt.Fprintf(w, "%s", ref.GetAddress())
+	}))
 
 	// Define comportamento para o endpoint /register, que serve para fazer a o registro de um servidor
 	// para posterior resolução
-	http.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		// Método POST
-		case "POST":
-
-			// Faz o parsing do formulário da requisição e sinaliza um erro caso seja encontrado
-			err := r.ParseForm()
-
-			if err != nil {
-				fmt.Fprint(w, ERR_PARSING)
+	mux.HandleFunc("/register", n.legacyHandler(auth.RoleWriter, func(w http.ResponseWriter, r *http.Request) {
+		// Recupera o atributo opcional ttl, que define o tempo de validade do registro (ex.: 30s).
+		// Sem ele, o registro não expira, já que os clientes legados não enviam heartbeats
+		var ttl time.Duration
+		if value := r.FormValue("ttl"); value != "" {
+			var err error
+			if ttl, err = n.parseTTL(value); err != nil {
+				fmt.Fprint(w, err)
 				return
 			}
+		}
+
+		// Recupera o atributo key, que sinaliza o nome de um servidor, os atributos host e port
+		// que compõem o endereço do servidor que está se registrando e o atributo opcional
+		// replica_key, exigido para registrar outra instância de um nome já registrado
+		token, err := n.register(r.FormValue("key"), r.FormValue("host"), r.FormValue("port"), r.FormValue("replica_key"), ttl)
+		if err != nil {
+			fmt.Fprint(w, err)
+			return
+		}
 
-			// Recupera o atributo key, que sinaliza o nome de um servidor e os atributos host e port
-			// que compõem o endereço do servidor que está se registrando
-			key := r.FormValue("key")
-			host := r.FormValue("host")
-			port := r.FormValue("port")
+		// Devolve o token de posse no cabeçalho
+		w.Header().Set(OWNERSHIP_TOKEN_HEADER, token)
+		fmt.Fprint(w, KEY_REGISTERED_SUCCESSFULLY)
+	}))
 
-			// Escreve false no corpo da resposta caso o nome já esteja sendo utilizada por outro servidor e retorna
-			if n.lookup(key) != nil {
-				fmt.Fprint(w, ERR_KEY_ALREADY_REGISTERED)
-				return
-			}
+	// Define comportamento para o endpoint /heartbeat, que serve para renovar o registro de um servidor
+	// antes que ele expire
+	mux.HandleFunc("/heartbeat", n.legacyHandler(auth.RoleWriter, func(w http.ResponseWriter, r *http.Request) {
+		writeLegacyResult(w, n.heartbeat(r.FormValue("key"), r.FormValue("token")))
+	}))
+
+	// Define comportamento para o endpoint /update, que serve para alterar o endereço associado a
+	// um nome, por exemplo quando o servidor é reiniciado em outra porta
+	mux.HandleFunc("/update", n.legacyHandler(auth.RoleWriter, func(w http.ResponseWriter, r *http.Request) {
+		writeLegacyResult(w, n.update(r.FormValue("key"), r.FormValue("token"), r.FormValue("host"), r.FormValue("port")))
+	}))
 
-			// Cria uma instância de RemoteRef e define o par <chave, valor> no mapa
-			n.servers[key] = types.NewRemoteRefImpl(host, port, key)
+	// Define comportamento para o endpoint /deregister, que serve para remover o registro de um nome,
+	// liberando-o para outro servidor
+	mux.HandleFunc("/deregister", n.legacyHandler(auth.RoleWriter, func(w http.ResponseWriter, r *http.Request) {
+		writeLegacyResult(w, n.deregister(r.FormValue("key"), r.FormValue("token")))
+	}))
 
-			// Faz o log do registro do servidor e escreve true no corpo da resposta
-			log.Println("[!] Server at " + host + ":" + port + " registered with hostname " + key)
-			fmt.Fprint(w, KEY_REGISTERED_SUCCESSFULLY)
+	// Define comportamento para o endpoint /list, mantido por compatibilidade com os clientes que
+	// o utilizavam antes da API JSON versionada. A resposta é uma lista JSON de referências remotas.
+	mux.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			// Caso a requisição seja feita à rota por outro método sinaliza que apenas o método GET é suprotado
+			fmt.Fprint(w, ERR_GET_ONLY)
+			return
+		}
+		if err := n.auth.Authorize(auth.BearerToken(r), auth.RoleReader); err != nil {
+			fmt.Fprint(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, n.list())
+	})
 
-		default:
+	return mux
+}
+
+// legacyHandler envolve um handler do protocolo legado, que aceita apenas o método POST e recebe
+// os parâmetros num formulário. As respostas do protocolo legado são sempre textuais e com status 200.
+// Assim como na API JSON, o token da requisição deve possuir o papel role.
+func (n *NameServer) legacyHandler(role auth.Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
 			// Caso a requisição seja feita à rota por outro método sinaliza que apenas o método POST é suprotado
 			fmt.Fprint(w, ERR_POST_ONLY)
+			return
 		}
-	})
 
-	// Inicializa servidor no host e porta designadas
-	log.Println("[!] HTTP server running on http://" + host + ":" + port)
-	log.Fatal(http.ListenAndServe(host+":"+port, nil))
+		if err := n.auth.Authorize(auth.BearerToken(r), role); err != nil {
+			fmt.Fprint(w, err)
+			return
+		}
+
+		// Faz o parsing do formulário da requisição e sinaliza um erro caso seja encontrado
+		if err := r.ParseForm(); err != nil {
+			fmt.Fprint(w, ERR_PARSING)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// writeLegacyResult escreve no corpo da resposta do protocolo legado a mensagem do erro,
+// ou KEY_REGISTERED_SUCCESSFULLY caso a operação tenha sido bem sucedida.
+func writeLegacyResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		fmt.Fprint(w, err)
+		return
+	}
+	fmt.Fprint(w, KEY_REGISTERED_SUCCESSFULLY)
 }
@@ -1,98 +1,268 @@
 package naming
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"log"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/internal/pkg/trace"
+	"go-rpc/types"
 	"net/http"
-	"net/url"
+	"time"
 )
 
+// DefaultTimeout é o tempo máximo de espera por uma resposta do serviço de nomes.
+const DefaultTimeout = 10 * time.Second
+
 // Estrutura NameServerCliente representa um cliente para o servidor http de resolução de nomes
-// NameServer.
+// NameServer. O cliente utiliza a API JSON versionada do serviço de nomes (ver API.go).
+// Todas as falhas são devolvidas como erros do tipo *rpcerror.Error.
+//
+// Todos os métodos possuem uma variante com o sufixo Context, que recebe um context.Context e
+// abandona a requisição caso o contexto seja cancelado ou o seu prazo vença. As variantes sem
+// contexto são limitadas apenas por DefaultTimeout.
 type NameServerClient struct {
-	host string // host do serviço de nomes
-	port string // porta do serviço de nomes
+	host       string       // host do serviço de nomes
+	port       string       // porta do serviço de nomes
+	httpClient *http.Client // cliente HTTP utilizado nas requisições
+	tls        *tls.Config  // configuração TLS de cliente, nula para HTTP sem TLS
+	token      string       // token de API enviado em todas as requisições, vazio caso não haja autenticação
+	replicaKey string       // chave de réplica enviada nos registros, vazia caso os nomes não sejam replicados
 }
 
 // NewNameServerClient retorna o ponteiro para uma estrutura NameServerClient.
 // Ela recebe como parâmetro duas strings que representam o host e a porta do serviço de nomes remoto, respectivamente.
 func NewNameServerClient(host string, port string) *NameServerClient {
-	return &NameServerClient{host, port}
+	return &NameServerClient{host: host, port: port, httpClient: &http.Client{Timeout: DefaultTimeout}}
+}
+
+// SetTLSConfig define a configuração TLS de cliente (ver tlsconfig.Options.Client) utilizada nas
+// requisições ao serviço de nomes, que passam a ser feitas por HTTPS. A mesma configuração é
+// utilizada pelos clientes de repositório criados a partir deste cliente (ver client.Dial), já
+// que todos os componentes de uma instalação utilizam certificados emitidos pela mesma CA.
+// Deve ser chamada antes da primeira requisição.
+func (n *NameServerClient) SetTLSConfig(cfg *tls.Config) {
+	n.tls = cfg
+	n.httpClient = &http.Client{Timeout: DefaultTimeout, Transport: &http.Transport{TLSClientConfig: cfg}}
+}
+
+// TLSConfig retorna a configuração TLS de cliente, ou nil caso o TLS não tenha sido configurado.
+func (n *NameServerClient) TLSConfig() *tls.Config {
+	return n.tls
+}
+
+// SetToken define o token de API enviado em todas as requisições, no cabeçalho Authorization.
+// Assim como a configuração TLS, o token é herdado pelos clientes de repositório criados a partir
+// deste cliente (ver client.Dial). Deve ser chamada antes da primeira requisição.
+func (n *NameServerClient) SetToken(token string) {
+	n.token = token
+}
+
+// Token retorna o token de API enviado nas requisições.
+func (n *NameServerClient) Token() string {
+	return n.token
+}
+
+// SetReplicaKey define a chave de réplica enviada nos registros (ver Register), que permite que
+// várias instâncias de um servidor replicado se registrem com o mesmo nome. Todas as instâncias
+// devem utilizar a mesma chave. Deve ser chamada antes da primeira requisição.
+func (n *NameServerClient) SetReplicaKey(key string) {
+	n.replicaKey = key
 }
 
 // Lookup faz uma consulta ao serviço de nomes a fim de resolver o endereço a partir do nome do servidor
+// Caso o nome possua várias instâncias registradas, retorna a primeira delas (ver LookupAll).
 // Recebe como parâmetro uma string chave, que sinaliza o nome do serviço a ser resolvido, e retorna
-// uma string e um erro, que sinalizam, respectivamente, o endereço do servidor, caso seja resolvido,
-// e um erro, caso o nome não tenha sido resolvido, sinalizando que a chave provavelmente não foi registrada.
-func (n *NameServerClient) Lookup(key string) (string, error) {
-	// Prepara corpo da requisição
-	data := url.Values{
-		"key": {key},
-	}
+// a referência ao servidor remoto, com nome, host, porta e metadados, caso seja resolvido,
+// e um erro, caso o nome não tenha sido resolvido (da categoria rpcerror.ErrNotFound, caso a chave não tenha sido registrada).
+func (n *NameServerClient) Lookup(key string) (interfaces.RemoteRef, error) {
+	return n.LookupContext(context.Background(), key)
+}
 
-	// Faz a requisição ao serviço de nomes conectado no endpoint /lookup e faz o logging caso haja um erro na requisição
-	resp, err := http.PostForm(n.getAddress()+"/lookup", data)
+// LookupContext é a variante de Lookup que recebe um contexto.
+func (n *NameServerClient) LookupContext(ctx context.Context, key string) (interfaces.RemoteRef, error) {
+	// Faz a requisição ao serviço de nomes conectado no endpoint /lookup
+	resp, err := n.call(ctx, "NameServer.Lookup", "POST", "/lookup", LookupRequest{Version: API_VERSION, Name: key})
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	// retorna a referência normalmente
+	return resp.Ref, nil
+}
 
-	// Faz a leitura do corpo da requisição e sinaliza caso haja algum erro
-	body, err := ioutil.ReadAll(resp.Body)
+// LookupAll faz uma consulta ao serviço de nomes a fim de resolver os endereços de todas as instâncias
+// registradas com o nome informado, na ordem de registro.
+// Retorna um erro da categoria rpcerror.ErrNotFound caso o nome não possua instâncias registradas.
+func (n *NameServerClient) LookupAll(key string) ([]interfaces.RemoteRef, error) {
+	return n.LookupAllContext(context.Background(), key)
+}
 
+// LookupAllContext é a variante de LookupAll que recebe um contexto.
+func (n *NameServerClient) LookupAllContext(ctx context.Context, key string) ([]interfaces.RemoteRef, error) {
+	resp, err := n.call(ctx, "NameServer.LookupAll", "POST", "/lookup", LookupRequest{Version: API_VERSION, Name: key})
 	if err != nil {
-		log.Fatalln(err)
-	}
-
-	// Converte o corpo da string para o tipo string e checa por erros
-	sb := string(body)
-
-	switch sb {
-	case ERR_KEY_NOT_REGISTERED, ERR_PARSING:
-		return "", errors.New(sb)
-	default:
-		// retorna o endereço normalmente
-		return sb, nil
+		return nil, err
 	}
+	return toRefs(resp.Refs), nil
 }
 
 // Register faz uma requisição ao serviço de nomes a fim de registrar o endereço de origem à um nome.
-// Recebe como parâmetro o host, porta e nome do servidor que quer se registar no serviço de nomes, respectivamente, e retorna
-// um  um erro, que sinaliza uma falha no registro, caso ocorra.
-func (n *NameServerClient) Register(host string, port string, name string) error {
+// Recebe como parâmetro o host, porta e nome do servidor que quer se registar no serviço de nomes, respectivamente,
+// e o tempo de validade do registro (zero para utilizar o valor padrão do serviço de nomes). Retorna
+// o token de posse emitido pelo serviço de nomes e um erro, que sinaliza uma falha no registro, caso ocorra.
+// O token deve ser guardado pelo servidor registrado, já que é exigido para renovar, alterar e remover o registro.
+// O registro expira caso não seja renovado com Heartbeat dentro do tempo de validade (ver KeepAlive).
+// Caso o nome já esteja registrado, outra instância só pode se registrar com ele caso ambas
+// utilizem a mesma chave de réplica (ver SetReplicaKey); caso contrário, o registro falha com
+// ErrAlreadyRegistered, ou ErrInvalidToken caso as chaves sejam diferentes.
+func (n *NameServerClient) Register(host string, port string, name string, ttl time.Duration) (string, error) {
+	return n.RegisterContext(context.Background(), host, port, name, ttl)
+}
+
+// RegisterContext é a variante de Register que recebe um contexto.
+func (n *NameServerClient) RegisterContext(ctx context.Context, host string, port string, name string, ttl time.Duration) (string, error) {
 	// Prepara corpo da requisição contendo dados para registro
-	data := url.Values{
-		"host": {host},
-		"port": {port},
-		"key":  {name},
+	req := RegisterRequest{Version: API_VERSION, Name: name, Host: host, Port: port, ReplicaKey: n.replicaKey}
+	if ttl > 0 {
+		req.TTL = ttl.String()
 	}
 
 	// Faz a requisição ao servidor no endpoint /register e sinaliza caso ocorra algum erro
-	resp, err := http.PostForm(n.getAddress()+"/register", data)
+	resp, err := n.call(ctx, "NameServer.Register", "POST", "/register", req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// Heartbeat faz uma requisição ao serviço de nomes a fim de renovar o registro do nome informado,
+// identificado pelo token de posse devolvido por Register.
+// Retorna um erro caso a renovação falhe. Caso o registro já tenha expirado, o erro é ErrNotRegistered,
+// ou ErrInvalidToken caso outras instâncias do nome continuem registradas, e o servidor precisa
+// se registrar novamente.
+func (n *NameServerClient) Heartbeat(name string, token string) error {
+	return n.HeartbeatContext(context.Background(), name, token)
+}
 
+// HeartbeatContext é a variante de Heartbeat que recebe um contexto.
+func (n *NameServerClient) HeartbeatContext(ctx context.Context, name string, token string) error {
+	_, err := n.call(ctx, "NameServer.Heartbeat", "POST", "/heartbeat", TokenRequest{Version: API_VERSION, Name: name, Token: token})
+	return err
+}
+
+// Update faz uma requisição ao serviço de nomes a fim de associar o nome informado a um novo endereço,
+// por exemplo quando o servidor é reiniciado em outra porta. O registro também é renovado.
+// Recebe como parâmetro o novo host e a nova porta, o nome registrado e o token de posse devolvido por Register.
+// Retorna ErrInvalidToken caso o token não corresponda ao do registro.
+func (n *NameServerClient) Update(host string, port string, name string, token string) error {
+	return n.UpdateContext(context.Background(), host, port, name, token)
+}
+
+// UpdateContext é a variante de Update que recebe um contexto.
+func (n *NameServerClient) UpdateContext(ctx context.Context, host string, port string, name string, token string) error {
+	_, err := n.call(ctx, "NameServer.Update", "POST", "/update", UpdateRequest{Version: API_VERSION, Name: name, Host: host, Port: port, Token: token})
+	return err
+}
+
+// Deregister faz uma requisição ao serviço de nomes a fim de remover o registro do nome informado,
+// liberando-o para outro servidor.
+// Recebe como parâmetro o nome registrado e o token de posse devolvido por Register.
+// Retorna ErrInvalidToken caso o token não corresponda ao do registro.
+func (n *NameServerClient) Deregister(name string, token string) error {
+	return n.DeregisterContext(context.Background(), name, token)
+}
+
+// DeregisterContext é a variante de Deregister que recebe um contexto.
+func (n *NameServerClient) DeregisterContext(ctx context.Context, name string, token string) error {
+	_, err := n.call(ctx, "NameServer.Deregister", "POST", "/deregister", TokenRequest{Version: API_VERSION, Name: name, Token: token})
+	return err
+}
+
+// List faz uma consulta ao serviço de nomes a fim de descobrir todos os servidores registrados.
+// Retorna a lista de referências remotas, ordenada pelo nome e acrescida dos metadados de cada
+// registro (ver as constantes META_*), e um erro, caso a consulta falhe.
+func (n *NameServerClient) List() ([]interfaces.RemoteRef, error) {
+	return n.ListContext(context.Background())
+}
+
+// ListContext é a variante de List que recebe um contexto.
+func (n *NameServerClient) ListContext(ctx context.Context) ([]interfaces.RemoteRef, error) {
+	resp, err := n.call(ctx, "NameServer.List", "GET", "/list", nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	// Faz a leitura do corpo da requisição e sinaliza caso ocorra algum erro
-	body, err := ioutil.ReadAll(resp.Body)
+	return toRefs(resp.Refs), nil
+}
+
+// toRefs converte uma lista de referências da API JSON numa lista de interfaces.RemoteRef.
+func toRefs(refs []*types.RemoteRefImpl) []interfaces.RemoteRef {
+	out := make([]interfaces.RemoteRef, len(refs))
+	for i, ref := range refs {
+		out[i] = ref
+	}
+	return out
+}
+
+// call faz uma requisição à rota path da API JSON do serviço de nomes, enviando req como corpo
+// (caso não seja nulo), e retorna a resposta decodificada. A requisição é abandonada caso o
+// contexto seja cancelado ou o seu prazo vença.
+// Falhas são devolvidas como erros do tipo *rpcerror.Error da operação op: falhas na comunicação
+// são classificadas por rpcerror.Transport, nomes não registrados como rpcerror.ErrNotFound,
+// requisições recusadas por falta de permissão como rpcerror.ErrPermissionDenied e os demais
+// erros do serviço de nomes como rpcerror.ErrServer. O erro original do serviço de nomes
+// (ex.: ErrInvalidToken) pode ser identificado com errors.Is.
+func (n *NameServerClient) call(ctx context.Context, op string, method string, path string, req interface{}) (resp *Response, err error) {
+	ctx, span := trace.Start(ctx, op)
+	defer func() { span.End(err) }()
+
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return nil, rpcerror.New(op, rpcerror.ErrServer, err)
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, n.getAddress()+API_PREFIX+path, &body)
+	if err != nil {
+		return nil, rpcerror.New(op, rpcerror.ErrServer, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	auth.SetBearerToken(httpReq, n.token)
+	trace.Inject(httpReq.Header, span.Context())
 
+	httpResp, err := n.httpClient.Do(httpReq)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, rpcerror.Transport(op, err)
 	}
+	defer httpResp.Body.Close()
 
-	// Converte o corpo da string para o tipo string e checa por erros
-	sb := string(body)
+	resp = new(Response)
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return nil, rpcerror.New(op, rpcerror.ErrServer, err)
+	}
 
-	switch sb {
-	case KEY_REGISTERED_SUCCESSFULLY:
-		return nil
-	default:
-		return errors.New(sb)
+	if resp.Error != nil {
+		err := resp.Error.Err()
+		if errors.Is(err, ErrNotRegistered) {
+			return nil, rpcerror.New(op, rpcerror.ErrNotFound, err)
+		}
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, rpcerror.New(op, rpcerror.ErrPermissionDenied, err)
+		}
+		return nil, rpcerror.New(op, rpcerror.ErrServer, err)
 	}
+	return resp, nil
 }
 
 // getAddress retorna o endereço no formato host:porta do serviço de nomes
 func (n *NameServerClient) getAddress() string {
+	if n.tls != nil {
+		return "https://" + n.host + ":" + n.port
+	}
 	return "http://" + n.host + ":" + n.port
 }
@@ -0,0 +1,357 @@
+package naming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/types"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startNameServer inicia um serviço de nomes numa porta livre e retorna um cliente conectado a ele.
+// Caso authorizer não seja nulo, as requisições são autenticadas por ele.
+func startNameServer(t *testing.T, authorizer ...*auth.Authorizer) (*NameServer, *NameServerClient) {
+	t.Helper()
+	n := NewNameServer("127.0.0.1", "0")
+	if len(authorizer) > 0 {
+		n.SetAuthorizer(authorizer[0])
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	host, port, _ := net.SplitHostPort(n.Addr())
+	return n, NewNameServerClient(host, port)
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	n := &NameServer{servers: make(map[string][]*registration)}
+	register := func(key string, ttl time.Duration) *registration {
+		reg := &registration{ref: types.NewRemoteRefImpl("127.0.0.1", "9001", key), ttl: ttl}
+		reg.renew()
+		n.servers[key] = []*registration{reg}
+		return reg
+	}
+	renewed := register("renewed", 100*time.Millisecond)
+	register("leased", 100*time.Millisecond)
+	register("legacy", 0)
+
+	// Apenas o registro renovado dentro do prazo continua válido; o registro sem ttl não expira
+	time.Sleep(60 * time.Millisecond)
+	renewed.renew()
+	time.Sleep(60 * time.Millisecond)
+	n.evictExpired()
+
+	for key, want := range map[string]bool{"renewed": true, "leased": false, "legacy": true} {
+		if got := len(n.lookup(key)) > 0; got != want {
+			t.Errorf("lookup(%s) found = %v, want %v", key, got, want)
+		}
+		if _, got := n.servers[key]; got != want {
+			t.Errorf("registration %s kept = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLegacyRegisterWithoutTTLDoesNotExpire(t *testing.T) {
+	n := NewNameServer("127.0.0.1", "0")
+	n.SetLeaseTTL(50 * time.Millisecond)
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	host, port, _ := net.SplitHostPort(n.Addr())
+	ns := NewNameServerClient(host, port)
+
+	resp, err := http.PostForm("http://"+n.Addr()+"/register", url.Values{"key": {"legacy"}, "host": {"127.0.0.1"}, "port": {"9001"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := ns.Register("127.0.0.1", "9002", "leased", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Aguarda o prazo padrão e a remoção dos registros expirados
+	time.Sleep(100 * time.Millisecond)
+	n.evictExpired()
+
+	if ref, err := ns.Lookup("legacy"); err != nil || ref.GetPort() != "9001" {
+		t.Errorf("Lookup of legacy registration = %v, %v", ref, err)
+	}
+	if _, err := ns.Lookup("leased"); !errors.Is(err, rpcerror.ErrNotFound) {
+		t.Errorf("Lookup of expired registration = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateAndDeregisterRequireToken(t *testing.T) {
+	_, ns := startNameServer(t)
+	token, err := ns.Register("127.0.0.1", "9001", "server1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Um token que não corresponde ao registro é recusado, e o registro permanece inalterado
+	if err := ns.Update("127.0.0.1", "9002", "server1", "wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Update with wrong token = %v, want ErrInvalidToken", err)
+	}
+	if err := ns.Deregister("server1", "wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Deregister with wrong token = %v, want ErrInvalidToken", err)
+	}
+	if ref, err := ns.Lookup("server1"); err != nil || ref.GetPort() != "9001" {
+		t.Fatalf("Lookup after rejected writes = %v, %v, want port 9001", ref, err)
+	}
+
+	// A alteração com o token do registro muda o endereço resolvido
+	if err := ns.Update("127.0.0.1", "9002", "server1", token); err != nil {
+		t.Fatal(err)
+	}
+	if ref, err := ns.Lookup("server1"); err != nil || ref.GetPort() != "9002" {
+		t.Errorf("Lookup after Update = %v, %v, want port 9002", ref, err)
+	}
+
+	// Um nome removido deixa de ser resolvido e pode ser registrado novamente, inclusive por outro servidor
+	if err := ns.Deregister("server1", token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Lookup("server1"); !errors.Is(err, rpcerror.ErrNotFound) {
+		t.Errorf("Lookup after Deregister = %v, want ErrNotFound", err)
+	}
+	if err := ns.Deregister("server1", token); !errors.Is(err, rpcerror.ErrNotFound) {
+		t.Errorf("second Deregister = %v, want ErrNotFound", err)
+	}
+	if _, err := ns.Register("127.0.0.1", "9003", "server1", 0); err != nil {
+		t.Fatalf("Register after Deregister = %v", err)
+	}
+	if ref, err := ns.Lookup("server1"); err != nil || ref.GetPort() != "9003" {
+		t.Errorf("Lookup after new Register = %v, %v, want port 9003", ref, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	_, ns := startNameServer(t)
+	if _, err := ns.Register("127.0.0.1", "9002", "list-b", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Register("127.0.0.1", "9003", "list-expired", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// Registro de um cliente anterior aos heartbeats, sem o campo ttl
+	resp, err := http.PostForm(ns.getAddress()+"/register", url.Values{"key": {"list-a"}, "host": {"127.0.0.1"}, "port": {"9001"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	refs, err := ns.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Os registros válidos são listados em ordem de nome, com os seus metadados
+	var listed []interfaces.RemoteRef
+	for _, ref := range refs {
+		if strings.HasPrefix(ref.GetName(), "list-") {
+			listed = append(listed, ref)
+		}
+	}
+	if len(listed) != 2 || listed[0].GetName() != "list-a" || listed[1].GetName() != "list-b" {
+		t.Fatalf("List = %v, want list-a and list-b", listed)
+	}
+	if addr := listed[1].GetAddress(); addr != "127.0.0.1:9002" {
+		t.Errorf("list-b address = %s, want 127.0.0.1:9002", addr)
+	}
+
+	legacy, leased := listed[0].(*types.RemoteRefImpl).Metadata, listed[1].(*types.RemoteRefImpl).Metadata
+	if legacy[META_EXPIRES_AT] != "never" || legacy[META_TTL] != "0s" {
+		t.Errorf("list-a metadata = %v, want a registration that never expires", legacy)
+	}
+	if leased[META_TTL] != "1m0s" {
+		t.Errorf("list-b ttl = %q, want 1m0s", leased[META_TTL])
+	}
+	for _, key := range []string{META_REGISTERED_AT, META_EXPIRES_AT} {
+		if _, err := time.Parse(time.RFC3339, leased[key]); err != nil {
+			t.Errorf("list-b %s = %q: %v", key, leased[key], err)
+		}
+	}
+}
+
+func TestJSONAPI(t *testing.T) {
+	_, ns := startNameServer(t)
+	token, err := ns.Register("127.0.0.1", "9001", "api-server", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As falhas são sinalizadas pelo código de status e pelo código de erro do corpo da resposta
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"POST", "/lookup", `{"name":"api-missing"}`, http.StatusNotFound, "not_registered"},
+		{"GET", "/lookup", ``, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"POST", "/register", `not json`, http.StatusBadRequest, "invalid_request"},
+		{"POST", "/register", `{"version":2,"name":"api-other","host":"127.0.0.1","port":"9002"}`, http.StatusBadRequest, "unsupported_version"},
+		{"POST", "/register", `{"name":"api-other","host":"127.0.0.1","port":"9002","ttl":"soon"}`, http.StatusBadRequest, "invalid_ttl"},
+		{"POST", "/register", `{"name":"api-server","host":"127.0.0.1","port":"9002"}`, http.StatusConflict, "already_registered"},
+		{"POST", "/heartbeat", `{"name":"api-server","token":"wrong"}`, http.StatusForbidden, "invalid_token"},
+		{"POST", "/heartbeat", `{"name":"api-server","token":"` + token + `"}`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, ns.getAddress()+API_PREFIX+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp Response
+		err = json.NewDecoder(httpResp.Body).Decode(&resp)
+		httpResp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+
+		code := ""
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if httpResp.StatusCode != tt.status || code != tt.code || resp.Version != API_VERSION {
+			t.Errorf("%s %s %s = %d %q (version %d), want %d %q",
+				tt.method, tt.path, tt.body, httpResp.StatusCode, code, resp.Version, tt.status, tt.code)
+		}
+	}
+
+	// O protocolo legado resolve os nomes registrados pela API JSON
+	resp, err := http.PostForm(ns.getAddress()+"/lookup", url.Values{"key": {"api-server"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "127.0.0.1:9001" {
+		t.Errorf("legacy lookup = %q, want 127.0.0.1:9001", body)
+	}
+}
+
+func TestUnreachableNameServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	// A falha na comunicação é devolvida como erro, sem encerrar o processo
+	ns := NewNameServerClient(host, port)
+	if _, err := ns.Lookup("server1"); !errors.Is(err, rpcerror.ErrConnectionLost) {
+		t.Errorf("Lookup = %v, want ErrConnectionLost", err)
+	}
+	if _, err := ns.Register("127.0.0.1", "9001", "server1", 0); !errors.Is(err, rpcerror.ErrConnectionLost) {
+		t.Errorf("Register = %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestRegisterRejectsDuplicateName(t *testing.T) {
+	_, owner := startNameServer(t)
+	if _, err := owner.Register("127.0.0.1", "9001", "exclusive", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Outro servidor não pode se registrar com o nome, com ou sem chave de réplica
+	other := *owner
+	if _, err := other.Register("127.0.0.1", "9002", "exclusive", 0); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("Register without replica key = %v, want ErrAlreadyRegistered", err)
+	}
+	other.SetReplicaKey("guess")
+	if _, err := other.Register("127.0.0.1", "9002", "exclusive", 0); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("Register of exclusive name = %v, want ErrAlreadyRegistered", err)
+	}
+
+	refs, err := owner.LookupAll("exclusive")
+	if err != nil || len(refs) != 1 || refs[0].GetPort() != "9001" {
+		t.Fatalf("LookupAll = %v, %v, want only the first instance", refs, err)
+	}
+}
+
+func TestRegisterReplicaRequiresKey(t *testing.T) {
+	_, client := startNameServer(t)
+	replica := *client
+	replica.SetReplicaKey("secret")
+	if _, err := replica.Register("127.0.0.1", "9001", "replicated", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	intruder := replica
+	intruder.SetReplicaKey("wrong")
+	if _, err := intruder.Register("127.0.0.1", "9002", "replicated", 0); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Register with wrong replica key = %v, want ErrInvalidToken", err)
+	}
+	intruder.SetReplicaKey("")
+	if _, err := intruder.Register("127.0.0.1", "9002", "replicated", 0); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("Register without replica key = %v, want ErrAlreadyRegistered", err)
+	}
+
+	if _, err := replica.Register("127.0.0.1", "9002", "replicated", 0); err != nil {
+		t.Fatalf("Register with replica key = %v", err)
+	}
+	refs, err := replica.LookupAll("replicated")
+	if err != nil || len(refs) != 2 {
+		t.Fatalf("LookupAll = %v, %v, want 2 instances", refs, err)
+	}
+}
+
+func TestLegacyRegisterRejectsDuplicateName(t *testing.T) {
+	_, ns := startNameServer(t)
+	register := func(port string) string {
+		resp, err := http.PostForm(ns.getAddress()+"/register", url.Values{"key": {"legacy-exclusive"}, "host": {"127.0.0.1"}, "port": {port}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if got := register("9001"); got != KEY_REGISTERED_SUCCESSFULLY {
+		t.Fatalf("first /register = %q", got)
+	}
+	if got := register("9002"); got != ERR_KEY_ALREADY_REGISTERED {
+		t.Fatalf("second /register = %q, want %q", got, ERR_KEY_ALREADY_REGISTERED)
+	}
+}
+
+func TestWriterCanRegister(t *testing.T) {
+	_, writer := startNameServer(t, auth.NewAuthorizer(map[string]auth.Role{
+		"reader-token": auth.RoleReader,
+		"writer-token": auth.RoleWriter,
+	}))
+	writer.SetToken("writer-token")
+	token, err := writer.Register("127.0.0.1", "9001", "server1", 0)
+	if err != nil {
+		t.Fatalf("Register with writer token = %v", err)
+	}
+	if err := writer.Heartbeat("server1", token); err != nil {
+		t.Fatalf("Heartbeat with writer token = %v", err)
+	}
+
+	reader := *writer
+	reader.SetToken("reader-token")
+	if _, err := reader.Register("127.0.0.1", "9002", "server2", 0); !errors.Is(err, auth.ErrPermissionDenied) {
+		t.Errorf("Register with reader token = %v, want ErrPermissionDenied", err)
+	}
+}
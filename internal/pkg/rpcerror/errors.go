@@ -0,0 +1,75 @@
+// O pacote rpcerror fornece os erros tipados devolvidos pelos clientes do repositório de peças
+// e do serviço de nomes, permitindo que o chamador trate cada categoria de falha sem
+// depender da mensagem do erro.
+//
+// Todos os erros devolvidos pelos clientes são do tipo *Error e podem ser identificados com
+// errors.Is a partir da sua categoria:
+//
+//	if errors.Is(err, rpcerror.ErrNotFound) { ... }
+package rpcerror
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+)
+
+// Categorias de erro
+var (
+	ErrConnectionLost   = errors.New("connection lost") // a conexão com o servidor remoto foi perdida ou recusada
+	ErrTimeout          = errors.New("timeout")         // o servidor remoto não respondeu a tempo
+	ErrCanceled         = errors.New("canceled")        // a chamada foi cancelada pelo chamador
+	ErrNotFound         = errors.New("not found")       // o recurso consultado não existe no servidor remoto
+	ErrPermissionDenied = errors.New("forbidden")       // o token do chamador não possui o papel exigido pela chamada
+	ErrServer           = errors.New("server error")    // o servidor remoto recusou ou falhou ao processar a chamada
+)
+
+// Estrutura Error representa a falha de uma chamada a um servidor remoto.
+type Error struct {
+	Op   string // operação que falhou (ex.: "PartRepository.GetPart")
+	Kind error  // categoria do erro: ErrConnectionLost, ErrTimeout, ErrCanceled, ErrNotFound, ErrPermissionDenied ou ErrServer
+	Err  error  // erro original
+}
+
+// New retorna o ponteiro para uma estrutura Error.
+// Ela recebe como parâmetro a operação que falhou, a categoria do erro e o erro original.
+func New(op string, kind error, err error) *Error {
+	return &Error{Op: op, Kind: kind, Err: err}
+}
+
+// Transport retorna o ponteiro para uma estrutura Error que representa uma falha na comunicação
+// com o servidor remoto, classificada como ErrTimeout, ErrCanceled ou ErrConnectionLost.
+func Transport(op string, err error) *Error {
+	var netErr net.Error
+	if errors.Is(err, context.Canceled) {
+		return New(op, ErrCanceled, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return New(op, ErrTimeout, err)
+	}
+	return New(op, ErrConnectionLost, err)
+}
+
+// IsConnectionError retorna true caso err seja da categoria ErrConnectionLost, isto é, caso a
+// chamada tenha falhado porque a conexão com o servidor remoto foi perdida ou recusada. Nesse caso,
+// a chamada pode não ter chegado ao servidor, e repeti-la numa nova conexão pode ter sucesso.
+func IsConnectionError(err error) bool {
+	return errors.Is(err, ErrConnectionLost)
+}
+
+// Error retorna a descrição do erro.
+func (e *Error) Error() string {
+	return e.Op + ": " + e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap retorna o erro original, permitindo identificá-lo com errors.Is e errors.As.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is retorna true caso target seja a categoria do erro.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
@@ -0,0 +1,61 @@
+package rpcerror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+)
+
+// Estrutura timeoutError representa um erro de rede que sinaliza o fim do prazo de uma operação.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{context.DeadlineExceeded, ErrTimeout},
+		{os.ErrDeadlineExceeded, ErrTimeout},
+		{&net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, ErrTimeout},
+		{io.EOF, ErrConnectionLost},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrConnectionLost},
+	}
+	for _, tt := range tests {
+		err := Transport("PartRepository.GetPart", tt.err)
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("Transport(%v) = %v, want %v", tt.err, err, tt.kind)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Transport(%v) does not wrap the original error", tt.err)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("part not found: bolt")
+	err := error(New("PartRepository.GetPart", ErrNotFound, cause))
+
+	if got, want := err.Error(), "PartRepository.GetPart: not found: part not found: bolt"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, cause) {
+		t.Errorf("errors.Is does not match the category and the cause of %v", err)
+	}
+	if errors.Is(err, ErrServer) || errors.Is(err, ErrTimeout) {
+		t.Errorf("errors.Is matches another category for %v", err)
+	}
+
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Op != "PartRepository.GetPart" || rpcErr.Kind != ErrNotFound {
+		t.Errorf("errors.As = %+v", rpcErr)
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+)
+
+// Estrutura gobServerCodec implementa a interface rpc.ServerCodec com o formato gob, da mesma
+// forma que o codec padrão da biblioteca net/rpc, que não é exportado. Ela permite que o codec
+// gob seja envolvido por outro codec (por exemplo, metrics.RPCMetrics.Wrap) antes de ser
+// entregue ao rpc.Server, o que não é possível com rpc.Server.Accept.
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser // conexão com o cliente
+	dec    *gob.Decoder       // decodificador das requisições
+	enc    *gob.Encoder       // codificador das respostas
+	encBuf *bufio.Writer      // buffer das respostas, enviado a cada resposta completa
+	close  sync.Once          // garante que a conexão seja fechada uma única vez
+}
+
+// NewGobServerCodec retorna um rpc.ServerCodec que atende a conexão conn no formato gob,
+// compatível com os clientes criados por rpc.NewClient. O prazo de cada chamada é contado a
+// partir da leitura da sua requisição (ver Header).
+func NewGobServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	buf := bufio.NewWriter(conn)
+	codec := &gobServerCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf}
+	return &receiveServerCodec{ServerCodec: codec}
+}
+
+// ReadRequestHeader decodifica o cabeçalho da requisição. Implementa a interface rpc.ServerCodec.
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+// ReadRequestBody decodifica os argumentos da requisição. Implementa a interface rpc.ServerCodec.
+func (c *gobServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+// WriteResponse codifica e envia o cabeçalho e o corpo da resposta. Caso a codificação falhe,
+// a conexão é fechada, já que o fluxo gob não pode mais ser interpretado pelo cliente.
+// Implementa a interface rpc.ServerCodec.
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("[!] rpc: gob error encoding response:", err)
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("[!] rpc: gob error encoding body:", err)
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+// Close fecha a conexão, uma única vez, mesmo que seja chamada concorrentemente (por exemplo,
+// por Drainer.Shutdown). Implementa a interface rpc.ServerCodec.
+func (c *gobServerCodec) Close() error {
+	var err error
+	c.close.Do(func() { err = c.rwc.Close() })
+	return err
+}
+
+// ServeCodec aceita conexões do listener e atende as chamadas de cada uma com o servidor RPC
+// informado, utilizando o codec criado por newCodec para a conexão.
+// Assim como rpc.Server.Accept, bloqueia até que o listener seja fechado ou falhe. As chamadas em
+// atendimento não são interrompidas pelo fechamento do listener (ver Drainer).
+func ServeCodec(server *rpc.Server, listener net.Listener, newCodec func(conn io.ReadWriteCloser) rpc.ServerCodec) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// O fechamento do listener é a forma de encerrar o atendimento, e não é reportado
+			if !errors.Is(err, net.ErrClosed) {
+				log.Print("[!] RPC accept: ", err)
+			}
+			return
+		}
+		go server.ServeCodec(newCodec(conn))
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/rpc"
+	"sync"
+)
+
+// Estrutura Drainer acompanha as conexões atendidas por um rpc.Server e as chamadas em
+// atendimento em cada uma, permitindo encerrá-las sem interromper as chamadas já recebidas
+// (ver Shutdown). As conexões são acompanhadas envolvendo o rpc.ServerCodec de cada uma (ver Wrap).
+//
+// A biblioteca net/rpc não oferece uma forma de encerrar um rpc.Server: ServeCodec só retorna
+// quando a leitura de uma requisição falha, e então aguarda as respostas pendentes da conexão
+// antes de fechá-la. O Drainer se apoia nesse comportamento, fazendo a leitura falhar.
+//
+// A estrutura é segura para uso concorrente.
+type Drainer struct {
+	mu      sync.Mutex               // protege as conexões e o encerramento
+	conns   map[*drainCodec]struct{} // conexões abertas
+	closing bool                     // se Shutdown foi chamado
+	drained chan struct{}            // fechado quando, após Shutdown, todas as conexões foram fechadas
+}
+
+// NewDrainer retorna o ponteiro para uma estrutura Drainer sem conexões.
+func NewDrainer() *Drainer {
+	return &Drainer{conns: make(map[*drainCodec]struct{}), drained: make(chan struct{})}
+}
+
+// Wrap retorna um rpc.ServerCodec que delega a codificação ao codec informado e é acompanhado
+// pelo Drainer. Caso Shutdown já tenha sido chamado, a conexão é recusada na primeira requisição.
+func (d *Drainer) Wrap(codec rpc.ServerCodec) rpc.ServerCodec {
+	c := &drainCodec{ServerCodec: codec, drainer: d}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.conns[c] = struct{}{}
+	return c
+}
+
+// Shutdown deixa de aceitar novas chamadas e aguarda o término das chamadas em atendimento, até
+// que o contexto seja cancelado ou o seu prazo vença. As conexões são fechadas assim que não
+// possuem chamadas em atendimento; as requisições recebidas a partir de então são descartadas
+// sem resposta, e o cliente as percebe como uma perda de conexão.
+// Caso o contexto termine antes, as conexões restantes são fechadas, interrompendo as respostas
+// pendentes, e o erro do contexto é devolvido.
+// Os listeners devem ser fechados antes, para que novas conexões não sejam aceitas.
+func (d *Drainer) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closing {
+		d.closing = true
+		d.checkDrained()
+	}
+	var idle []*drainCodec
+	for c := range d.conns {
+		if c.pending == 0 {
+			idle = append(idle, c)
+		}
+	}
+	d.mu.Unlock()
+
+	// Fechar a conexão faz a leitura da próxima requisição falhar, encerrando o seu atendimento
+	for _, c := range idle {
+		c.ServerCodec.Close()
+	}
+
+	select {
+	case <-d.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.mu.Lock()
+	var remaining []*drainCodec
+	for c := range d.conns {
+		remaining = append(remaining, c)
+	}
+	d.mu.Unlock()
+	for _, c := range remaining {
+		c.ServerCodec.Close()
+	}
+	return ctx.Err()
+}
+
+// Pending retorna o número de chamadas em atendimento em todas as conexões.
+func (d *Drainer) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := 0
+	for c := range d.conns {
+		pending += c.pending
+	}
+	return pending
+}
+
+// checkDrained sinaliza o término do encerramento, caso não haja mais conexões abertas.
+// Deve ser chamada com o mutex adquirido.
+func (d *Drainer) checkDrained() {
+	if d.closing && len(d.conns) == 0 {
+		select {
+		case <-d.drained:
+		default:
+			close(d.drained)
+		}
+	}
+}
+
+// Estrutura drainCodec envolve o rpc.ServerCodec de uma conexão acompanhada por um Drainer,
+// contabilizando as chamadas em atendimento, da leitura do cabeçalho da requisição até a escrita
+// da resposta.
+type drainCodec struct {
+	rpc.ServerCodec
+	drainer *Drainer
+	pending int // número de chamadas em atendimento, protegido pelo mutex do Drainer
+}
+
+// ReadRequestHeader lê o cabeçalho da requisição e a contabiliza, ou a descarta caso o Drainer
+// esteja encerrando as conexões. Implementa a interface rpc.ServerCodec.
+func (c *drainCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+
+	c.drainer.mu.Lock()
+	defer c.drainer.mu.Unlock()
+	if c.drainer.closing {
+		// O rpc.Server deixa de ler a conexão e a fecha após as respostas pendentes
+		return io.EOF
+	}
+	c.pending++
+	return nil
+}
+
+// WriteResponse escreve a resposta e, caso o Drainer esteja encerrando as conexões e esta seja a
+// última chamada em atendimento na conexão, fecha a conexão. Implementa a interface rpc.ServerCodec.
+func (c *drainCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	err := c.ServerCodec.WriteResponse(r, body)
+
+	c.drainer.mu.Lock()
+	c.pending--
+	idle := c.drainer.closing && c.pending == 0
+	c.drainer.mu.Unlock()
+	if idle {
+		c.ServerCodec.Close()
+	}
+	return err
+}
+
+// Close fecha a conexão e deixa de acompanhá-la. Implementa a interface rpc.ServerCodec.
+func (c *drainCodec) Close() error {
+	c.drainer.mu.Lock()
+	delete(c.drainer.conns, c)
+	c.drainer.checkDrained()
+	c.drainer.mu.Unlock()
+	return c.ServerCodec.Close()
+}
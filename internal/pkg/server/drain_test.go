@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+// blockingService é um serviço RPC cujo método Wait só responde após o fechamento de release.
+type blockingService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingService) Wait(args int, reply *int) error {
+	s.started <- struct{}{}
+	<-s.release
+	*reply = args
+	return nil
+}
+
+// serveDrained atende o serviço blockingService numa porta livre, com as conexões acompanhadas pelo
+// Drainer retornado, e devolve um cliente conectado a ele.
+func serveDrained(t *testing.T) (*blockingService, net.Listener, *Drainer, *rpc.Client) {
+	t.Helper()
+	service := &blockingService{started: make(chan struct{}, 1), release: make(chan struct{})}
+	server := rpc.NewServer()
+	if err := server.RegisterName("Blocking", service); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainer := NewDrainer()
+	go ServeCodec(server, listener, func(conn io.ReadWriteCloser) rpc.ServerCodec {
+		return drainer.Wrap(NewGobServerCodec(conn))
+	})
+	client, err := rpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return service, listener, drainer, client
+}
+
+func TestDrainerWaitsForInFlightCalls(t *testing.T) {
+	service, listener, drainer, client := serveDrained(t)
+	call := client.Go("Blocking.Wait", 7, new(int), nil)
+	<-service.started
+
+	// O encerramento aguarda a chamada em atendimento
+	listener.Close()
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- drainer.Shutdown(ctx)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v with a call in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := drainer.Pending(); n != 1 {
+		t.Errorf("Pending() = %d, want 1", n)
+	}
+
+	// A chamada é concluída e, em seguida, a conexão é fechada
+	close(service.release)
+	<-call.Done
+	if call.Error != nil || *call.Reply.(*int) != 7 {
+		t.Errorf("in-flight call = %v, %v, want 7", *call.Reply.(*int), call.Error)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if err := client.Call("Blocking.Wait", 1, new(int)); err == nil {
+		t.Error("call after shutdown succeeded")
+	}
+}
+
+func TestDrainerShutdownDeadline(t *testing.T) {
+	service, listener, drainer, client := serveDrained(t)
+	defer close(service.release)
+	call := client.Go("Blocking.Wait", 7, new(int), nil)
+	<-service.started
+
+	// Vencido o prazo, as conexões restantes são fechadas e a chamada pendente é interrompida
+	listener.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := drainer.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	<-call.Done
+	if call.Error == nil {
+		t.Error("in-flight call succeeded after the shutdown deadline")
+	}
+}
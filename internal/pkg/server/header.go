@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"go-rpc/internal/pkg/trace"
+	"net/rpc"
+	"time"
+)
+
+// Estrutura Header representa os metadados de uma chamada remota, enviados pelo cliente junto
+// com os argumentos de todos os métodos expostos pelo PartRepositoryServer.
+//
+// O prazo da chamada é transmitido como o tempo restante, e não como um instante absoluto,
+// para que a diferença entre os relógios do cliente e do servidor não o afete. O servidor
+// passa a contá-lo a partir da leitura da requisição pelo codec (ver NewGobServerCodec e
+// NewJSONServerCodec), e não do início do atendimento, de forma que uma chamada que aguardou a
+// leitura de outras requisições da conexão também tenha esse tempo descontado do seu prazo.
+// No codec JSON-RPC, o tempo restante é representado em nanossegundos.
+//
+// O cabeçalho também identifica a etapa do chamador (ver o pacote trace), da qual o atendimento
+// da chamada passa a ser uma etapa filha. No servidor, após a leitura da chamada, os campos
+// TraceID e SpanID passam a identificar a etapa do atendimento (ver NewTraceServerCodec), de
+// forma que as chamadas feitas durante o atendimento, a partir de Context, sejam filhas dela.
+type Header struct {
+	Timeout time.Duration `json:"timeout"`            // tempo restante até o prazo da chamada no cliente; zero indica ausência de prazo
+	Token   string        `json:"token"`              // token de API do chamador, verificado caso o servidor exija autenticação (ver SetAuthorizer)
+	TraceID string        `json:"trace_id,omitempty"` // identificador do trace da chamada; vazio caso o chamador não o propague
+	SpanID  string        `json:"span_id,omitempty"`  // identificador da etapa do chamador
+
+	received time.Time // instante da leitura da requisição no servidor, não transmitido; zero caso desconhecido
+}
+
+// Interface Args é implementada pelos ponteiros para os argumentos de todos os métodos expostos
+// pelo PartRepositoryServer, permitindo que o cabeçalho seja lido e definido independentemente
+// do método chamado.
+type Args interface {
+	// GetHeader retorna o ponteiro para o cabeçalho da chamada.
+	GetHeader() *Header
+}
+
+// NewHeader retorna uma estrutura Header com o prazo e a etapa corrente do contexto informado.
+// Um prazo já vencido é enviado como o menor prazo possível, para que não seja confundido com
+// a ausência de prazo.
+func NewHeader(ctx context.Context) Header {
+	sc := trace.FromContext(ctx)
+	h := Header{TraceID: sc.TraceID, SpanID: sc.SpanID}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return h
+	}
+	h.Timeout = time.Until(deadline)
+	if h.Timeout <= 0 {
+		h.Timeout = time.Nanosecond
+	}
+	return h
+}
+
+// WithToken retorna uma cópia do cabeçalho com o token de API informado.
+func (h Header) WithToken(token string) Header {
+	h.Token = token
+	return h
+}
+
+// SpanContext retorna a etapa identificada pelo cabeçalho.
+func (h Header) SpanContext() trace.SpanContext {
+	return trace.SpanContext{TraceID: h.TraceID, SpanID: h.SpanID}
+}
+
+// GetHeader retorna o ponteiro para o próprio cabeçalho. Como todos os argumentos dos métodos
+// expostos incluem uma estrutura Header, os ponteiros para eles implementam a interface Args.
+func (h *Header) GetHeader() *Header {
+	return h
+}
+
+// Context retorna um contexto que expira no prazo da chamada, caso haja um, e cuja etapa corrente
+// é a identificada pelo cabeçalho, e a função que libera os seus recursos, que deve ser chamada
+// ao final do atendimento da chamada. O prazo é contado a partir da leitura da requisição ou,
+// caso o cabeçalho não tenha sido lido por um codec do servidor, a partir da chamada de Context.
+func (h Header) Context() (context.Context, context.CancelFunc) {
+	ctx := trace.ContextWithSpanContext(context.Background(), h.SpanContext())
+	if h.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	if h.received.IsZero() {
+		return context.WithTimeout(ctx, h.Timeout)
+	}
+	return context.WithDeadline(ctx, h.received.Add(h.Timeout))
+}
+
+// Estrutura receiveServerCodec envolve um rpc.ServerCodec, registrando no cabeçalho de cada
+// chamada o instante em que a sua requisição foi lida, a partir do qual o prazo é contado.
+// Assim como em traceServerCodec, o cabeçalho e os argumentos de cada requisição são lidos em
+// sequência, numa única goroutine.
+type receiveServerCodec struct {
+	rpc.ServerCodec
+	received time.Time // instante da leitura do cabeçalho da última requisição
+}
+
+// ReadRequestHeader lê o cabeçalho da requisição e registra o instante da leitura.
+// Implementa a interface rpc.ServerCodec.
+func (c *receiveServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	c.received = time.Now()
+	return err
+}
+
+// ReadRequestBody lê os argumentos da requisição e registra no seu cabeçalho o instante da
+// leitura da requisição. Implementa a interface rpc.ServerCodec.
+func (c *receiveServerCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	if args, ok := body.(Args); ok {
+		args.GetHeader().received = c.received
+	}
+	return nil
+}
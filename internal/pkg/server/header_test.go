@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/types"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+// slowBodyCodec simula um rpc.ServerCodec cujos argumentos demoram delay para serem lidos, como
+// numa requisição grande recebida por uma rede lenta.
+type slowBodyCodec struct {
+	rpc.ServerCodec
+	args  CodeArgs      // argumentos devolvidos pela leitura
+	delay time.Duration // duração da leitura dos argumentos
+}
+
+func (c *slowBodyCodec) ReadRequestHeader(r *rpc.Request) error {
+	r.ServiceMethod = "PartRepository.GetPart"
+	return nil
+}
+
+func (c *slowBodyCodec) ReadRequestBody(body interface{}) error {
+	time.Sleep(c.delay)
+	*body.(*CodeArgs) = c.args
+	return nil
+}
+
+func TestDeadlineCountsFromRequestRead(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	codec := &receiveServerCodec{ServerCodec: &slowBodyCodec{
+		args:  CodeArgs{Header: Header{Timeout: 20 * time.Millisecond}, Code: "code"},
+		delay: 50 * time.Millisecond,
+	}}
+
+	var req rpc.Request
+	var args CodeArgs
+	if err := codec.ReadRequestHeader(&req); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.ReadRequestBody(&args); err != nil {
+		t.Fatal(err)
+	}
+
+	// O prazo venceu durante a leitura dos argumentos, e a chamada é descartada
+	var part interfaces.Part
+	if err := srv.GetPart(args, &part); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetPart = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Um cabeçalho que não foi lido por um codec conta o prazo a partir de Context
+	args.received = time.Time{}
+	if err := srv.GetPart(args, &part); !errors.Is(err, types.ErrPartNotFound) {
+		t.Errorf("GetPart without read time = %v, want ErrPartNotFound", err)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-rpc/types"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+// O servidor RPC também pode ser exposto com o codec JSON-RPC 1.0 da biblioteca net/rpc/jsonrpc,
+// para clientes que não são escritos em Go e, portanto, não falam gob. Os métodos e os seus
+// argumentos são os mesmos; cada chamada é um objeto JSON com o nome do método e uma lista com
+// um único elemento, os argumentos:
+//
+//	{"method": "PartRepository.GetPart", "params": [{"code": "..."}], "id": 1}
+//
+// As peças são codificadas com discriminadores de tipo (ver types.PartImpl.MarshalJSON).
+
+// UnmarshalJSON decodifica os argumentos de AddPart e UpdatePart a partir da sua codificação JSON.
+// Como o campo Part é uma interface, a peça é decodificada no tipo concreto indicado pelo seu
+// discriminador (ver types.UnmarshalPart). Uma peça ausente ou nula é recusada com ErrInvalidRequest.
+// Implementa a interface json.Unmarshaler.
+func (a *PartArgs) UnmarshalJSON(data []byte) error {
+	var in struct {
+		Header
+		Part json.RawMessage `json:"part"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	part, err := types.UnmarshalPart(in.Part)
+	if err != nil {
+		return err
+	}
+	if part == nil {
+		return fmt.Errorf("%w: missing part", ErrInvalidRequest)
+	}
+	*a = PartArgs{Header: in.Header, Part: part}
+	return nil
+}
+
+// ServeJSON aceita conexões do listener e atende as chamadas de cada uma com o codec JSON-RPC,
+// utilizando o servidor RPC informado, no qual o PartRepositoryServer deve estar registrado.
+// Assim como rpc.Server.Accept, bloqueia até que o listener seja fechado ou falhe.
+func ServeJSON(server *rpc.Server, listener net.Listener) {
+	ServeCodec(server, listener, NewJSONServerCodec)
+}
+
+// NewJSONServerCodec retorna um rpc.ServerCodec que atende a conexão conn com o codec JSON-RPC.
+// O prazo de cada chamada é contado a partir da leitura da sua requisição (ver Header).
+func NewJSONServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &receiveServerCodec{ServerCodec: jsonrpc.NewServerCodec(conn)}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/types"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+)
+
+// serveJSONPipe atende o servidor srv com o codec JSON-RPC numa conexão em memória e retorna a
+// outra ponta da conexão, na qual o teste escreve as requisições e lê as respostas.
+func serveJSONPipe(t *testing.T, srv *PartRepositoryServer) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("PartRepository", srv); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go rpcServer.ServeCodec(NewJSONServerCodec(serverConn))
+	t.Cleanup(func() { clientConn.Close() })
+	return clientConn, bufio.NewReader(clientConn)
+}
+
+func TestJSONRPCRejectsNullPart(t *testing.T) {
+	repo := new(types.PartRepositoryImpl)
+	conn, reader := serveJSONPipe(t, NewPartRepositoryServer(repo))
+
+	requests := []string{
+		`{"method":"PartRepository.AddPart","params":[{"part":null}],"id":1}`,
+		`{"method":"PartRepository.AddPart","params":[{}],"id":2}`,
+		`{"method":"PartRepository.UpdatePart","params":[{"part":null}],"id":3}`,
+		`{"method":"PartRepository.AddPart","params":[{"part":{"type":"part","name":"x","subcomponents":[null]}}],"id":4}`,
+	}
+	for _, request := range requests {
+		if _, err := conn.Write([]byte(request + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("%s: server stopped responding: %v", request, err)
+		}
+		var response struct {
+			Result json.RawMessage `json:"result"`
+			Error  *string         `json:"error"`
+		}
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error == nil {
+			t.Errorf("%s: got result %s, want an error", request, response.Result)
+		}
+	}
+
+	// O servidor continua atendendo após as requisições inválidas
+	request := `{"method":"PartRepository.AddPart","params":[{"part":{"type":"part","name":"bolt"}}],"id":5}`
+	if _, err := conn.Write([]byte(request + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil || !strings.Contains(string(line), `"error":null`) {
+		t.Fatalf("valid AddPart failed: %s %v", line, err)
+	}
+	if n := len(repo.GetParts()); n != 1 {
+		t.Fatalf("repository has %d parts, want 1", n)
+	}
+}
+
+func TestAddPartRejectsNilPart(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	var reply interfaces.Part
+
+	if err := srv.AddPart(&PartArgs{}, &reply); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("AddPart(nil) = %v, want ErrInvalidRequest", err)
+	}
+	if err := srv.UpdatePart(&PartArgs{}, &reply); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("UpdatePart(nil) = %v, want ErrInvalidRequest", err)
+	}
+
+	part := types.NewPartImpl("frame", "")
+	part.Subcomponents = []interfaces.Pair{nil}
+	if err := srv.AddPart(&PartArgs{Part: part}, &reply); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("AddPart(nil subcomponent) = %v, want ErrInvalidRequest", err)
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/trace"
+	"go-rpc/types"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Prefixo das rotas da API REST.
+const REST_PREFIX = "/parts"
+
+// MaxRESTBodySize é o tamanho máximo, em bytes, do corpo das requisições da API REST. Corpos
+// maiores são recusados com ErrRequestTooLarge, sem que sejam lidos por completo.
+const MaxRESTBodySize = 4 << 20
+
+// ErrRequestTooLarge é devolvido pela API REST quando o corpo da requisição excede MaxRESTBodySize.
+var ErrRequestTooLarge = errors.New("request too large")
+
+// Estrutura restError associa um erro do repositório ao código de status HTTP e ao código de
+// erro devolvidos pela API REST.
+type restError struct {
+	err    error  // erro do repositório
+	status int    // código de status HTTP
+	code   string // código do erro no corpo da resposta
+}
+
+// Tabela de erros da API REST
+var restErrors = []restError{
+	{types.ErrPartNotFound, http.StatusNotFound, "part_not_found"},
+	{types.ErrPartAlreadyExists, http.StatusConflict, "part_already_exists"},
+	{types.ErrPartInUse, http.StatusConflict, "part_in_use"},
+	{types.ErrPartCycle, http.StatusUnprocessableEntity, "part_cycle"},
+	{types.ErrPartTooDeep, http.StatusUnprocessableEntity, "part_too_deep"},
+	{types.ErrInvalidPageToken, http.StatusBadRequest, "invalid_page_token"},
+	{ErrInvalidRequest, http.StatusBadRequest, "invalid_request"},
+	{ErrRequestTooLarge, http.StatusRequestEntityTooLarge, "request_too_large"},
+	{auth.ErrPermissionDenied, http.StatusForbidden, "permission_denied"},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, "deadline_exceeded"},
+}
+
+// Estrutura RESTError representa o corpo das respostas de erro da API REST.
+type RESTError struct {
+	Error struct {
+		Code    string `json:"code"`    // código do erro (ex.: "part_not_found")
+		Message string `json:"message"` // descrição do erro
+	} `json:"error"`
+}
+
+// Estrutura DeleteResponse representa o corpo da resposta de DELETE REST_PREFIX/{code}.
+type DeleteResponse struct {
+	Deleted []string `json:"deleted"` // códigos das peças removidas
+}
+
+// NewRESTHandler retorna um http.Handler que expõe o PartRepositoryServer informado como uma API REST:
+//
+//	GET    REST_PREFIX?page_size=&page_token=  -> ListPartsReply
+//	GET    REST_PREFIX/{code}                  -> peça
+//	POST   REST_PREFIX                         peça -> peça criada (201)
+//	DELETE REST_PREFIX/{code}?cascade=true     -> DeleteResponse
+//
+// As rotas chamam os mesmos métodos expostos via RPC, de forma que as peças sejam validadas da
+// mesma forma e os erros sejam os mesmos, sinalizados com o código de status HTTP correspondente
+// e um corpo RESTError. As peças são codificadas em JSON com discriminadores de tipo, como no
+// codec JSON-RPC (ver types.PartImpl.MarshalJSON).
+//
+// O prazo do contexto da requisição, caso haja um, é repassado aos métodos como o prazo da chamada,
+// e o token do cabeçalho Authorization, no esquema Bearer, como o token da chamada. Cada requisição
+// é atendida numa etapa do trace do cabeçalho traceparent, registrada no log (ver trace.Handler).
+// O corpo das requisições é limitado a MaxRESTBodySize bytes.
+func NewRESTHandler(p *PartRepositoryServer) http.Handler {
+	return trace.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, MaxRESTBodySize)
+
+		// As rotas são interpretadas manualmente, já que o http.ServeMux não extrai parâmetros do caminho
+		path := r.URL.Path
+		code := strings.TrimPrefix(path, REST_PREFIX+"/")
+		switch {
+		case path == REST_PREFIX:
+			switch r.Method {
+			case http.MethodGet:
+				restHandle(w, http.StatusOK, func() (interface{}, error) { return p.restList(r) })
+			case http.MethodPost:
+				restHandle(w, http.StatusCreated, func() (interface{}, error) { return p.restAdd(r) })
+			default:
+				methodNotAllowed(w, "GET, POST")
+			}
+		case code != path && code != "" && !strings.Contains(code, "/"):
+			switch r.Method {
+			case http.MethodGet:
+				restHandle(w, http.StatusOK, func() (interface{}, error) { return p.restGet(r, code) })
+			case http.MethodDelete:
+				restHandle(w, http.StatusOK, func() (interface{}, error) { return p.restDelete(r, code) })
+			default:
+				methodNotAllowed(w, "GET, DELETE")
+			}
+		default:
+			writeRESTError(w, http.StatusNotFound, "not_found", "no route for "+path)
+		}
+	}))
+}
+
+// restHeader retorna o cabeçalho da chamada correspondente à requisição HTTP r, com o prazo e a
+// etapa corrente do seu contexto.
+func restHeader(r *http.Request) Header {
+	return NewHeader(r.Context()).WithToken(auth.BearerToken(r))
+}
+
+// restList atende GET REST_PREFIX, devolvendo uma página das peças (ver ListParts).
+func (p *PartRepositoryServer) restList(r *http.Request) (interface{}, error) {
+	args := ListPartsArgs{Header: restHeader(r), PageToken: r.URL.Query().Get("page_token")}
+	if size := r.URL.Query().Get("page_size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return nil, ErrInvalidRequest
+		}
+		args.PageSize = n
+	}
+
+	var reply ListPartsReply
+	if err := p.ListParts(args, &reply); err != nil {
+		return nil, err
+	}
+	if reply.Parts == nil {
+		// Uma página vazia é devolvida como uma lista vazia, e não como null
+		reply.Parts = []interfaces.Part{}
+	}
+	return &reply, nil
+}
+
+// restGet atende GET REST_PREFIX/{code}, devolvendo a peça com o código informado (ver GetPart).
+func (p *PartRepositoryServer) restGet(r *http.Request, code string) (interface{}, error) {
+	var part interfaces.Part
+	if err := p.GetPart(CodeArgs{Header: restHeader(r), Code: code}, &part); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+// restAdd atende POST REST_PREFIX, adicionando a peça do corpo da requisição (ver AddPart).
+func (p *PartRepositoryServer) restAdd(r *http.Request) (interface{}, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		// O http.MaxBytesReader interrompe a leitura ao atingir o limite
+		if len(body) >= MaxRESTBodySize {
+			return nil, fmt.Errorf("%w: body exceeds %d bytes", ErrRequestTooLarge, MaxRESTBodySize)
+		}
+		return nil, ErrInvalidRequest
+	}
+	part, err := types.UnmarshalPart(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if part == nil {
+		return nil, ErrInvalidRequest
+	}
+
+	var added interfaces.Part
+	if err := p.AddPart(&PartArgs{Header: restHeader(r), Part: part}, &added); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// restDelete atende DELETE REST_PREFIX/{code}, removendo a peça com o código informado e, caso o
+// parâmetro cascade seja verdadeiro, as peças que a utilizam (ver DeletePart).
+func (p *PartRepositoryServer) restDelete(r *http.Request, code string) (interface{}, error) {
+	args := DeletePartArgs{Header: restHeader(r), Code: code}
+	if cascade := r.URL.Query().Get("cascade"); cascade != "" {
+		b, err := strconv.ParseBool(cascade)
+		if err != nil {
+			return nil, ErrInvalidRequest
+		}
+		args.Cascade = b
+	}
+
+	var deleted []string
+	if err := p.DeletePart(args, &deleted); err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{Deleted: deleted}, nil
+}
+
+// restHandle executa a rota handler e escreve o seu resultado com o código de status informado,
+// ou o erro com o código de status correspondente. Erros desconhecidos são tratados como erros
+// internos do servidor.
+func restHandle(w http.ResponseWriter, status int, handler func() (interface{}, error)) {
+	v, err := handler()
+	if err == nil {
+		writeREST(w, status, v)
+		return
+	}
+	for _, e := range restErrors {
+		if errors.Is(err, e.err) {
+			writeRESTError(w, e.status, e.code, err.Error())
+			return
+		}
+	}
+	writeRESTError(w, http.StatusInternalServerError, "internal", err.Error())
+}
+
+// methodNotAllowed escreve a resposta de uma requisição com um método não suportado pela rota.
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeRESTError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only "+allow+" methods are supported")
+}
+
+// writeRESTError escreve o corpo RESTError com o código de erro e a mensagem informados.
+func writeRESTError(w http.ResponseWriter, status int, code string, message string) {
+	var body RESTError
+	body.Error.Code = code
+	body.Error.Message = message
+	writeREST(w, status, &body)
+}
+
+// writeREST escreve v como o corpo JSON da resposta, com o código de status informado.
+func writeREST(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("[!] Failed to write response:", err)
+	}
+}
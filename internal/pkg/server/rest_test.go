@@ -0,0 +1,231 @@
+package server
+
+import (
+	"encoding/json"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRESTRejectsLargeBody(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	ts := httptest.NewServer(NewRESTHandler(srv))
+	defer ts.Close()
+
+	body := `{"name":"` + strings.Repeat("x", MaxRESTBodySize) + `"}`
+	resp, err := http.Post(ts.URL+REST_PREFIX, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var restErr RESTError
+	if err := json.NewDecoder(resp.Body).Decode(&restErr); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || restErr.Error.Code != "request_too_large" {
+		t.Errorf("POST of large body = %d %q, want 413 request_too_large", resp.StatusCode, restErr.Error.Code)
+	}
+}
+
+// restDo envia ao servidor ts uma requisição com o método, o caminho, o token e o corpo
+// informados, e retorna o código de status e o corpo da resposta.
+func restDo(t *testing.T, ts *httptest.Server, method string, path string, token string, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// restErrorCode retorna o código do erro do corpo de uma resposta de erro.
+func restErrorCode(body []byte) string {
+	var restErr RESTError
+	json.Unmarshal(body, &restErr)
+	return restErr.Error.Code
+}
+
+// partJSON retorna a codificação JSON da peça com o nome e os subcomponentes informados.
+func partJSON(t *testing.T, name string, subs ...interfaces.Pair) string {
+	t.Helper()
+	part := types.NewPartImpl(name, "")
+	part.SetSubcomponents(subs)
+	data, err := json.Marshal(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRESTListPages(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+	ts := httptest.NewServer(NewRESTHandler(srv))
+	defer ts.Close()
+	const total = 7
+	for i := 0; i < total; i++ {
+		var added interfaces.Part
+		if err := srv.AddPart(&PartArgs{Part: types.NewPartImpl("bolt", "")}, &added); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// As páginas são percorridas com o token de continuação, sem repetir nem omitir peças
+	seen := make(map[string]bool)
+	last, token, pages := "", "", 0
+	for {
+		status, body := restDo(t, ts, http.MethodGet, REST_PREFIX+"?page_size=3&page_token="+token, "", "")
+		if status != http.StatusOK {
+			t.Fatalf("GET page %d = %d %s", pages, status, body)
+		}
+		var page struct {
+			Parts         []json.RawMessage `json:"parts"`
+			NextPageToken string            `json:"next_page_token"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, data := range page.Parts {
+			part, err := types.UnmarshalPart(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[part.GetCode()] || part.GetCode() <= last {
+				t.Fatalf("page %d repeats or reorders part %s", pages, part.GetCode())
+			}
+			seen[part.GetCode()] = true
+			last = part.GetCode()
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		token = page.NextPageToken
+	}
+	if len(seen) != total || pages != 3 {
+		t.Errorf("listed %d parts in %d pages, want %d parts in 3 pages", len(seen), pages, total)
+	}
+
+	for query, code := range map[string]string{"?page_token=!!!": "invalid_page_token", "?page_size=many": "invalid_request"} {
+		status, body := restDo(t, ts, http.MethodGet, REST_PREFIX+query, "", "")
+		if status != http.StatusBadRequest || restErrorCode(body) != code {
+			t.Errorf("GET %s = %d %s, want 400 %s", query, status, body, code)
+		}
+	}
+}
+
+func TestRESTGetAndDelete(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+	ts := httptest.NewServer(NewRESTHandler(srv))
+	defer ts.Close()
+
+	var wheel, axle interfaces.Part
+	if err := srv.AddPart(&PartArgs{Part: types.NewPartImpl("wheel", "")}, &wheel); err != nil {
+		t.Fatal(err)
+	}
+	part := types.NewPartImpl("axle", "")
+	part.SetSubcomponents([]interfaces.Pair{types.NewPairRefImpl("repo", wheel.GetCode(), 2)})
+	if err := srv.AddPart(&PartArgs{Part: part}, &axle); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, REST_PREFIX + "/" + wheel.GetCode(), http.StatusOK, ""},
+		{http.MethodGet, REST_PREFIX + "/missing", http.StatusNotFound, "part_not_found"},
+		{http.MethodDelete, REST_PREFIX + "/missing", http.StatusNotFound, "part_not_found"},
+		{http.MethodDelete, REST_PREFIX + "/" + wheel.GetCode(), http.StatusConflict, "part_in_use"},
+		{http.MethodDelete, REST_PREFIX + "/" + wheel.GetCode() + "?cascade=maybe", http.StatusBadRequest, "invalid_request"},
+		{http.MethodPut, REST_PREFIX + "/" + wheel.GetCode(), http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, REST_PREFIX + "/a/b", http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		status, body := restDo(t, ts, tt.method, tt.path, "", "")
+		if status != tt.status || (tt.code != "" && restErrorCode(body) != tt.code) {
+			t.Errorf("%s %s = %d %s, want %d %s", tt.method, tt.path, status, body, tt.status, tt.code)
+		}
+	}
+
+	// A remoção em cascata devolve os códigos das peças removidas
+	status, body := restDo(t, ts, http.MethodDelete, REST_PREFIX+"/"+wheel.GetCode()+"?cascade=true", "", "")
+	var deleted DeleteResponse
+	json.Unmarshal(body, &deleted)
+	if status != http.StatusOK || len(deleted.Deleted) != 2 {
+		t.Errorf("cascading DELETE = %d %s, want 200 with 2 deleted parts", status, body)
+	}
+	if status, _ := restDo(t, ts, http.MethodGet, REST_PREFIX+"/"+axle.GetCode(), "", ""); status != http.StatusNotFound {
+		t.Errorf("GET of a part deleted in cascade = %d, want 404", status)
+	}
+}
+
+func TestRESTAddValidationAndRoles(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+	srv.SetMaxDepth(1)
+	srv.SetAuthorizer(auth.NewAuthorizer(map[string]auth.Role{"reader": auth.RoleReader, "writer": auth.RoleWriter, "admin": auth.RoleAdmin}))
+	ts := httptest.NewServer(NewRESTHandler(srv))
+	defer ts.Close()
+
+	status, body := restDo(t, ts, http.MethodPost, REST_PREFIX, "writer", partJSON(t, "wheel"))
+	if status != http.StatusCreated {
+		t.Fatalf("POST = %d %s, want 201", status, body)
+	}
+	wheel, err := types.UnmarshalPart(body)
+	if err != nil || wheel.GetCode() == "" {
+		t.Fatalf("POST returned %s: %v", body, err)
+	}
+	status, body = restDo(t, ts, http.MethodPost, REST_PREFIX, "writer", partJSON(t, "axle", types.NewPairRefImpl("repo", wheel.GetCode(), 2)))
+	if status != http.StatusCreated {
+		t.Fatalf("POST = %d %s, want 201", status, body)
+	}
+	axle, err := types.UnmarshalPart(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                string
+		method, path, token string
+		body                string
+		status              int
+		code                string
+	}{
+		{"no token", http.MethodGet, REST_PREFIX + "/" + wheel.GetCode(), "", "", http.StatusForbidden, "permission_denied"},
+		{"reader adds", http.MethodPost, REST_PREFIX, "reader", partJSON(t, "bolt"), http.StatusForbidden, "permission_denied"},
+		{"writer cascades", http.MethodDelete, REST_PREFIX + "/" + wheel.GetCode() + "?cascade=true", "writer", "", http.StatusForbidden, "permission_denied"},
+		{"malformed json", http.MethodPost, REST_PREFIX, "writer", `{"type":`, http.StatusBadRequest, "invalid_request"},
+		{"missing type", http.MethodPost, REST_PREFIX, "writer", `{"name":"bolt"}`, http.StatusBadRequest, "invalid_request"},
+		{"missing reference", http.MethodPost, REST_PREFIX, "writer", partJSON(t, "cart", types.NewPairRefImpl("repo", "missing", 1)), http.StatusNotFound, "part_not_found"},
+		{"too deep", http.MethodPost, REST_PREFIX, "writer", partJSON(t, "cart", types.NewPairRefImpl("repo", axle.GetCode(), 1)), http.StatusUnprocessableEntity, "part_too_deep"},
+		{"reader gets", http.MethodGet, REST_PREFIX + "/" + wheel.GetCode(), "reader", "", http.StatusOK, ""},
+		{"admin cascades", http.MethodDelete, REST_PREFIX + "/" + wheel.GetCode() + "?cascade=true", "admin", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		status, body := restDo(t, ts, tt.method, tt.path, tt.token, tt.body)
+		if status != tt.status || (tt.code != "" && restErrorCode(body) != tt.code) {
+			t.Errorf("%s: %s %s = %d %s, want %d %s", tt.name, tt.method, tt.path, status, body, tt.status, tt.code)
+		}
+	}
+}
@@ -3,7 +3,13 @@
 package server
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/types"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -36,9 +42,18 @@ import (
 // O valor de retorno do método, se não for nulo, é passado de volta como uma string que o cliente
 // vê como se tivesse sido criada por errors.New. Se um erro for retornado, o parâmetro de resposta
 // não será enviado de volta ao cliente.
+//
+// A biblioteca net/rpc atende cada chamada numa goroutine própria, portanto os métodos
+// expostos podem executar concorrentemente. O objeto PartRepository fornecido deve ser
+// seguro para uso concorrente, como types.PartRepositoryImpl.
 type PartRepositoryServer struct {
 	partRepository interfaces.PartRepository // objeto PartRepository
+	mu             sync.RWMutex              // protege a referência, a profundidade máxima, o PartResolver e o Authorizer
 	ref            interfaces.RemoteRef      // referência do servidor remoto
+	maxDepth       int                       // profundidade máxima da composição das peças (ver validate)
+	resolver       PartResolver              // consulta as peças de outros repositórios (ver validate)
+	authorizer     *auth.Authorizer          // verifica os papéis dos tokens das chamadas, nulo caso não haja autenticação
+	updateMu       sync.Mutex                // serializa a validação e a escrita das inclusões, alterações e remoções (ver UpdatePart)
 }
 
 // NewPartRepositoryServer retorna o ponteiro para uma estrutura PartRepositoryServer.
@@ -47,44 +62,372 @@ func NewPartRepositoryServer(p interfaces.PartRepository) *PartRepositoryServer
 	return &PartRepositoryServer{partRepository: p}
 }
 
+// Estrutura PartArgs representa os argumentos das chamadas remotas AddPart e UpdatePart.
+type PartArgs struct {
+	Header
+	Part interfaces.Part `json:"part"` // peça a ser adicionada ou alterada
+}
+
+// Estrutura CodeArgs representa os argumentos da chamada remota GetPart.
+type CodeArgs struct {
+	Header
+	Code string `json:"code"` // código da peça
+}
+
+// Estrutura NameArgs representa os argumentos das chamadas remotas FindPartsByName e FindPartsByNamePrefix.
+type NameArgs struct {
+	Header
+	Name string `json:"name"` // nome, ou prefixo do nome, das peças buscadas
+}
+
+// Estrutura DeletePartArgs representa os argumentos da chamada remota DeletePart.
+type DeletePartArgs struct {
+	Header
+	Code    string `json:"code"`    // código da peça a ser removida
+	Cascade bool   `json:"cascade"` // se as peças que utilizam a peça também devem ser removidas
+}
+
+// Estrutura WhereUsedArgs representa os argumentos da chamada remota WhereUsed.
+type WhereUsedArgs struct {
+	Header
+	Code      string `json:"code"`      // código da peça
+	Recursive bool   `json:"recursive"` // se as peças que utilizam a peça indiretamente também devem ser devolvidas
+}
+
+// Estrutura ListPartsArgs representa os argumentos da chamada remota ListParts.
+type ListPartsArgs struct {
+	Header
+	PageSize  int    `json:"page_size"`  // número máximo de peças da página; zero indica DefaultPageSize
+	PageToken string `json:"page_token"` // token de continuação devolvido pela página anterior; vazio indica a primeira página
+}
+
+// Estrutura ListPartsReply representa o resultado da chamada remota ListParts.
+type ListPartsReply struct {
+	Parts         []interfaces.Part `json:"parts"`           // peças da página, em ordem crescente de código
+	NextPageToken string            `json:"next_page_token"` // token de continuação da próxima página; vazio indica a última página
+}
+
+// DefaultPageSize é o tamanho padrão das páginas de ListParts, e MaxPageSize o maior tamanho
+// aceito; páginas maiores são reduzidas a MaxPageSize.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// ErrInvalidRequest é devolvido pela API REST e pelos métodos expostos via RPC quando a requisição
+// não pode ser interpretada, por exemplo por não conter a peça a ser adicionada.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Todos os métodos expostos recebem, junto com os argumentos, uma estrutura Header com o prazo da
+// chamada, contado a partir da leitura da requisição (ver Header). Caso o prazo vença antes do
+// atendimento, o chamador já desistiu da chamada e ela é descartada, devolvendo context.DeadlineExceeded. As escritas são descartadas apenas antes de
+// serem aplicadas ao repositório; as consultas também são descartadas antes do envio da resposta.
+//
+// Caso um auth.Authorizer tenha sido definido (ver SetAuthorizer), o cabeçalho também carrega o
+// token do chamador, e a chamada é recusada com auth.ErrPermissionDenied caso o token não possua
+// o papel exigido: auth.RoleReader para as consultas, auth.RoleWriter para as alterações e
+// auth.RoleAdmin para as remoções em cascata.
+
 // AddPart adiciona uma peça ao repositório de peças da estrutura PartRepositoryServer.
 // Ela atribui um identificador único à peça e define a sua referência ao servidor remoto como a
 // referência remota do próprio servidor.
-// Recebe como parâmetros um ponteiro para uma peça, que será adicionada à lista, e um ponteiro para uma peça, que passará
+// Recebe como parâmetros os argumentos da chamada, com a peça que será adicionada à lista, e um ponteiro para uma peça, que passará
 // a apontar à própria peça inserida, após definir o valor identificador e a referência remota do servidor.
-// Retorna por padrão nulo, sinalizando que não houve erro na comunicação.
-func (p *PartRepositoryServer) AddPart(part *interfaces.Part, reply *interfaces.Part) error {
+// A composição da peça é validada antes da inserção (ver validate), e a peça é recusada com
+// types.ErrPartCycle ou types.ErrPartTooDeep caso contenha um ciclo ou exceda a profundidade máxima,
+// ou com types.ErrPartNotFound caso referencie uma peça inexistente. A validação e a inserção são
+// feitas sob o mesmo mutex de UpdatePart e DeletePart, de forma que uma peça referenciada não seja
+// removida entre a validação e a inserção.
+// Retorna ErrInvalidRequest caso a peça, ou algum dos seus subcomponentes, seja nula.
+// Retorna nulo, ou o erro devolvido pelo repositório caso a peça não possa ser armazenada.
+func (p *PartRepositoryServer) AddPart(args *PartArgs, reply *interfaces.Part) error {
+	if err := p.authorize(args.Header, auth.RoleWriter); err != nil {
+		return err
+	}
+	if err := checkPart(args.Part); err != nil {
+		return err
+	}
+	ctx, cancel := args.Context()
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Gera novo identificador
 	id := uuid.New().String()
 
 	// Altera o código do objeto e a referência ao servidor
-	(*part).SetCode(id)
-	(*part).SetRef(p.ref)
+	args.Part.SetCode(id)
+	args.Part.SetRef(p.getRef())
+
+	p.updateMu.Lock()
+	defer p.updateMu.Unlock()
+	if err := p.validate(ctx, args.Part); err != nil {
+		return err
+	}
 
 	// Adiciona a peça usando a API do objeto PartRepository
-	p.partRepository.AddPart(*part)
+	if err := p.partRepository.AddPart(args.Part); err != nil {
+		return err
+	}
 
 	// Armazena no segundo parâmetro o endereço de memória peça adicionada
-	*reply = *part
+	*reply = args.Part
 
 	return nil
 }
 
 // GetPart consulta uma peça a partir de um código no repositório de peças e a retorna ao usuário.
-// Recebe como parâmetros uma string, que indica o código da peça a ser buscada
+// Recebe como parâmetros os argumentos da chamada, com o código da peça a ser buscada,
 // e um ponteiro para uma peça, que passará a apontar para a peça buscada, caso seja encontrada.
-// Retorna por padrão nulo, sinalizando que não houve erro na comunicação.
-func (p *PartRepositoryServer) GetPart(code string, out *interfaces.Part) error {
-	*out = p.partRepository.GetPart(code)
+// Retorna types.ErrPartNotFound caso não exista peça com o código informado.
+func (p *PartRepositoryServer) GetPart(args CodeArgs, out *interfaces.Part) error {
+	if err := p.authorize(args.Header, auth.RoleReader); err != nil {
+		return err
+	}
+	ctx, cancel := args.Context()
+	defer cancel()
+
+	part := p.partRepository.GetPart(args.Code)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if part == nil {
+		return fmt.Errorf("%w: %s", types.ErrPartNotFound, args.Code)
+	}
+	*out = part
+	return nil
+}
+
+// GetParts retorna uma cópia da lista de peças do repositório de peças, que reflete o estado
+// do repositório no momento da chamada.
+// Recebe como parâmetros o cabeçalho da chamada, já que o método não possui outros argumentos,
+// e um ponteiro para uma lista de peça, na qual será armazenada, que passará a apontar para uma cópia da lista de peças do objeto PartRepository.
+// Retorna nulo, sinalizando que não houve erro na comunicação, ou o erro do prazo da chamada.
+func (p *PartRepositoryServer) GetParts(h Header, out *[]interfaces.Part) error {
+	return p.query(h, out, p.partRepository.GetParts)
+}
+
+// FindPartsByName consulta as peças do repositório cujo nome é exatamente igual ao nome informado,
+// utilizando o índice de nomes do repositório.
+// Recebe como parâmetros os argumentos da chamada, com o nome buscado, e um ponteiro para a lista na qual as peças encontradas são armazenadas.
+func (p *PartRepositoryServer) FindPartsByName(args NameArgs, out *[]interfaces.Part) error {
+	return p.query(args.Header, out, func() []interfaces.Part {
+		return p.partRepository.FindPartsByName(args.Name)
+	})
+}
+
+// FindPartsByNamePrefix consulta as peças do repositório cujo nome começa com o prefixo informado,
+// utilizando o índice de nomes do repositório.
+// Recebe como parâmetros os argumentos da chamada, com o prefixo buscado, e um ponteiro para a lista na qual as peças encontradas são armazenadas.
+func (p *PartRepositoryServer) FindPartsByNamePrefix(args NameArgs, out *[]interfaces.Part) error {
+	return p.query(args.Header, out, func() []interfaces.Part {
+		return p.partRepository.FindPartsByNamePrefix(args.Name)
+	})
+}
+
+// GetPrimitiveParts retorna a lista de peças primitivas do repositório de peças.
+// Assim como em GetParts, o primeiro parâmetro é apenas o cabeçalho da chamada.
+func (p *PartRepositoryServer) GetPrimitiveParts(h Header, out *[]interfaces.Part) error {
+	return p.query(h, out, p.partRepository.GetPrimitiveParts)
+}
+
+// GetAggregateParts retorna a lista de peças agregadas do repositório de peças.
+// Assim como em GetParts, o primeiro parâmetro é apenas o cabeçalho da chamada.
+func (p *PartRepositoryServer) GetAggregateParts(h Header, out *[]interfaces.Part) error {
+	return p.query(h, out, p.partRepository.GetAggregateParts)
+}
+
+// WhereUsed consulta as peças do repositório que utilizam a peça com o código informado como
+// subcomponente, utilizando o índice reverso dos subcomponentes do repositório.
+// Recebe como parâmetros os argumentos da chamada, com o código da peça e se a consulta é recursiva,
+// e um ponteiro para a lista na qual as peças encontradas são armazenadas.
+func (p *PartRepositoryServer) WhereUsed(args WhereUsedArgs, out *[]interfaces.Part) error {
+	return p.query(args.Header, out, func() []interfaces.Part {
+		return p.partRepository.WhereUsed(args.Code, args.Recursive)
+	})
+}
+
+// ListParts retorna uma página das peças do repositório, em ordem crescente de código.
+// Diferente de GetParts, que devolve o repositório inteiro em uma única resposta, ListParts permite
+// que o cliente percorra repositórios grandes em respostas de tamanho limitado.
+// Recebe como parâmetros os argumentos da chamada, com o tamanho da página e o token de continuação
+// devolvido pela página anterior, e um ponteiro para o resultado, com as peças da página e o token
+// da próxima página, vazio caso não haja mais peças.
+// O token identifica a última peça devolvida, de forma que as peças adicionadas ou removidas entre
+// as páginas não causem repetições nem omissões das demais.
+// O token não é assinado: ele apenas codifica o código da última peça, e um token forjado somente
+// inicia a listagem a partir de outro código, sem expor peças além das obtidas com GetParts.
+// Retorna um *PageTokenError, identificado como types.ErrInvalidPageToken e como ErrInvalidRequest,
+// caso o token esteja malformado.
+func (p *PartRepositoryServer) ListParts(args ListPartsArgs, reply *ListPartsReply) error {
+	after, err := decodePageToken(args.PageToken)
+	if err != nil {
+		return err
+	}
+	size := args.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	} else if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	var parts []interfaces.Part
+	// Consulta uma peça além do tamanho da página para saber se há uma próxima página
+	err = p.query(args.Header, &parts, func() []interfaces.Part {
+		return p.partRepository.ListParts(after, size+1)
+	})
+	if err != nil {
+		return err
+	}
+
+	reply.NextPageToken = ""
+	if len(parts) > size {
+		parts = parts[:size]
+		reply.NextPageToken = encodePageToken(parts[size-1].GetCode())
+	}
+	reply.Parts = parts
+	return nil
+}
+
+// encodePageToken retorna o token de continuação de uma listagem cuja última peça tem o código informado.
+func encodePageToken(code string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(code))
+}
+
+// decodePageToken retorna o código da última peça da página anterior, identificado pelo token.
+// Um token vazio identifica o início da listagem.
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	code, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(code) == 0 {
+		return "", &PageTokenError{Token: token}
+	}
+	return string(code), nil
+}
+
+// Estrutura PageTokenError representa o erro de um token de continuação malformado, devolvido por
+// ListParts. Ele é identificado com errors.Is tanto como types.ErrInvalidPageToken quanto como
+// ErrInvalidRequest, já que o token é um argumento inválido da chamada.
+type PageTokenError struct {
+	Token string // token recebido
+}
+
+// Error retorna a descrição do erro, iniciada pela descrição de types.ErrInvalidPageToken.
+func (e *PageTokenError) Error() string {
+	return types.ErrInvalidPageToken.Error() + ": " + e.Token
+}
+
+// Is retorna true caso target seja types.ErrInvalidPageToken ou ErrInvalidRequest.
+func (e *PageTokenError) Is(target error) bool {
+	return target == types.ErrInvalidPageToken || target == ErrInvalidRequest
+}
+
+// query executa a consulta find e armazena o seu resultado em out, a menos que o token do
+// cabeçalho h não possua o papel de leitura ou que o prazo da chamada tenha vencido.
+func (p *PartRepositoryServer) query(h Header, out *[]interfaces.Part, find func() []interfaces.Part) error {
+	if err := p.authorize(h, auth.RoleReader); err != nil {
+		return err
+	}
+	ctx, cancel := h.Context()
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	parts := find()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	*out = parts
 	return nil
 }
 
-// GetParts retorna a lista de peças do repositório de peças.
-// Recebe como parâmetros um ponteiro para uma string dummy, que será ignorado (é dessa forma para atender aos critérios previamente mencionados),
-// e um ponteiro para uma lista de peça, na qual será armazenada, que passará a apontar para a própria lista de peças do objeto PartRepository.
-// Retorna por padrão nulo, sinalizando que não houve erro na comunicação.
-func (p *PartRepositoryServer) GetParts(_ string, out *[]interfaces.Part) error {
-	*out = p.partRepository.GetParts()
+// UpdatePart substitui uma peça do repositório de peças, identificada pelo seu código.
+// A referência remota da peça passa a ser a referência remota do próprio servidor.
+// Recebe como parâmetros os argumentos da chamada, com a peça alterada, e um ponteiro para uma peça, que passará
+// a apontar para a peça armazenada.
+// Assim como em AddPart, a composição da peça alterada é validada antes da substituição. A validação
+// e a substituição são feitas sob um mesmo mutex, de forma que duas alterações concorrentes (ex.:
+// a passa a utilizar b e b passa a utilizar a) não sejam ambas validadas contra o estado anterior
+// e armazenadas, formando um ciclo. Ciclos formados por alterações concorrentes em repositórios
+// diferentes não são evitados.
+// Retorna types.ErrPartNotFound caso não exista peça com o código informado, ou ErrInvalidRequest
+// caso a peça, ou algum dos seus subcomponentes, seja nula.
+func (p *PartRepositoryServer) UpdatePart(args *PartArgs, reply *interfaces.Part) error {
+	if err := p.authorize(args.Header, auth.RoleWriter); err != nil {
+		return err
+	}
+	if err := checkPart(args.Part); err != nil {
+		return err
+	}
+	ctx, cancel := args.Context()
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	args.Part.SetRef(p.getRef())
+
+	p.updateMu.Lock()
+	defer p.updateMu.Unlock()
+	if err := p.validate(ctx, args.Part); err != nil {
+		return err
+	}
+	if err := p.partRepository.UpdatePart(args.Part); err != nil {
+		return err
+	}
+
+	*reply = args.Part
+	return nil
+}
+
+// checkPart verifica se a peça recebida por AddPart ou UpdatePart e os seus subcomponentes não são
+// nulos. Tanto o gob quanto o JSON-RPC transmitem valores nulos em campos de interface, e a
+// biblioteca net/rpc não recupera o pânico de um método exposto, que encerraria o servidor.
+func checkPart(part interfaces.Part) error {
+	if part == nil {
+		return fmt.Errorf("%w: missing part", ErrInvalidRequest)
+	}
+	for i, pair := range part.GetSubcomponents() {
+		if pair == nil {
+			return fmt.Errorf("%w: missing subcomponent %d", ErrInvalidRequest, i)
+		}
+	}
+	return nil
+}
+
+// DeletePart remove uma peça do repositório de peças a partir do seu código.
+// Recebe como parâmetros os argumentos da remoção e um ponteiro para uma lista de strings,
+// na qual são armazenados os códigos das peças removidas.
+// A remoção é recusada com types.ErrPartInUse caso outra peça do repositório utilize a peça como
+// subcomponente, a menos que a remoção em cascata tenha sido solicitada. A remoção é feita sob o
+// mesmo mutex de AddPart e UpdatePart, de forma que uma peça não passe a utilizar a peça removida
+// depois da verificação do seu uso e antes da remoção.
+func (p *PartRepositoryServer) DeletePart(args DeletePartArgs, deleted *[]string) error {
+	role := auth.RoleWriter
+	if args.Cascade {
+		role = auth.RoleAdmin
+	}
+	if err := p.authorize(args.Header, role); err != nil {
+		return err
+	}
+	ctx, cancel := args.Context()
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.updateMu.Lock()
+	defer p.updateMu.Unlock()
+	codes, err := p.partRepository.DeletePart(args.Code, args.Cascade)
+	if err != nil {
+		return err
+	}
+
+	*deleted = codes
 	return nil
 }
 
@@ -92,5 +435,31 @@ func (p *PartRepositoryServer) GetParts(_ string, out *[]interfaces.Part) error
 // Ela recebe como parâmetro uma referência a um servidor remoto que implementa a interface interfaces.RemoteRef
 // Esse método não atende aos critérios previamente citados e, portanto, não é registrado e exposta via RPC.
 func (p *PartRepositoryServer) SetRef(ref interfaces.RemoteRef) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.ref = ref
 }
+
+// SetAuthorizer define o objeto que verifica os papéis dos tokens das chamadas. Sem ele, todas as
+// chamadas são aceitas, independentemente do token.
+// Esse método não atende aos critérios previamente citados e, portanto, não é registrado e exposta via RPC.
+func (p *PartRepositoryServer) SetAuthorizer(authorizer *auth.Authorizer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.authorizer = authorizer
+}
+
+// authorize verifica se o token do cabeçalho h possui o papel required.
+func (p *PartRepositoryServer) authorize(h Header, required auth.Role) error {
+	p.mu.RLock()
+	authorizer := p.authorizer
+	p.mu.RUnlock()
+	return authorizer.Authorize(h.Token, required)
+}
+
+// getRef retorna a referência do servidor remoto da estrutura PartRepositoryServer.
+func (p *PartRepositoryServer) getRef() interfaces.RemoteRef {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ref
+}
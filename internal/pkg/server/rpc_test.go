@@ -0,0 +1,179 @@
+package server
+
+import (
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/types"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentCalls(t *testing.T) {
+	const workers, perWorker = 8, 50
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		// Escritor: adiciona peças e as altera
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				var added, updated interfaces.Part
+				if err := srv.AddPart(&PartArgs{Part: types.NewPartImpl("bolt", "")}, &added); err != nil {
+					t.Error(err)
+					return
+				}
+				part := types.NewPartImpl("bolt", "m6")
+				part.SetCode(added.GetCode())
+				if err := srv.UpdatePart(&PartArgs{Part: part}, &updated); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		// Leitor: consulta o repositório e verifica a consistência da cópia
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				var parts []interfaces.Part
+				if err := srv.GetParts(Header{}, &parts); err != nil {
+					t.Error(err)
+					return
+				}
+				// Todas as peças da cópia continuam acessíveis pelo código
+				for _, part := range parts {
+					var got interfaces.Part
+					if err := srv.GetPart(CodeArgs{Code: part.GetCode()}, &got); err != nil {
+						t.Error(err)
+						return
+					}
+					if got == nil {
+						t.Errorf("part %s not found", part.GetCode())
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var parts []interfaces.Part
+	if err := srv.GetParts(Header{}, &parts); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != workers*perWorker {
+		t.Fatalf("repository has %d parts, want %d", len(parts), workers*perWorker)
+	}
+	for _, part := range parts {
+		if part.GetDescription() != "m6" {
+			t.Fatalf("part %s was not updated", part.GetCode())
+		}
+	}
+}
+
+func TestDeletePartCascade(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+	srv.SetAuthorizer(auth.NewAuthorizer(map[string]auth.Role{"writer": auth.RoleWriter, "admin": auth.RoleAdmin}))
+	writer, admin := Header{Token: "writer"}, Header{Token: "admin"}
+
+	// wheel é utilizada por axle, que é utilizada por cart; bolt não é utilizada
+	add := func(name string, subs ...interfaces.Part) interfaces.Part {
+		part := types.NewPartImpl(name, "")
+		var pairs []interfaces.Pair
+		for _, sub := range subs {
+			pairs = append(pairs, types.NewPairImpl(sub, 1))
+		}
+		part.SetSubcomponents(pairs)
+		var added interfaces.Part
+		if err := srv.AddPart(&PartArgs{Header: writer, Part: part}, &added); err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+	wheel := add("wheel")
+	axle := add("axle", wheel)
+	cart := add("cart", axle)
+	bolt := add("bolt")
+
+	var deleted []string
+	if err := srv.DeletePart(DeletePartArgs{Header: writer, Code: wheel.GetCode()}, &deleted); !errors.Is(err, types.ErrPartInUse) {
+		t.Errorf("DeletePart of a part in use = %v, want ErrPartInUse", err)
+	}
+	if err := srv.DeletePart(DeletePartArgs{Header: writer, Code: wheel.GetCode(), Cascade: true}, &deleted); !errors.Is(err, auth.ErrPermissionDenied) {
+		t.Errorf("cascading DeletePart by a writer = %v, want ErrPermissionDenied", err)
+	}
+	if err := srv.DeletePart(DeletePartArgs{Header: writer, Code: "missing"}, &deleted); !errors.Is(err, types.ErrPartNotFound) {
+		t.Errorf("DeletePart of a missing part = %v, want ErrPartNotFound", err)
+	}
+
+	// A remoção em cascata remove a peça e todas as que a utilizam, direta ou indiretamente
+	if err := srv.DeletePart(DeletePartArgs{Header: admin, Code: wheel.GetCode(), Cascade: true}, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{wheel.GetCode(), axle.GetCode(), cart.GetCode()}
+	sort.Strings(deleted)
+	sort.Strings(want)
+	if len(deleted) != len(want) {
+		t.Fatalf("cascading DeletePart removed %v, want %v", deleted, want)
+	}
+	for i := range want {
+		if deleted[i] != want[i] {
+			t.Fatalf("cascading DeletePart removed %v, want %v", deleted, want)
+		}
+	}
+
+	var parts []interfaces.Part
+	if err := srv.GetParts(writer, &parts); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts[0].GetCode() != bolt.GetCode() {
+		t.Errorf("repository after cascading DeletePart = %v, want only bolt", parts)
+	}
+}
+
+func TestDeletePartDuringAddPart(t *testing.T) {
+	srv := NewPartRepositoryServer(slowRepository{new(types.PartRepositoryImpl)})
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+
+	// Cada peça adicionada referencia wheel, que é removida enquanto a inclusão é armazenada: ou a
+	// inclusão é recusada, ou a remoção, mas nunca resta uma referência a uma peça removida
+	for i := 0; i < 5; i++ {
+		var wheel interfaces.Part
+		if err := srv.AddPart(&PartArgs{Part: types.NewPartImpl("wheel", "")}, &wheel); err != nil {
+			t.Fatal(err)
+		}
+		axle := types.NewPartImpl("axle", "")
+		axle.SetSubcomponents([]interfaces.Pair{types.NewPairRefImpl("repo", wheel.GetCode(), 2)})
+
+		var wg sync.WaitGroup
+		var addErr, deleteErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			var added interfaces.Part
+			addErr = srv.AddPart(&PartArgs{Part: axle}, &added)
+		}()
+		go func() {
+			defer wg.Done()
+			time.Sleep(5 * time.Millisecond)
+			var deleted []string
+			deleteErr = srv.DeletePart(DeletePartArgs{Code: wheel.GetCode()}, &deleted)
+		}()
+		wg.Wait()
+
+		switch {
+		case addErr == nil && deleteErr == nil:
+			t.Fatal("AddPart and DeletePart both succeeded, leaving a dangling reference")
+		case addErr != nil && !errors.Is(addErr, types.ErrPartNotFound):
+			t.Fatalf("AddPart = %v, want nil or ErrPartNotFound", addErr)
+		case deleteErr != nil && !errors.Is(deleteErr, types.ErrPartInUse):
+			t.Fatalf("DeletePart = %v, want nil or ErrPartInUse", deleteErr)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"go-rpc/internal/pkg/trace"
+	"log"
+	"net/rpc"
+	"sync"
+)
+
+// NewTraceServerCodec retorna um rpc.ServerCodec que delega a codificação ao codec informado e
+// atende cada chamada numa etapa filha da etapa do chamador, identificada pelo cabeçalho da
+// chamada (ver Header). Ao final do atendimento, a etapa é encerrada e registrada no log, com os
+// identificadores do trace, da etapa e da etapa do chamador. Caso os identificadores do cabeçalho
+// sejam mal formados, a chamada é atendida na raiz de um novo trace (ver trace.StartRemote).
+//
+// Após a leitura dos argumentos, o cabeçalho passa a identificar a etapa do atendimento, de forma
+// que as chamadas feitas pelos métodos expostos, a partir de Header.Context, sejam filhas dela.
+func NewTraceServerCodec(codec rpc.ServerCodec) rpc.ServerCodec {
+	return &traceServerCodec{ServerCodec: codec, spans: make(map[uint64]*trace.Span)}
+}
+
+// Estrutura traceServerCodec envolve um rpc.ServerCodec, iniciando a etapa de cada chamada na
+// leitura dos argumentos e encerrando-a na escrita da resposta.
+// O rpc.Server lê o cabeçalho e os argumentos de cada requisição em sequência, numa única
+// goroutine, e escreve as respostas em outras, por isso as etapas são protegidas por um mutex.
+type traceServerCodec struct {
+	rpc.ServerCodec
+	request rpc.Request            // cabeçalho da última requisição lida
+	mu      sync.Mutex             // protege as etapas em atendimento
+	spans   map[uint64]*trace.Span // etapas em atendimento, indexadas pelo número de sequência
+}
+
+// ReadRequestHeader lê o cabeçalho da requisição e o guarda para a leitura dos argumentos.
+// Implementa a interface rpc.ServerCodec.
+func (c *traceServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	c.request = *r
+	return nil
+}
+
+// ReadRequestBody lê os argumentos da requisição e inicia a etapa da chamada. Os argumentos de
+// métodos inexistentes são descartados pelo rpc.Server sem que uma etapa seja iniciada.
+// Implementa a interface rpc.ServerCodec.
+func (c *traceServerCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	args, ok := body.(Args)
+	if !ok {
+		return nil
+	}
+
+	h := args.GetHeader()
+	span := trace.StartRemote(h.SpanContext(), c.request.ServiceMethod)
+	h.TraceID, h.SpanID = span.TraceID, span.SpanID
+	c.mu.Lock()
+	c.spans[c.request.Seq] = span
+	c.mu.Unlock()
+	return nil
+}
+
+// WriteResponse escreve a resposta e encerra a etapa da chamada correspondente.
+// Implementa a interface rpc.ServerCodec.
+func (c *traceServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mu.Lock()
+	span, ok := c.spans[r.Seq]
+	delete(c.spans, r.Seq)
+	c.mu.Unlock()
+
+	err := c.ServerCodec.WriteResponse(r, body)
+	if ok {
+		if r.Error != "" {
+			span.End(rpc.ServerError(r.Error))
+		} else {
+			span.End(nil)
+		}
+		log.Println("[!]", span)
+	}
+	return err
+}
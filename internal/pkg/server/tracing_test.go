@@ -0,0 +1,31 @@
+package server
+
+import (
+	"go-rpc/internal/pkg/trace"
+	"net/rpc"
+	"testing"
+)
+
+func TestMalformedTraceIDsStartNewTrace(t *testing.T) {
+	const forged = "0123456789abcdef0123456789abcdef\n[!] forged log line"
+	codec := NewTraceServerCodec(&slowBodyCodec{
+		args: CodeArgs{Header: Header{TraceID: forged, SpanID: "0123456789abcdef"}, Code: "code"},
+	})
+
+	var req rpc.Request
+	var args CodeArgs
+	if err := codec.ReadRequestHeader(&req); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.ReadRequestBody(&args); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := args.SpanContext()
+	if !sc.IsValid() || sc.TraceID == forged {
+		t.Errorf("call span = %+v, want a new valid trace", sc)
+	}
+	if got := trace.StartRemote(sc, "child"); got.TraceID != sc.TraceID {
+		t.Errorf("child trace = %s, want %s", got.TraceID, sc.TraceID)
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rpc/interfaces"
+	"go-rpc/types"
+	"strings"
+)
+
+// DefaultMaxDepth é a profundidade máxima padrão da composição de uma peça, isto é, o número
+// máximo de níveis de subcomponentes abaixo dela.
+const DefaultMaxDepth = 32
+
+// Interface PartResolver define a consulta de peças armazenadas em outros repositórios,
+// utilizada pelo PartRepositoryServer para validar composições que referenciam essas peças.
+// Ela é implementada por client.Resolver; a interface evita que o pacote server dependa do
+// pacote client, que depende dele.
+type PartResolver interface {
+	// ResolvePart retorna a peça com o código informado do repositório com o nome informado.
+	ResolvePart(ctx context.Context, repository string, code string) (interfaces.Part, error)
+}
+
+// SetMaxDepth altera a profundidade máxima da composição das peças aceitas por AddPart e UpdatePart.
+// Um valor não positivo restaura DefaultMaxDepth.
+func (p *PartRepositoryServer) SetMaxDepth(depth int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxDepth = depth
+}
+
+// SetResolver define o objeto utilizado para consultar as peças de outros repositórios durante a
+// validação das composições. Sem ele, as referências a outros repositórios são tratadas como
+// peças primitivas, e ciclos que passam por outros repositórios não são detectados.
+func (p *PartRepositoryServer) SetResolver(resolver PartResolver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolver = resolver
+}
+
+// validate verifica se a composição da peça part, que será adicionada ou alterada, não contém
+// ciclos e não excede a profundidade máxima, percorrendo recursivamente os seus subcomponentes.
+// Retorna types.ErrPartCycle ou types.ErrPartTooDeep, acompanhados do caminho de peças que
+// viola a restrição.
+//
+// As referências a peças do próprio repositório são consultadas no repositório, e as demais
+// através do PartResolver, caso tenha sido definido. Referências a peças inexistentes, no próprio
+// repositório ou num repositório remoto que respondeu à consulta, são recusadas com
+// types.ErrPartNotFound, acompanhado do caminho até a referência; apenas as referências a
+// repositórios inacessíveis são tratadas como peças primitivas. Como a peça part é avaliada na versão recebida, e não na
+// armazenada, um ciclo criado por uma alteração é detectado quando o caminho retorna a ela.
+// A profundidade é verificada a partir da própria peça, e não das peças que a utilizam.
+func (p *PartRepositoryServer) validate(ctx context.Context, part interfaces.Part) error {
+	p.mu.RLock()
+	v := &validator{
+		ctx:      ctx,
+		repo:     p.partRepository,
+		resolver: p.resolver,
+		maxDepth: p.maxDepth,
+		chains:   make(map[string][]string),
+	}
+	if p.ref != nil {
+		v.name = p.ref.GetName()
+	}
+	p.mu.RUnlock()
+	if v.maxDepth <= 0 {
+		v.maxDepth = DefaultMaxDepth
+	}
+
+	key := v.name + "/" + part.GetCode()
+	_, err := v.check(part, []string{key}, []string{label(part, key)})
+	return err
+}
+
+// Estrutura validator mantém o estado da validação da composição de uma peça.
+type validator struct {
+	ctx      context.Context           // contexto da chamada que originou a validação
+	repo     interfaces.PartRepository // repositório local
+	name     string                    // nome do repositório local
+	resolver PartResolver              // consulta as peças de outros repositórios, pode ser nulo
+	maxDepth int                       // profundidade máxima
+	chains   map[string][]string       // maior cadeia de peças a partir de cada peça já validada, indexada pela chave da peça
+}
+
+// check valida a composição da peça part, alcançada pelo caminho de chaves keys (cujos rótulos são
+// labels), terminado pela própria peça, e retorna os rótulos da maior cadeia de subcomponentes
+// a partir dela, incluindo-a. As cadeias das peças já validadas são reaproveitadas, de forma que
+// peças compartilhadas por vários caminhos sejam percorridas uma única vez.
+func (v *validator) check(part interfaces.Part, keys []string, labels []string) ([]string, error) {
+	depth := len(keys) - 1
+	if chain, ok := v.chains[keys[depth]]; ok {
+		if depth+len(chain)-1 > v.maxDepth {
+			return nil, v.tooDeep(append(labels[:depth:depth], chain...))
+		}
+		return chain, nil
+	}
+	if depth > v.maxDepth {
+		return nil, v.tooDeep(labels)
+	}
+
+	var longest []string
+	for _, pair := range part.GetSubcomponents() {
+		key := pair.GetRepositoryName() + "/" + pair.GetPartCode()
+		for i, k := range keys {
+			if k == key {
+				path := append(labels[i:len(labels):len(labels)], labels[i])
+				return nil, fmt.Errorf("%w: %s", types.ErrPartCycle, strings.Join(path, " -> "))
+			}
+		}
+
+		sub, err := v.resolve(pair)
+		if errors.Is(err, types.ErrPartNotFound) {
+			path := append(labels[:len(labels):len(labels)], key)
+			return nil, fmt.Errorf("%w: %s", types.ErrPartNotFound, strings.Join(path, " -> "))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sub == nil {
+			// Referência a um repositório inacessível, tratada como peça primitiva
+			sub = types.NewPartImpl("", "")
+			sub.SetCode(pair.GetPartCode())
+		}
+
+		chain, err := v.check(sub, append(keys[:len(keys):len(keys)], key), append(labels[:len(labels):len(labels)], label(sub, key)))
+		if err != nil {
+			return nil, err
+		}
+		if len(chain) > len(longest) {
+			longest = chain
+		}
+	}
+
+	chain := append([]string{labels[depth]}, longest...)
+	v.chains[keys[depth]] = chain
+	return chain, nil
+}
+
+// resolve retorna a peça do par, ou nil caso a referência seja a um repositório que não pode ser
+// consultado. Retorna types.ErrPartNotFound caso a peça não exista no repositório local ou no
+// repositório remoto que a deveria conter, ou o erro do contexto caso o prazo da chamada tenha vencido.
+func (v *validator) resolve(pair interfaces.Pair) (interfaces.Part, error) {
+	if !pair.IsReference() {
+		return pair.GetPart(), nil
+	}
+
+	var part interfaces.Part
+	var err error
+	switch {
+	case pair.GetRepositoryName() == v.name:
+		if part = v.repo.GetPart(pair.GetPartCode()); part == nil {
+			err = types.ErrPartNotFound
+		}
+	case v.resolver != nil:
+		part, err = v.resolver.ResolvePart(v.ctx, pair.GetRepositoryName(), pair.GetPartCode())
+	}
+	if ctxErr := v.ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if errors.Is(err, types.ErrPartNotFound) {
+		return nil, types.ErrPartNotFound
+	}
+	if err != nil {
+		return nil, nil
+	}
+	return part, nil
+}
+
+// tooDeep retorna o erro de uma composição que excede a profundidade máxima ao longo do caminho path.
+func (v *validator) tooDeep(path []string) error {
+	return fmt.Errorf("%w: maximum depth is %d: %s", types.ErrPartTooDeep, v.maxDepth, strings.Join(path, " -> "))
+}
+
+// label retorna o rótulo da peça nas mensagens de erro, formado pelo nome e pela chave da peça.
+func label(part interfaces.Part, key string) string {
+	if part.GetName() == "" {
+		return key
+	}
+	return part.GetName() + "[" + key + "]"
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/types"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Estrutura slowRepository representa um repositório cujas alterações demoram, como as de um
+// repositório persistente que sincroniza o log com o disco.
+type slowRepository struct {
+	*types.PartRepositoryImpl
+}
+
+// AddPart aguarda antes de adicionar a peça.
+func (r slowRepository) AddPart(part interfaces.Part) error {
+	time.Sleep(20 * time.Millisecond)
+	return r.PartRepositoryImpl.AddPart(part)
+}
+
+// UpdatePart aguarda antes de alterar a peça.
+func (r slowRepository) UpdatePart(part interfaces.Part) error {
+	time.Sleep(20 * time.Millisecond)
+	return r.PartRepositoryImpl.UpdatePart(part)
+}
+
+func TestConcurrentUpdatesCannotCreateCycle(t *testing.T) {
+	srv := NewPartRepositoryServer(slowRepository{new(types.PartRepositoryImpl)})
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+
+	var a, b interfaces.Part
+	if err := srv.AddPart(&PartArgs{Part: types.NewPartImpl("a", "")}, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.AddPart(&PartArgs{Part: types.NewPartImpl("b", "")}, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	// a passa a utilizar b e, ao mesmo tempo, b passa a utilizar a
+	updates := []struct{ part, sub interfaces.Part }{{a, b}, {b, a}}
+	errs := make([]error, len(updates))
+	var wg sync.WaitGroup
+	for i, u := range updates {
+		part := types.NewPartImpl(u.part.GetName(), "")
+		part.SetCode(u.part.GetCode())
+		part.SetSubcomponents([]interfaces.Pair{types.NewPairRefImpl("repo", u.sub.GetCode(), 1)})
+		wg.Add(1)
+		go func(i int, part interfaces.Part) {
+			defer wg.Done()
+			var reply interfaces.Part
+			errs[i] = srv.UpdatePart(&PartArgs{Part: part}, &reply)
+		}(i, part)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			if !errors.Is(err, types.ErrPartCycle) {
+				t.Fatalf("UpdatePart = %v, want nil or ErrPartCycle", err)
+			}
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("%d updates rejected, want exactly 1: %v", failed, errs)
+	}
+}
+
+// Tipo resolverFunc permite utilizar uma função como PartResolver.
+type resolverFunc func(ctx context.Context, repository string, code string) (interfaces.Part, error)
+
+// ResolvePart chama a própria função.
+func (f resolverFunc) ResolvePart(ctx context.Context, repository string, code string) (interfaces.Part, error) {
+	return f(ctx, repository, code)
+}
+
+func TestUnresolvedReferenceIsRejected(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+	srv.SetResolver(resolverFunc(func(ctx context.Context, repository string, code string) (interfaces.Part, error) {
+		if repository == "down" {
+			return nil, rpcerror.New("Dial", rpcerror.ErrConnectionLost, errors.New("connection refused"))
+		}
+		return nil, rpcerror.New("PartRepository.GetPart", rpcerror.ErrNotFound, fmt.Errorf("%w: %s", types.ErrPartNotFound, code))
+	}))
+
+	add := func(repository string, code string) error {
+		part := types.NewPartImpl("assembly", "")
+		part.SetSubcomponents([]interfaces.Pair{types.NewPairRefImpl(repository, code, 1)})
+		var reply interfaces.Part
+		return srv.AddPart(&PartArgs{Part: part}, &reply)
+	}
+
+	// Referências a peças inexistentes, locais ou remotas, são recusadas com o caminho até elas
+	for _, repository := range []string{"repo", "other"} {
+		err := add(repository, "missing")
+		if !errors.Is(err, types.ErrPartNotFound) {
+			t.Errorf("AddPart referencing %s/missing = %v, want ErrPartNotFound", repository, err)
+		} else if !strings.Contains(err.Error(), repository+"/missing") {
+			t.Errorf("AddPart error %q does not name the path to %s/missing", err, repository)
+		}
+	}
+
+	// Referências a repositórios inacessíveis são aceitas como peças primitivas
+	if err := add("down", "bolt"); err != nil {
+		t.Errorf("AddPart referencing an unreachable repository = %v, want success", err)
+	}
+}
+
+func TestValidateDepthAndCycle(t *testing.T) {
+	srv := NewPartRepositoryServer(new(types.PartRepositoryImpl))
+	srv.SetRef(types.NewRemoteRefImpl("127.0.0.1", "8001", "repo"))
+	srv.SetMaxDepth(2)
+
+	add := func(name string, subs ...interfaces.Part) (interfaces.Part, error) {
+		part := types.NewPartImpl(name, "")
+		var pairs []interfaces.Pair
+		for _, sub := range subs {
+			pairs = append(pairs, types.NewPairRefImpl("repo", sub.GetCode(), 1))
+		}
+		part.SetSubcomponents(pairs)
+		var added interfaces.Part
+		err := srv.AddPart(&PartArgs{Part: part}, &added)
+		return added, err
+	}
+
+	// wheel <- axle <- cart tem profundidade 2; uma peça que utiliza cart excede o limite
+	wheel, err := add("wheel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	axle, err := add("axle", wheel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart, err := add("cart", axle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add("train", cart); !errors.Is(err, types.ErrPartTooDeep) {
+		t.Errorf("AddPart beyond the maximum depth = %v, want ErrPartTooDeep", err)
+	}
+
+	// wheel passa a utilizar axle, que a utiliza
+	cyclic := types.NewPartImpl("wheel", "")
+	cyclic.SetCode(wheel.GetCode())
+	cyclic.SetSubcomponents([]interfaces.Pair{types.NewPairRefImpl("repo", axle.GetCode(), 1)})
+	var reply interfaces.Part
+	if err := srv.UpdatePart(&PartArgs{Part: cyclic}, &reply); !errors.Is(err, types.ErrPartCycle) {
+		t.Errorf("UpdatePart creating a cycle = %v, want ErrPartCycle", err)
+	}
+}
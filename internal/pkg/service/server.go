@@ -0,0 +1,406 @@
+// O pacote service fornece o tipo que executa um servidor de repositório de peças completo: o
+// servidor RPC (gob e, opcionalmente, JSON-RPC), a API REST opcional, o registro no serviço de
+// nomes e o encerramento gradual, de forma que ele possa ser embutido em outros programas e
+// testes, inclusive várias vezes no mesmo processo. O executável cmd/service apenas o configura
+// a partir das flags de linha de comando.
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/auth"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/internal/pkg/metrics"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/server"
+	"go-rpc/types"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout é o tempo máximo padrão de espera pelas chamadas em atendimento no encerramento.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Tempos máximos de leitura do cabeçalho e da requisição inteira na API REST, para que clientes
+// lentos não mantenham conexões abertas indefinidamente.
+const (
+	restReadHeaderTimeout = 10 * time.Second
+	restReadTimeout       = 30 * time.Second
+)
+
+// Estrutura Config representa a configuração de um servidor de repositório de peças.
+// Apenas Host e Name são obrigatórios; os demais campos possuem valores-padrão ou desabilitam
+// o recurso correspondente quando omitidos.
+type Config struct {
+	Host            string                    // host em que o servidor escuta e com o qual se registra
+	Port            string                    // porta do servidor RPC gob; "0" ou vazia escolhe uma porta livre
+	AdvertisePort   string                    // porta registrada no serviço de nomes, caso os clientes acessem o servidor por outra porta (ex.: através de um proxy); vazia para a porta do servidor RPC gob
+	JSONPort        string                    // porta do servidor JSON-RPC, desabilitado caso vazia; "0" escolhe uma porta livre
+	RESTPort        string                    // porta da API REST, desabilitada caso vazia; "0" escolhe uma porta livre
+	Name            string                    // nome registrado no serviço de nomes
+	NameServer      *naming.NameServerClient  // cliente do serviço de nomes; caso nulo, o servidor não se registra nem resolve peças de outros repositórios
+	TTL             time.Duration             // tempo de validade do registro no serviço de nomes; zero para o valor padrão
+	Repository      interfaces.PartRepository // repositório de peças; caso nulo, utiliza um repositório em memória
+	TLS             *tls.Config               // configuração TLS de servidor, aplicada a todas as portas; nula para TCP sem TLS
+	Authorizer      *auth.Authorizer          // verifica os papéis dos tokens das chamadas; nulo caso não haja autenticação
+	MaxDepth        int                       // profundidade máxima das composições; zero para server.DefaultMaxDepth
+	Metrics         *metrics.Registry         // conjunto em que as métricas do servidor são registradas; nulo caso não sejam coletadas
+	ShutdownTimeout time.Duration             // tempo máximo de espera pelas chamadas em atendimento no encerramento; zero para DefaultShutdownTimeout
+}
+
+// Estrutura Server representa um servidor de repositório de peças em execução, com os seus
+// próprios listeners, servidor RPC e servidor HTTP. O ciclo de vida é controlado por Start e
+// Close; um servidor encerrado não pode ser iniciado novamente.
+// A estrutura é segura para uso concorrente.
+type Server struct {
+	cfg        Config                    // configuração do servidor
+	mu         sync.Mutex                // protege o estado do ciclo de vida
+	started    bool                      // se Start foi chamado
+	running    bool                      // se Start foi concluído com sucesso
+	repository interfaces.PartRepository // repositório de peças atendido
+	listeners  []net.Listener            // listeners das portas RPC gob e JSON-RPC; o da API REST é fechado pelo restServer
+	addrs      map[string]string         // endereços em que o servidor escuta, indexados pelo protocolo
+	restServer *http.Server              // servidor HTTP da API REST, nulo caso desabilitada
+	drainer    *server.Drainer           // acompanha as chamadas RPC em atendimento
+	resolver   *client.Resolver          // consulta as peças de outros repositórios, nulo caso não haja serviço de nomes
+	lease      *naming.Lease             // renovação do registro no serviço de nomes, nula caso não haja registro
+	stop       chan struct{}             // fechado no início do encerramento
+	done       chan struct{}             // fechado ao final do encerramento
+	closeOnce  sync.Once                 // garante que o encerramento seja executado uma única vez
+	closeErr   error                     // primeiro erro do encerramento
+}
+
+// Protocolos indexados em Server.addrs
+const (
+	protoGob  = "gob"
+	protoJSON = "json"
+	protoREST = "rest"
+)
+
+// NewServer retorna o ponteiro para uma estrutura Server com a configuração informada, que
+// começa a atender as chamadas com Start.
+func NewServer(cfg Config) *Server {
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return &Server{cfg: cfg, addrs: make(map[string]string), stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Start começa a escutar nas portas configuradas, a atender as chamadas em segundo plano e,
+// caso um cliente do serviço de nomes tenha sido configurado, registra o servidor com o seu nome e
+// renova o registro periodicamente. O contexto ctx limita a inicialização, e o seu cancelamento
+// encerra o servidor, assim como Close.
+// Retorna um erro caso não seja possível escutar em alguma porta ou se registrar no serviço de
+// nomes, situação em que os recursos já alocados são liberados, ou caso o servidor já tenha sido iniciado.
+func (s *Server) Start(ctx context.Context) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("server already started")
+	}
+	s.started = true
+	cfg := s.cfg
+
+	// Inicializa o repositório de peças, em memória caso nenhum tenha sido informado
+	s.repository = cfg.Repository
+	if s.repository == nil {
+		s.repository = new(types.PartRepositoryImpl)
+	}
+
+	// Inicializa servidor de repositório de peças, com a autenticação, a profundidade máxima das
+	// composições e o objeto que consulta as peças de outros repositórios através do serviço de nomes
+	partRepositoryServer := server.NewPartRepositoryServer(s.repository)
+	partRepositoryServer.SetAuthorizer(cfg.Authorizer)
+	partRepositoryServer.SetMaxDepth(cfg.MaxDepth)
+	if cfg.NameServer != nil {
+		s.resolver = client.NewResolver(cfg.NameServer)
+		partRepositoryServer.SetResolver(s.resolver)
+	}
+
+	// Inicializa servidor RPC e registra/expõe o servidor de repositório de peças como um serviço com o nome "PartRepository"
+	rpcServer := rpc.NewServer()
+	rpcServer.RegisterName("PartRepository", partRepositoryServer)
+
+	// Os codecs de cada conexão são criados explicitamente, em vez de utilizar rpc.Server.Accept, para
+	// que possam ser envolvidos pelo rastreamento e pela coleta de métricas sem alterar os métodos expostos
+	var rpcMetrics *metrics.RPCMetrics
+	if cfg.Metrics != nil {
+		rpcMetrics = metrics.NewRPCMetrics(cfg.Metrics, "partrepository")
+		repository := s.repository
+		cfg.Metrics.NewGaugeFunc("partrepository_parts", "Number of parts stored in the repository.", func() float64 {
+			return float64(repository.Len())
+		})
+	}
+	s.drainer = server.NewDrainer()
+
+	// Libera os listeners já abertos e as conexões do Resolver caso a inicialização falhe
+	defer func() {
+		if err != nil {
+			for _, l := range s.listeners {
+				l.Close()
+			}
+			if s.restServer != nil {
+				s.restServer.Close()
+			}
+			if s.resolver != nil {
+				s.resolver.Close()
+			}
+		}
+	}()
+
+	// Começa a escutar por conexões na porta RPC e define a referência do servidor com a porta
+	// efetivamente utilizada, que pode ter sido escolhida pelo sistema, ou com a porta anunciada
+	listener, err := s.listen(ctx, protoGob, cfg.Port)
+	if err != nil {
+		return err
+	}
+	s.listeners = append(s.listeners, listener)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	if cfg.AdvertisePort != "" {
+		port = cfg.AdvertisePort
+	}
+	partRepositoryServer.SetRef(types.NewRemoteRefImpl(cfg.Host, port, cfg.Name))
+	go server.ServeCodec(rpcServer, listener, s.instrument(server.NewGobServerCodec, rpcMetrics))
+	log.Printf("[!] RPC server running on %s", listener.Addr())
+
+	// Caso uma porta JSON-RPC tenha sido informada, expõe o mesmo servidor RPC com o codec JSON-RPC,
+	// para clientes que não falam gob
+	if cfg.JSONPort != "" {
+		jsonListener, err := s.listen(ctx, protoJSON, cfg.JSONPort)
+		if err != nil {
+			return err
+		}
+		s.listeners = append(s.listeners, jsonListener)
+		go server.ServeCodec(rpcServer, jsonListener, s.instrument(server.NewJSONServerCodec, rpcMetrics))
+		log.Printf("[!] JSON-RPC server running on %s", jsonListener.Addr())
+	}
+
+	// Caso uma porta REST tenha sido informada, expõe o mesmo servidor de repositório como uma API REST
+	if cfg.RESTPort != "" {
+		restListener, err := s.listen(ctx, protoREST, cfg.RESTPort)
+		if err != nil {
+			return err
+		}
+		restServer := &http.Server{
+			Handler:           server.NewRESTHandler(partRepositoryServer),
+			ReadHeaderTimeout: restReadHeaderTimeout,
+			ReadTimeout:       restReadTimeout,
+		}
+		s.restServer = restServer
+		go func() {
+			if err := restServer.Serve(restListener); err != http.ErrServerClosed {
+				log.Println("[!] REST server stopped:", err)
+			}
+		}()
+		log.Printf("[!] REST server running on %s", restListener.Addr())
+	}
+
+	// Registra o presente servidor no serviço de nomes e renova o registro periodicamente, para
+	// que ele não expire enquanto o servidor estiver ativo
+	if cfg.NameServer != nil {
+		token, err := cfg.NameServer.RegisterContext(ctx, cfg.Host, port, cfg.Name, cfg.TTL)
+		if err != nil {
+			return err
+		}
+		s.lease = cfg.NameServer.KeepAlive(cfg.Host, port, cfg.Name, token, cfg.TTL)
+		log.Printf("[!] Successfully registered at nameserver with hostname %s", cfg.Name)
+	}
+
+	s.running = true
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.stop:
+		}
+	}()
+	return nil
+}
+
+// Addr retorna o endereço, no formato host:porta, do servidor RPC gob, ou uma string vazia caso
+// o servidor não tenha sido iniciado.
+func (s *Server) Addr() string {
+	return s.addr(protoGob)
+}
+
+// JSONAddr retorna o endereço do servidor JSON-RPC, ou uma string vazia caso esteja desabilitado.
+func (s *Server) JSONAddr() string {
+	return s.addr(protoJSON)
+}
+
+// RESTAddr retorna o endereço da API REST, ou uma string vazia caso esteja desabilitada.
+func (s *Server) RESTAddr() string {
+	return s.addr(protoREST)
+}
+
+// addr retorna o endereço em que o servidor escuta com o protocolo proto.
+func (s *Server) addr(proto string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addrs[proto]
+}
+
+// Close encerra o servidor gradualmente:
+//
+//  1. encerra a renovação do registro e o remove do serviço de nomes, para que os clientes
+//     recorram às outras instâncias do nome;
+//  2. deixa de aceitar conexões e aguarda o término das chamadas em atendimento, por até
+//     Config.ShutdownTimeout, descartando as requisições recebidas nesse intervalo (ver server.Drainer);
+//  3. encerra as conexões com os outros repositórios, abertas para validar as composições;
+//  4. fecha o repositório de peças, caso implemente io.Closer (ex.: storage.FilePartRepository,
+//     que gera um snapshot final).
+//
+// Todas as etapas são executadas mesmo que alguma falhe, e o primeiro erro é devolvido.
+// Chamadas posteriores, ou concorrentes, aguardam o mesmo encerramento e devolvem o mesmo erro.
+func (s *Server) Close() error {
+	return s.close(true)
+}
+
+// Abort encerra o servidor imediatamente, simulando a falha do processo: a renovação do registro
+// é encerrada sem removê-lo do serviço de nomes, que o mantém até que expire, e as conexões são
+// fechadas sem aguardar as chamadas em atendimento. O repositório de peças não é fechado.
+// Assim como Close, o encerramento é executado uma única vez.
+func (s *Server) Abort() error {
+	return s.close(false)
+}
+
+// close executa o encerramento, gradual ou imediato, uma única vez, e aguarda o seu término.
+func (s *Server) close(graceful bool) error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		close(s.stop)
+		running := s.running
+		s.started = true
+		s.mu.Unlock()
+
+		if running && graceful {
+			s.closeErr = s.shutdown()
+		} else if running {
+			s.abort()
+		}
+		close(s.done)
+	})
+	<-s.done
+	return s.closeErr
+}
+
+// Wait bloqueia até que o servidor seja encerrado, por Close, Abort ou pelo cancelamento do
+// contexto de Start, e retorna o erro do encerramento.
+func (s *Server) Wait() error {
+	<-s.done
+	return s.closeErr
+}
+
+// shutdown executa as etapas do encerramento descritas em Close.
+func (s *Server) shutdown() error {
+	log.Printf("[!] Shutting down %s (waiting up to %s for in-flight calls)", s.cfg.Name, s.cfg.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if s.lease != nil {
+		s.lease.Stop()
+		if err := s.cfg.NameServer.DeregisterContext(ctx, s.cfg.Name, s.lease.Token()); err != nil {
+			log.Printf("[!] Failed to deregister %s: %v", s.cfg.Name, err)
+			errs = append(errs, err)
+		} else {
+			log.Printf("[!] Deregistered hostname %s from nameserver", s.cfg.Name)
+		}
+	}
+
+	// Deixa de aceitar conexões e aguarda o término das chamadas em atendimento
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	if s.restServer != nil {
+		if err := s.restServer.Shutdown(ctx); err != nil {
+			log.Println("[!] REST server shutdown:", err)
+			errs = append(errs, err)
+		}
+	}
+	if err := s.drainer.Shutdown(ctx); err != nil {
+		log.Println("[!] In-flight calls interrupted:", err)
+		errs = append(errs, err)
+	}
+
+	// Encerra as conexões com os outros repositórios, utilizadas apenas pelas chamadas já encerradas
+	if s.resolver != nil {
+		s.resolver.Close()
+	}
+
+	// Gera o snapshot final e fecha o log do repositório persistente, caso seja utilizado
+	if closer, ok := s.repository.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("[!] Failed to close storage:", err)
+			errs = append(errs, err)
+		}
+	}
+	log.Printf("[!] Server %s stopped", s.cfg.Name)
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// abort executa o encerramento imediato descrito em Abort.
+func (s *Server) abort() {
+	if s.lease != nil {
+		s.lease.Stop()
+	}
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	if s.restServer != nil {
+		s.restServer.Close()
+	}
+
+	// Com o contexto já cancelado, o Drainer fecha todas as conexões sem aguardar as chamadas
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.drainer.Shutdown(ctx)
+	if s.resolver != nil {
+		s.resolver.Close()
+	}
+	log.Printf("[!] Server %s aborted", s.cfg.Name)
+}
+
+// listen começa a escutar por conexões TCP no host do servidor e na porta informada, registrando
+// o seu endereço com o protocolo proto. Caso uma configuração TLS tenha sido informada,
+// estabelece uma sessão TLS sobre cada conexão aceita.
+// Deve ser chamada com o mutex adquirido.
+func (s *Server) listen(ctx context.Context, proto string, port string) (net.Listener, error) {
+	if port == "" {
+		port = "0"
+	}
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", net.JoinHostPort(s.cfg.Host, port))
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.TLS != nil {
+		listener = tls.NewListener(listener, s.cfg.TLS)
+	}
+	s.addrs[proto] = listener.Addr().String()
+	return listener, nil
+}
+
+// instrument retorna a função que cria os codecs das conexões com newCodec, envolvendo-os pelo
+// rastreamento das chamadas (ver server.NewTraceServerCodec), pela coleta de métricas, caso
+// rpcMetrics não seja nulo, e pelo acompanhamento das chamadas em atendimento (ver server.Drainer).
+func (s *Server) instrument(newCodec func(conn io.ReadWriteCloser) rpc.ServerCodec, rpcMetrics *metrics.RPCMetrics) func(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return func(conn io.ReadWriteCloser) rpc.ServerCodec {
+		codec := server.NewTraceServerCodec(newCodec(conn))
+		if rpcMetrics != nil {
+			codec = rpcMetrics.Wrap(codec)
+		}
+		return s.drainer.Wrap(codec)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-rpc/encoding"
+	"go-rpc/interfaces"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/internal/pkg/metrics"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/rpcerror"
+	"go-rpc/types"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Estrutura blockingRepository representa um repositório cujas consultas aguardam a liberação
+// do teste, mantendo as chamadas em atendimento durante o encerramento do servidor.
+type blockingRepository struct {
+	*types.PartRepositoryImpl
+	entered chan struct{} // recebe um valor a cada consulta iniciada
+	release chan struct{} // fechado para liberar as consultas
+}
+
+// GetPart sinaliza o início da consulta e aguarda a liberação antes de consultar a peça.
+func (r blockingRepository) GetPart(code string) interfaces.Part {
+	r.entered <- struct{}{}
+	<-r.release
+	return r.PartRepositoryImpl.GetPart(code)
+}
+
+func TestCloseDrainsInFlightCalls(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	nameServer := naming.NewNameServer("127.0.0.1", "0")
+	if err := nameServer.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nameServer.Close() })
+	host, port, _ := net.SplitHostPort(nameServer.Addr())
+	ns := naming.NewNameServerClient(host, port)
+
+	repo := blockingRepository{new(types.PartRepositoryImpl), make(chan struct{}, 1), make(chan struct{})}
+	part := types.NewPartImpl("bolt", "m6")
+	part.SetCode("bolt")
+	repo.AddPart(part)
+	srv := NewServer(Config{Host: "127.0.0.1", Name: "repo", NameServer: ns, Repository: repo})
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	c, err := client.Dial(ns, "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetRetryPolicy("GetPart", client.NoRetry)
+
+	// Uma chamada em atendimento no início do encerramento
+	inFlight := make(chan error, 1)
+	go func() {
+		_, err := c.GetPart("bolt")
+		inFlight <- err
+	}()
+	<-repo.entered
+	closed := make(chan error, 1)
+	go func() { closed <- srv.Close() }()
+
+	// O nome é removido do serviço de nomes e novas conexões são recusadas
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, lookupErr := ns.LookupAll("repo")
+		conn, dialErr := net.Dial("tcp", srv.Addr())
+		if dialErr == nil {
+			conn.Close()
+		}
+		if errors.Is(lookupErr, rpcerror.ErrNotFound) && dialErr != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server still reachable while draining: lookup = %v, dial = %v", lookupErr, dialErr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// As novas chamadas na conexão existente são descartadas
+	time.Sleep(50 * time.Millisecond)
+	refused := make(chan error, 1)
+	go func() {
+		_, err := c.GetPart("bolt")
+		refused <- err
+	}()
+
+	// A chamada em atendimento termina normalmente, e só então o servidor é encerrado
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v before the in-flight call finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(repo.release)
+	if err := <-inFlight; err != nil {
+		t.Errorf("in-flight GetPart = %v, want success", err)
+	}
+	if err := <-refused; !rpcerror.IsConnectionError(err) {
+		t.Errorf("GetPart during shutdown = %v, want ErrConnectionLost", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close = %v", err)
+	}
+
+	// As conexões com os outros repositórios são encerradas junto com o servidor
+	if _, err := srv.resolver.Client(context.Background(), "repo"); !errors.Is(err, rpcerror.ErrCanceled) {
+		t.Errorf("resolver after Close = %v, want ErrCanceled", err)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	repo := new(types.PartRepositoryImpl)
+	for _, code := range []string{"bolt", "nut"} {
+		part := types.NewPartImpl(code, "")
+		part.SetCode(code)
+		repo.AddPart(part)
+	}
+	registry := metrics.NewRegistry()
+	srv := NewServer(Config{Host: "127.0.0.1", Name: "repo", Repository: repo, Metrics: registry})
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(srv.Addr())
+	c := client.NewPartRepositoryClient(rpc.NewClient(conn), types.NewRemoteRefImpl(host, port, "repo"))
+	defer c.Close()
+	if _, err := c.GetPart("bolt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPart("missing"); !errors.Is(err, rpcerror.ErrNotFound) {
+		t.Fatalf("GetPart of a missing part = %v, want ErrNotFound", err)
+	}
+
+	metricsServer := httptest.NewServer(registry.Handler())
+	defer metricsServer.Close()
+	resp, err := http.Get(metricsServer.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As chamadas, os erros e as latências são contabilizados por método, e o gauge reflete o repositório
+	for _, line := range []string{
+		`partrepository_rpc_calls_total{method="PartRepository.GetPart"} 2`,
+		`partrepository_rpc_errors_total{method="PartRepository.GetPart"} 1`,
+		`partrepository_rpc_duration_seconds_count{method="PartRepository.GetPart"} 2`,
+		`partrepository_rpc_duration_seconds_bucket{method="PartRepository.GetPart",le="+Inf"} 2`,
+		`partrepository_parts 2`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", line, body)
+		}
+	}
+}
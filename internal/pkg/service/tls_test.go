@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"go-rpc/encoding"
+	"go-rpc/internal/pkg/client"
+	"go-rpc/internal/pkg/naming"
+	"go-rpc/internal/pkg/tlsconfig"
+	"go-rpc/types"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMutualTLS(t *testing.T) {
+	encoding.RegisterConcreteTypes()
+	dir := t.TempDir()
+	opts, err := tlsconfig.GenerateDevCerts(dir, []string{"127.0.0.1"}, []string{"nameserver", "repo", "client"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsTLS, err := opts[0].Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoTLS, err := opts[1].Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoClientTLS, err := opts[1].Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTLS, err := opts[2].Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Um cliente que confia na CA, mas não apresenta um certificado
+	anonymous := &tlsconfig.Options{CAFile: filepath.Join(dir, tlsconfig.CACertFile)}
+	anonymousTLS, err := anonymous.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nameServer := naming.NewNameServer("127.0.0.1", "0")
+	nameServer.SetTLSConfig(nsTLS)
+	if err := nameServer.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nameServer.Close() })
+	host, port, _ := net.SplitHostPort(nameServer.Addr())
+	nsClient := func(cfg *tls.Config) *naming.NameServerClient {
+		ns := naming.NewNameServerClient(host, port)
+		ns.SetTLSConfig(cfg)
+		return ns
+	}
+
+	repo := new(types.PartRepositoryImpl)
+	part := types.NewPartImpl("bolt", "m6")
+	part.SetCode("bolt")
+	repo.AddPart(part)
+	srv := NewServer(Config{Host: "127.0.0.1", Name: "repo", NameServer: nsClient(repoClientTLS), Repository: repo, TLS: repoTLS})
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	// Um cliente com certificado emitido pela CA resolve o repositório e o consulta
+	c, err := client.Dial(nsClient(clientTLS), "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.GetPart("bolt"); err != nil {
+		t.Errorf("GetPart with a client certificate = %v", err)
+	}
+
+	// Sem certificado, o serviço de nomes e o repositório recusam a conexão
+	if _, err := nsClient(anonymousTLS).LookupAll("repo"); err == nil {
+		t.Error("nameserver accepted a client without certificate")
+	}
+	conn, err := tls.Dial("tcp", srv.Addr(), tlsconfig.ForHost(anonymousTLS, "127.0.0.1"))
+	if err == nil {
+		// No TLS 1.3, a recusa do certificado do cliente só é percebida na primeira leitura
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil || isTimeout(err) {
+		t.Errorf("RPC server accepted a client without certificate: %v", err)
+	}
+}
+
+// isTimeout retorna true caso err seja o vencimento do prazo de uma operação de rede.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
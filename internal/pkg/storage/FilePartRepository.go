@@ -0,0 +1,388 @@
+// O pacote storage fornece uma implementação persistente da interface interfaces.PartRepository,
+// baseada num log de escrita antecipada (write-ahead log) do tipo append-only e em snapshots periódicos.
+//
+// Toda alteração no repositório é primeiro registrada no arquivo de log e sincronizada em disco,
+// e só então aplicada à estrutura em memória. Na inicialização o snapshot mais recente é carregado
+// e os registros do log posteriores a ele são reaplicados, recuperando todas as peças com seus
+// códigos, subcomponentes e referências remotas.
+//
+// Os tipos concretos das interfaces precisam estar registrados no gob (ver encoding.RegisterConcreteTypes)
+// antes da abertura do repositório.
+package storage
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"go-rpc/interfaces"
+	"go-rpc/types"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Nomes dos arquivos mantidos no diretório de dados
+const (
+	logFileName      = "wal.log"
+	snapshotFileName = "snapshot.gob"
+)
+
+// Operações registradas no log
+const (
+	opAddPart    = "add"
+	opUpdatePart = "update"
+	opDeletePart = "delete"
+)
+
+// ErrCorruptLog é devolvido por Open quando um registro do log, seguido de outros registros, está
+// corrompido. Ao contrário de um final incompleto, deixado por uma escrita interrompida, o trecho
+// não é descartado, para que os registros seguintes possam ser recuperados manualmente.
+var ErrCorruptLog = errors.New("corrupt log")
+
+// DefaultSnapshotEvery é a quantidade padrão de registros no log que dispara um novo snapshot.
+const DefaultSnapshotEvery = 1000
+
+// Estrutura logRecord representa um registro do log de escrita antecipada.
+type logRecord struct {
+	Seq     uint64          // número de sequência do registro
+	Op      string          // operação registrada
+	Part    interfaces.Part // peça incluída ou alterada pela operação
+	Code    string          // código da peça removida
+	Cascade bool            // se a remoção se estende às peças que utilizam a peça removida
+}
+
+// Estrutura snapshot representa o estado completo do repositório num dado momento.
+type snapshot struct {
+	Seq   uint64            // número de sequência do último registro incluído no snapshot
+	Parts []interfaces.Part // lista de peças do repositório
+}
+
+// Interface logFile define as operações utilizadas sobre o arquivo de log, implementadas por *os.File.
+type logFile interface {
+	io.WriteSeeker
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// Estrutura FilePartRepository representa um repositório de peças persistido em disco.
+// Ela reutiliza types.PartRepositoryImpl para manter as peças em memória e implementa a
+// interface interfaces.PartRepository, registrando cada escrita no log antes de aplicá-la.
+// As consultas, inclusive Len, são atendidas diretamente pelo repositório em memória.
+type FilePartRepository struct {
+	*types.PartRepositoryImpl // repositório em memória
+
+	mu            sync.Mutex // serializa as escritas no log e nos snapshots
+	dir           string     // diretório de dados
+	log           logFile    // arquivo de log aberto para escrita
+	offset        int64      // tamanho do log até o fim do último registro válido
+	failed        error      // falha que impediu descartar um registro incompleto; recusa as escritas seguintes
+	seq           uint64     // número de sequência do último registro escrito
+	pending       int        // quantidade de registros escritos desde o último snapshot
+	snapshotEvery int        // quantidade de registros que dispara um novo snapshot
+}
+
+// Open abre (ou cria) um repositório de peças persistente no diretório dir e recupera o seu estado.
+// Recebe também a quantidade de registros no log após a qual um novo snapshot é gerado;
+// valores menores ou iguais a zero desativam os snapshots automáticos.
+// Caso o final do log esteja corrompido por uma escrita interrompida, o trecho inválido é descartado;
+// caso um registro inválido seja seguido de outros dados, retorna ErrCorruptLog sem alterar o log.
+func Open(dir string, snapshotEvery int) (*FilePartRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	r := &FilePartRepository{
+		PartRepositoryImpl: new(types.PartRepositoryImpl),
+		dir:                dir,
+		snapshotEvery:      snapshotEvery,
+	}
+
+	// Carrega o snapshot mais recente, caso exista
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	// Abre o log e reaplica os registros posteriores ao snapshot
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.replay(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.log = f
+
+	return r, nil
+}
+
+// loadSnapshot carrega o snapshot do diretório de dados para o repositório em memória.
+func (r *FilePartRepository) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(r.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return err
+	}
+
+	for _, part := range snap.Parts {
+		if err := r.PartRepositoryImpl.AddPart(part); err != nil {
+			return err
+		}
+	}
+	r.seq = snap.Seq
+	return nil
+}
+
+// replay lê os registros do log e aplica ao repositório em memória aqueles posteriores ao snapshot.
+// Ao final, o arquivo fica posicionado no fim do último registro válido.
+func (r *FilePartRepository) replay(f *os.File) error {
+	reader := bufio.NewReader(f)
+	var offset int64
+
+	for {
+		var rec logRecord
+		n, err := readFrame(reader, &rec)
+		if err == io.EOF {
+			break
+		}
+		if err == errCorruptFrame {
+			// Apenas um registro que se estende até o fim do arquivo foi escrito parcialmente antes
+			// de uma queda do processo; os dados seguintes a um registro inválido não são descartados
+			if _, err := reader.Peek(1); err == nil {
+				return fmt.Errorf("%w: invalid record at offset %d of %s is followed by more data", ErrCorruptLog, offset, logFileName)
+			} else if err != io.EOF {
+				return err
+			}
+			log.Printf("[!] Discarding corrupt tail of %s at offset %d", logFileName, offset)
+			if err := f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(n)
+
+		// Registros já incluídos no snapshot são ignorados
+		if rec.Seq <= r.seq {
+			continue
+		}
+		// Operações recusadas originalmente são recusadas da mesma forma na recuperação,
+		// já que o estado do repositório é o mesmo, e portanto podem ser ignoradas
+		if err := r.apply(rec); err != nil && !isRejection(err) {
+			return err
+		}
+		r.seq = rec.Seq
+		r.pending++
+	}
+
+	r.offset = offset
+	_, err := f.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply aplica um registro do log ao repositório em memória.
+func (r *FilePartRepository) apply(rec logRecord) error {
+	switch rec.Op {
+	case opAddPart:
+		return r.PartRepositoryImpl.AddPart(rec.Part)
+	case opUpdatePart:
+		return r.PartRepositoryImpl.UpdatePart(rec.Part)
+	case opDeletePart:
+		_, err := r.PartRepositoryImpl.DeletePart(rec.Code, rec.Cascade)
+		return err
+	default:
+		return errors.New("unknown log operation: " + rec.Op)
+	}
+}
+
+// isRejection retorna true caso o erro corresponda à recusa de uma operação pelo repositório.
+func isRejection(err error) bool {
+	return errors.Is(err, types.ErrPartNotFound) ||
+		errors.Is(err, types.ErrPartAlreadyExists) ||
+		errors.Is(err, types.ErrPartInUse)
+}
+
+// append escreve um registro no log e o sincroniza em disco.
+// Caso a escrita ou a sincronização falhe, o registro é descartado do log (ver rollback), de forma
+// que uma escrita recusada não reapareça na recuperação e que os registros seguintes não sejam
+// escritos após um registro incompleto, o que faria a recuperação descartá-los.
+// Deve ser chamada com o mutex adquirido.
+func (r *FilePartRepository) append(rec logRecord) error {
+	if r.failed != nil {
+		return r.failed
+	}
+
+	rec.Seq = r.seq + 1
+	err := writeFrame(r.log, rec)
+	if err == nil {
+		err = r.log.Sync()
+	}
+	if err != nil {
+		if rerr := r.rollback(); rerr != nil {
+			r.failed = fmt.Errorf("log unusable after failed write: %w", rerr)
+			log.Printf("[!] %v", r.failed)
+		}
+		return err
+	}
+
+	offset, err := r.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	r.offset = offset
+	r.seq = rec.Seq
+	r.pending++
+	return nil
+}
+
+// rollback descarta do log os bytes escritos após o último registro válido e sincroniza o
+// truncamento em disco, posicionando o arquivo no fim do último registro válido.
+// Deve ser chamada com o mutex adquirido.
+func (r *FilePartRepository) rollback() error {
+	if err := r.log.Truncate(r.offset); err != nil {
+		return err
+	}
+	if _, err := r.log.Seek(r.offset, io.SeekStart); err != nil {
+		return err
+	}
+	return r.log.Sync()
+}
+
+// maybeSnapshot gera um snapshot caso a quantidade de registros desde o último snapshot
+// atinja o limite configurado. Deve ser chamada com o mutex adquirido.
+func (r *FilePartRepository) maybeSnapshot() {
+	if r.snapshotEvery <= 0 || r.pending < r.snapshotEvery {
+		return
+	}
+	// Uma falha no snapshot não compromete a durabilidade, já que o log continua íntegro
+	if err := r.snapshot(); err != nil {
+		log.Printf("[!] Snapshot failed: %v", err)
+	}
+}
+
+// snapshot escreve o estado completo do repositório num arquivo temporário, o renomeia
+// atomicamente para o arquivo de snapshot e então esvazia o log.
+// Deve ser chamada com o mutex adquirido.
+func (r *FilePartRepository) snapshot() error {
+	path := filepath.Join(r.dir, snapshotFileName)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	snap := snapshot{Seq: r.seq, Parts: r.PartRepositoryImpl.GetParts()}
+	if err := gob.NewEncoder(f).Encode(&snap); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	// Os registros do log agora estão contidos no snapshot. Caso o processo caia antes do
+	// truncamento, eles são ignorados na recuperação pelo número de sequência.
+	if err := r.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := r.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.offset = 0
+	r.pending = 0
+	return nil
+}
+
+// AddPart registra a inclusão da peça no log e então a adiciona ao repositório em memória.
+// Retorna um erro caso o registro não possa ser persistido, situação em que a peça não é adicionada.
+func (r *FilePartRepository) AddPart(part interfaces.Part) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.append(logRecord{Op: opAddPart, Part: part}); err != nil {
+		return err
+	}
+	if err := r.PartRepositoryImpl.AddPart(part); err != nil {
+		return err
+	}
+	r.maybeSnapshot()
+	return nil
+}
+
+// UpdatePart registra a alteração da peça no log e então a aplica ao repositório em memória.
+// Caso a alteração seja recusada pelo repositório (por exemplo, peça inexistente), o registro
+// permanece no log e é recusado da mesma forma na recuperação.
+func (r *FilePartRepository) UpdatePart(part interfaces.Part) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.append(logRecord{Op: opUpdatePart, Part: part}); err != nil {
+		return err
+	}
+	if err := r.PartRepositoryImpl.UpdatePart(part); err != nil {
+		return err
+	}
+	r.maybeSnapshot()
+	return nil
+}
+
+// DeletePart registra a remoção da peça no log e então a aplica ao repositório em memória,
+// retornando os códigos das peças removidas.
+func (r *FilePartRepository) DeletePart(code string, cascade bool) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.append(logRecord{Op: opDeletePart, Code: code, Cascade: cascade}); err != nil {
+		return nil, err
+	}
+	deleted, err := r.PartRepositoryImpl.DeletePart(code, cascade)
+	if err != nil {
+		return nil, err
+	}
+	r.maybeSnapshot()
+	return deleted, nil
+}
+
+// Snapshot força a geração de um snapshot do repositório, compactando o log.
+func (r *FilePartRepository) Snapshot() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshot()
+}
+
+// Close gera um snapshot final e fecha o arquivo de log.
+// O repositório não deve ser utilizado após o fechamento.
+func (r *FilePartRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.snapshot()
+	if cerr := r.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
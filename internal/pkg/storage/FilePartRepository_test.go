@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"go-rpc/encoding"
+	"go-rpc/interfaces"
+	"go-rpc/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	encoding.RegisterConcreteTypes()
+}
+
+// newPart retorna uma peça com o código e o nome informados.
+func newPart(code string, name string) *types.PartImpl {
+	part := types.NewPartImpl(name, "")
+	part.SetCode(code)
+	return part
+}
+
+// open abre o repositório no diretório dir, interrompendo o teste caso falhe.
+func open(t *testing.T, dir string, snapshotEvery int) *FilePartRepository {
+	t.Helper()
+	r, err := Open(dir, snapshotEvery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// crash fecha o log sem gerar o snapshot final, como numa queda do processo.
+func crash(r *FilePartRepository) {
+	r.log.Close()
+}
+
+// codes retorna os códigos das peças do repositório, na ordem de inserção.
+func codes(r *FilePartRepository) []string {
+	var codes []string
+	for _, part := range r.GetParts() {
+		codes = append(codes, part.GetCode())
+	}
+	return codes
+}
+
+// assertCodes verifica se o repositório possui exatamente as peças com os códigos informados.
+func assertCodes(t *testing.T, r *FilePartRepository, want ...string) {
+	t.Helper()
+	got := codes(r)
+	if len(got) != len(want) {
+		t.Fatalf("parts = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("parts = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	r := open(t, dir, 0)
+	for _, code := range []string{"a", "b", "c"} {
+		if err := r.AddPart(newPart(code, "part")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.UpdatePart(newPart("b", "renamed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeletePart("c", false); err != nil {
+		t.Fatal(err)
+	}
+	crash(r)
+
+	r = open(t, dir, 0)
+	defer r.Close()
+	assertCodes(t, r, "a", "b")
+	if name := r.GetPart("b").GetName(); name != "renamed" {
+		t.Errorf("b.name = %q, want renamed", name)
+	}
+}
+
+func TestTornTailIsDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	r := open(t, dir, 0)
+	if err := r.AddPart(newPart("a", "part")); err != nil {
+		t.Fatal(err)
+	}
+	crash(r)
+
+	// Simula uma escrita interrompida: um cabeçalho seguido de parte do conteúdo
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], 100)
+	f.Write(append(header[:], "partial"...))
+	f.Close()
+
+	// O final é descartado e as escritas seguintes são recuperadas
+	r = open(t, dir, 0)
+	if err := r.AddPart(newPart("b", "part")); err != nil {
+		t.Fatal(err)
+	}
+	crash(r)
+
+	r = open(t, dir, 0)
+	defer r.Close()
+	assertCodes(t, r, "a", "b")
+}
+
+func TestOversizedFrameIsCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], 0xFFFFFFFF)
+	if err := os.WriteFile(filepath.Join(dir, logFileName), header[:], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := open(t, dir, 0)
+	defer r.Close()
+	assertCodes(t, r)
+	if info, err := os.Stat(filepath.Join(dir, logFileName)); err != nil || info.Size() != 0 {
+		t.Fatalf("log was not truncated: %v %v", info, err)
+	}
+}
+
+// Estrutura faultyLog envolve o arquivo de log, simulando falhas na próxima escrita ou sincronização.
+type faultyLog struct {
+	*os.File
+	failWrite bool // a próxima escrita grava apenas parte dos bytes e falha
+	failSync  bool // a próxima sincronização falha, após a escrita ter sido feita
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *faultyLog) Write(b []byte) (int, error) {
+	if f.failWrite {
+		f.failWrite = false
+		n, _ := f.File.Write(b[:len(b)/2])
+		return n, errInjected
+	}
+	return f.File.Write(b)
+}
+
+func (f *faultyLog) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+func TestFailedAppendIsRolledBack(t *testing.T) {
+	dir := t.TempDir()
+	r := open(t, dir, 0)
+	faulty := &faultyLog{File: r.log.(*os.File)}
+	r.log = faulty
+
+	if err := r.AddPart(newPart("a", "part")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Escrita parcial: a peça é recusada e não deixa um registro incompleto no log
+	faulty.failWrite = true
+	if err := r.AddPart(newPart("torn", "part")); !errors.Is(err, errInjected) {
+		t.Fatalf("AddPart = %v, want injected failure", err)
+	}
+
+	// Escrita completa, mas não sincronizada: a peça é recusada e não reaparece na recuperação
+	faulty.failSync = true
+	if err := r.AddPart(newPart("unsynced", "part")); !errors.Is(err, errInjected) {
+		t.Fatalf("AddPart = %v, want injected failure", err)
+	}
+
+	// As escritas seguintes são recuperadas
+	if err := r.AddPart(newPart("b", "part")); err != nil {
+		t.Fatal(err)
+	}
+	assertCodes(t, r, "a", "b")
+	crash(r)
+
+	r = open(t, dir, 0)
+	defer r.Close()
+	assertCodes(t, r, "a", "b")
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	r := open(t, dir, 2)
+	var parts []interfaces.Part
+	for _, code := range []string{"a", "b", "c"} {
+		part := newPart(code, "part")
+		parts = append(parts, part)
+		if err := r.AddPart(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// O snapshot automático, após dois registros, esvaziou o log, que contém apenas o terceiro
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("snapshot was not written: %v", err)
+	}
+	if r.pending != 1 {
+		t.Fatalf("pending = %d, want 1", r.pending)
+	}
+	if _, err := r.DeletePart("a", false); err != nil {
+		t.Fatal(err)
+	}
+	crash(r)
+
+	r = open(t, dir, 2)
+	assertCodes(t, r, "b", "c")
+
+	// O snapshot final do fechamento contém todo o estado, e o log fica vazio
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, logFileName)); err != nil || info.Size() != 0 {
+		t.Fatalf("log not empty after Close: %v %v", info, err)
+	}
+	r = open(t, dir, 2)
+	defer r.Close()
+	assertCodes(t, r, "b", "c")
+}
+
+func TestCorruptMiddleFrameFailsOpen(t *testing.T) {
+	dir := t.TempDir()
+	r := open(t, dir, 0)
+	for _, code := range []string{"a", "b", "c"} {
+		if err := r.AddPart(newPart(code, "part")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	crash(r)
+
+	// Altera um bit do conteúdo do primeiro registro, seguido de registros válidos
+	path := filepath.Join(dir, logFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[frameHeaderSize+1] ^= 1
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := Open(dir, 0); !errors.Is(err, ErrCorruptLog) {
+		if err == nil {
+			r.Close()
+		}
+		t.Fatalf("Open = %v, want ErrCorruptLog", err)
+	}
+	// O log não é truncado, para que os registros seguintes possam ser recuperados
+	if info, err := os.Stat(path); err != nil || info.Size() != int64(len(data)) {
+		t.Fatalf("log was modified: %v %v", info, err)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Tamanho, em bytes, do cabeçalho de cada registro do log: 4 bytes para o tamanho
+// do conteúdo e 4 bytes para o checksum CRC-32 do conteúdo.
+const frameHeaderSize = 8
+
+// maxFrameSize é o tamanho máximo, em bytes, do conteúdo de um registro. Registros maiores são
+// recusados na escrita, e um cabeçalho que indique um tamanho maior é tratado como corrompido
+// na leitura, em vez de alocar a memória indicada por ele.
+const maxFrameSize = 64 << 20
+
+// errCorruptFrame sinaliza que um registro do log está truncado ou com checksum inválido,
+// o que acontece quando o processo é interrompido no meio de uma escrita.
+var errCorruptFrame = errors.New("corrupt log frame")
+
+// errFrameTooLarge sinaliza que um registro excede maxFrameSize e não pode ser escrito.
+var errFrameTooLarge = errors.New("log frame too large")
+
+// writeFrame serializa o valor v via gob e o escreve em w no formato
+// [tamanho][crc32][conteúdo].
+// Cada registro é codificado com um encoder próprio, de forma que o arquivo possa
+// continuar a ser estendido após reinicializações do servidor sem misturar fluxos gob.
+func writeFrame(w io.Writer, v interface{}) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(v); err != nil {
+		return err
+	}
+	if payload.Len() > maxFrameSize {
+		return errFrameTooLarge
+	}
+
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+
+	// Escreve cabeçalho e conteúdo numa única chamada para reduzir a janela de escrita parcial
+	_, err := w.Write(append(header[:], payload.Bytes()...))
+	return err
+}
+
+// readFrame lê o próximo registro de r e o desserializa em v.
+// Retorna io.EOF quando não há mais registros e errCorruptFrame caso o registro
+// esteja incompleto ou não passe na verificação do checksum.
+// O inteiro retornado é a quantidade de bytes consumidos do leitor.
+func readFrame(r *bufio.Reader, v interface{}) (int, error) {
+	var header [frameHeaderSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return n, errCorruptFrame
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if size > maxFrameSize {
+		return n, errCorruptFrame
+	}
+
+	payload := make([]byte, size)
+	m, err := io.ReadFull(r, payload)
+	n += m
+	if err != nil || crc32.ChecksumIEEE(payload) != checksum {
+		return n, errCorruptFrame
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// syncDir faz o fsync do diretório informado, garantindo que operações como rename
+// sobre arquivos desse diretório sejam persistidas.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
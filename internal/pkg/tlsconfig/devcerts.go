@@ -0,0 +1,137 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Nomes dos arquivos da CA gerada por GenerateDevCerts. Os certificados de cada componente são
+// gravados como <nome>.pem e <nome>-key.pem.
+const (
+	CACertFile = "ca.pem"
+	CAKeyFile  = "ca-key.pem"
+)
+
+// GenerateDevCerts gera, no diretório dir, uma CA descartável e um certificado emitido por ela
+// para cada um dos nomes informados, válidos pelo período validity. Os certificados valem para
+// os hosts informados (nomes DNS ou endereços IP) e podem ser utilizados tanto por servidores
+// quanto por clientes, de forma que sirvam à autenticação mútua entre todos os componentes.
+// Retorna as opções TLS de cada nome, na ordem informada.
+//
+// Os certificados destinam-se apenas a testes locais: a chave da CA é gravada junto com eles.
+func GenerateDevCerts(dir string, hosts []string, names []string, validity time.Duration) ([]Options, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now().Add(-time.Minute)
+	notAfter := notBefore.Add(validity)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          serial(),
+		Subject:               pkix.Name{CommonName: "go-rpc development CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+	caFile := filepath.Join(dir, CACertFile)
+	if err := writePEM(caFile, "CERTIFICATE", caDER); err != nil {
+		return nil, err
+	}
+	if err := writeKey(filepath.Join(dir, CAKeyFile), caKey); err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	var dnsNames []string
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, h)
+		}
+	}
+
+	var opts []Options
+	for _, name := range names {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		template := &x509.Certificate{
+			SerialNumber: serial(),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			IPAddresses:  ips,
+			DNSNames:     dnsNames,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			return nil, err
+		}
+
+		o := Options{
+			CertFile: filepath.Join(dir, name+".pem"),
+			KeyFile:  filepath.Join(dir, name+"-key.pem"),
+			CAFile:   caFile,
+		}
+		if err := writePEM(o.CertFile, "CERTIFICATE", der); err != nil {
+			return nil, err
+		}
+		if err := writeKey(o.KeyFile, key); err != nil {
+			return nil, err
+		}
+		opts = append(opts, o)
+	}
+	return opts, nil
+}
+
+// serial retorna um número de série aleatório de 128 bits.
+func serial() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(fmt.Sprint("tls: random serial: ", err))
+	}
+	return n
+}
+
+// writeKey grava a chave privada key no arquivo PEM file, legível apenas pelo usuário.
+func writeKey(file string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(file, "EC PRIVATE KEY", der)
+}
+
+// writePEM grava o bloco PEM do tipo informado no arquivo file, legível apenas pelo usuário.
+func writePEM(file string, blockType string, der []byte) error {
+	return os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+}
@@ -0,0 +1,126 @@
+// O pacote tlsconfig fornece a configuração TLS compartilhada pelo serviço de nomes, pelos
+// servidores de repositório e pelos clientes, a partir dos arquivos de certificado, chave e
+// autoridade certificadora (CA) informados nas flags dos executáveis.
+//
+// Todos os componentes de uma instalação utilizam certificados emitidos pela mesma CA. Quando a
+// CA é informada ao servidor, a autenticação é mútua (mTLS): o servidor exige e verifica o
+// certificado do cliente. Quando é informada ao cliente, o certificado do servidor é verificado
+// contra ela, e não contra as CAs do sistema.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Estrutura Options representa os arquivos da configuração TLS de um componente.
+// O mesmo certificado é apresentado pelo componente tanto quando atende conexões quanto quando
+// se conecta a outros componentes (por exemplo, um servidor de repositório ao serviço de nomes).
+type Options struct {
+	CertFile string // certificado do componente, em PEM
+	KeyFile  string // chave privada do certificado, em PEM
+	CAFile   string // certificado da CA que emitiu os certificados dos demais componentes, em PEM
+}
+
+// RegisterFlags define no conjunto de flags fs as flags -tls-cert, -tls-key e -tls-ca, que
+// preenchem as propriedades da estrutura Options.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.CertFile, "tls-cert", "", "TLS certificate file (TLS disabled if empty)")
+	fs.StringVar(&o.KeyFile, "tls-key", "", "TLS private key file")
+	fs.StringVar(&o.CAFile, "tls-ca", "", "CA certificate file used to verify peers (enables mutual TLS on servers)")
+}
+
+// Enabled retorna true caso o TLS tenha sido configurado, isto é, caso um certificado ou uma CA
+// tenham sido informados.
+func (o *Options) Enabled() bool {
+	return o.CertFile != "" || o.CAFile != ""
+}
+
+// Server retorna a configuração TLS para atender conexões, ou nil caso o TLS não tenha sido configurado.
+// O certificado e a chave são obrigatórios; caso a CA tenha sido informada, os clientes devem
+// apresentar um certificado emitido por ela.
+func (o *Options) Server() (*tls.Config, error) {
+	if !o.Enabled() {
+		return nil, nil
+	}
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, errors.New("tls: server requires both certificate and key")
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if o.CAFile != "" {
+		pool, err := loadPool(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// Client retorna a configuração TLS para se conectar a outros componentes, ou nil caso o TLS
+// não tenha sido configurado. Caso a CA tenha sido informada, o certificado do servidor é
+// verificado contra ela; caso o certificado e a chave tenham sido informados, são apresentados
+// ao servidor, que pode exigi-los.
+func (o *Options) Client() (*tls.Config, error) {
+	if !o.Enabled() {
+		return nil, nil
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("tls: client certificate and key must be given together")
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if o.CAFile != "" {
+		pool, err := loadPool(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// ForHost retorna uma cópia da configuração TLS de cliente cfg que verifica o certificado do
+// servidor contra o host informado, utilizada nas conexões TCP, em que o host não é deduzido
+// automaticamente como nas requisições HTTP. Retorna nil caso cfg seja nulo.
+func ForHost(cfg *tls.Config, host string) *tls.Config {
+	if cfg == nil {
+		return nil
+	}
+	cfg = cfg.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// loadPool retorna um conjunto de certificados com os certificados do arquivo PEM informado.
+func loadPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", file)
+	}
+	return pool, nil
+}
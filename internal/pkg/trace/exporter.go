@@ -0,0 +1,92 @@
+package trace
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Interface Exporter define o destino das etapas encerradas.
+type Exporter interface {
+	// Export registra a etapa encerrada span.
+	Export(span *Span) error
+}
+
+// Exportador e nome do processo utilizados por todas as etapas, definidos com SetExporter e SetServiceName.
+var (
+	mu       sync.RWMutex
+	exporter Exporter
+	service  string
+)
+
+// SetExporter define o exportador das etapas encerradas. Sem ele, as etapas não são exportadas,
+// mas os seus identificadores continuam sendo propagados.
+func SetExporter(e Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	exporter = e
+}
+
+// SetServiceName define o nome do processo registrado nas etapas iniciadas a partir de então
+// (ex.: o nome do repositório de peças).
+func SetServiceName(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	service = name
+}
+
+// ServiceName retorna o nome do processo definido com SetServiceName.
+func ServiceName() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return service
+}
+
+// getExporter retorna o exportador definido com SetExporter, ou nulo.
+func getExporter() Exporter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return exporter
+}
+
+// Estrutura FileExporter implementa a interface Exporter escrevendo cada etapa como um objeto
+// JSON numa linha de um arquivo (JSON-lines). Vários processos podem exportar para o mesmo
+// arquivo, já que ele é aberto em modo de acréscimo e cada linha é escrita de uma única vez;
+// as linhas com o mesmo trace_id formam uma operação completa, ligada pelos campos parent_id.
+// A estrutura é segura para uso concorrente.
+type FileExporter struct {
+	mu   sync.Mutex // protege o arquivo
+	file *os.File   // arquivo de destino
+}
+
+// NewFileExporter abre, ou cria, o arquivo path em modo de acréscimo e retorna o ponteiro para
+// uma estrutura FileExporter que escreve nele.
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: file}, nil
+}
+
+// Export escreve a etapa span como uma linha do arquivo. Implementa a interface Exporter.
+func (f *FileExporter) Export(span *Span) error {
+	span.mu.Lock()
+	line, err := json.Marshal(span)
+	span.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close fecha o arquivo. As etapas exportadas depois disso são descartadas com erro.
+func (f *FileExporter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,38 @@
+package trace
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Handler envolve o handler h, atendendo cada requisição numa etapa filha da etapa do cabeçalho
+// traceparent (ver Extract), nomeada com o método e o caminho da requisição. A etapa é a etapa
+// corrente do contexto da requisição repassada a h, e é encerrada, e registrada no log, ao final
+// do atendimento; as respostas com código de status 4xx ou 5xx são registradas como falhas.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := StartRemote(Extract(r.Header), r.Method+" "+r.URL.Path)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(recorder, r.WithContext(ContextWithSpanContext(r.Context(), span.Context())))
+
+		var err error
+		if recorder.status >= 400 {
+			err = fmt.Errorf("HTTP %d %s", recorder.status, http.StatusText(recorder.status))
+		}
+		span.End(err)
+		log.Println("[!]", span)
+	})
+}
+
+// Estrutura statusRecorder envolve um http.ResponseWriter, registrando o código de status da resposta.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int // código de status da resposta
+}
+
+// WriteHeader registra e escreve o código de status da resposta. Implementa a interface http.ResponseWriter.
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
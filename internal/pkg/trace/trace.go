@@ -0,0 +1,191 @@
+// O pacote trace fornece identificadores de rastreamento (trace) e de etapa (span) propagados
+// entre os clientes e os servidores, de forma que os logs de uma mesma operação, distribuída entre
+// o serviço de nomes e vários repositórios de peças, possam ser correlacionados.
+//
+// Um trace agrupa todas as etapas de uma operação, e cada etapa (Span) registra o seu nome, o seu
+// intervalo, o resultado e a etapa que a originou. A etapa corrente é transportada no
+// context.Context (ver Start); entre processos, ela é transmitida no cabeçalho das chamadas RPC
+// (ver server.Header) e no cabeçalho HTTP traceparent, no formato do W3C Trace Context (ver Inject
+// e Extract). As etapas encerradas podem ser exportadas, por exemplo para um arquivo JSON-lines
+// (ver SetExporter e FileExporter), para a reconstrução posterior da operação completa.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader é o nome do cabeçalho HTTP que transporta a etapa do chamador.
+const TraceparentHeader = "traceparent"
+
+// Estrutura SpanContext representa a identificação de uma etapa, propagada entre os processos.
+type SpanContext struct {
+	TraceID string // identificador do trace, com 32 dígitos hexadecimais
+	SpanID  string // identificador da etapa, com 16 dígitos hexadecimais
+}
+
+// IsValid retorna verdadeiro caso os dois identificadores tenham sido definidos com o número de
+// dígitos hexadecimais esperado. Os identificadores recebidos de outros processos são registrados
+// no log e devem ser verificados, para que não sejam utilizados para forjar linhas do log.
+func (sc SpanContext) IsValid() bool {
+	return isHex(sc.TraceID, 32) && isHex(sc.SpanID, 16)
+}
+
+// Estrutura Span representa uma etapa de um trace, como uma chamada RPC feita por um cliente ou
+// atendida por um servidor. É serializada como uma linha do arquivo exportado por FileExporter.
+type Span struct {
+	TraceID    string            `json:"trace_id"`             // identificador do trace
+	SpanID     string            `json:"span_id"`              // identificador da etapa
+	ParentID   string            `json:"parent_id,omitempty"`  // identificador da etapa que originou esta; vazio na etapa raiz
+	Name       string            `json:"name"`                 // nome da etapa, por exemplo o método RPC
+	Service    string            `json:"service,omitempty"`    // nome do processo que executou a etapa
+	Start      time.Time         `json:"start"`                // instante de início
+	Duration   time.Duration     `json:"duration_ns"`          // duração, em nanossegundos
+	Error      string            `json:"error,omitempty"`      // mensagem de erro, caso a etapa tenha falhado
+	Attributes map[string]string `json:"attributes,omitempty"` // atributos adicionais (ex.: código da peça)
+
+	mu sync.Mutex // protege os atributos e o encerramento
+}
+
+// Context retorna a identificação da etapa, que deve ser propagada às chamadas que ela originar.
+func (s *Span) Context() SpanContext {
+	return SpanContext{TraceID: s.TraceID, SpanID: s.SpanID}
+}
+
+// SetAttribute define o atributo key da etapa com o valor value.
+func (s *Span) SetAttribute(key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// End encerra a etapa com o erro err, nulo em caso de sucesso, e a entrega ao exportador definido
+// com SetExporter. Chamadas posteriores não têm efeito.
+func (s *Span) End(err error) {
+	s.mu.Lock()
+	if s.Duration != 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.Duration = time.Since(s.Start)
+	if s.Duration <= 0 {
+		s.Duration = time.Nanosecond
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	if exporter := getExporter(); exporter != nil {
+		if err := exporter.Export(s); err != nil {
+			log.Println("[!] Failed to export span:", err)
+		}
+	}
+}
+
+// String retorna a descrição da etapa utilizada nos logs dos servidores, com os seus identificadores.
+func (s *Span) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent := s.ParentID
+	if parent == "" {
+		parent = "-"
+	}
+	desc := fmt.Sprintf("%s trace=%s span=%s parent=%s", s.Name, s.TraceID, s.SpanID, parent)
+	if s.Duration != 0 {
+		desc += " duration=" + s.Duration.String()
+	}
+	if s.Error != "" {
+		desc += " error=" + fmt.Sprintf("%q", s.Error)
+	}
+	return desc
+}
+
+// Tipo contextKey é o tipo da chave da etapa corrente no context.Context.
+type contextKey struct{}
+
+// ContextWithSpanContext retorna uma cópia de ctx cuja etapa corrente é sc. É utilizada pelos
+// servidores para continuar o trace recebido de outro processo.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// FromContext retorna a etapa corrente do contexto ctx, ou uma identificação vazia caso não haja.
+func FromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+// Start inicia uma etapa com o nome informado, filha da etapa corrente de ctx ou, caso não haja,
+// raiz de um novo trace, e retorna uma cópia de ctx cuja etapa corrente é a nova etapa.
+// A etapa deve ser encerrada com Span.End.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := StartRemote(FromContext(ctx), name)
+	return ContextWithSpanContext(ctx, span.Context()), span
+}
+
+// StartRemote inicia uma etapa com o nome informado, filha da etapa parent recebida de outro
+// processo ou, caso parent seja vazia ou inválida (ver SpanContext.IsValid), raiz de um novo trace.
+func StartRemote(parent SpanContext, name string) *Span {
+	span := &Span{TraceID: parent.TraceID, ParentID: parent.SpanID, SpanID: newID(8), Name: name, Service: ServiceName(), Start: time.Now()}
+	if !parent.IsValid() {
+		span.TraceID, span.ParentID = newID(16), ""
+	}
+	return span
+}
+
+// newID retorna um identificador aleatório de n bytes, em hexadecimal.
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("trace: failed to generate id: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Inject define o cabeçalho traceparent de h com a etapa sc, no formato
+// "00-<trace id>-<span id>-01". Não faz nada caso sc seja vazia.
+func Inject(h http.Header, sc SpanContext) {
+	if sc.IsValid() {
+		h.Set(TraceparentHeader, "00-"+sc.TraceID+"-"+sc.SpanID+"-01")
+	}
+}
+
+// Extract retorna a etapa do cabeçalho traceparent de h, ou uma identificação vazia caso o
+// cabeçalho esteja ausente ou mal formado.
+func Extract(h http.Header) SpanContext {
+	parts := strings.Split(h.Get(TraceparentHeader), "-")
+	if len(parts) != 4 {
+		return SpanContext{}
+	}
+	if sc := (SpanContext{TraceID: parts[1], SpanID: parts[2]}); sc.IsValid() {
+		return sc
+	}
+	return SpanContext{}
+}
+
+// isHex retorna verdadeiro caso s tenha n dígitos hexadecimais minúsculos.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,15 @@
+package types
+
+import "errors"
+
+// Erros devolvidos pelas implementações de interfaces.PartRepository.
+// Como a biblioteca net/rpc transmite erros apenas como texto, as mensagens servem
+// também para identificar o erro do lado do cliente.
+var (
+	ErrPartNotFound      = errors.New("part not found")                 // peça não encontrada no repositório
+	ErrPartAlreadyExists = errors.New("part already exists")            // já existe peça com o mesmo código
+	ErrPartInUse         = errors.New("part is in use as subcomponent") // peça é subcomponente de outra peça do repositório
+	ErrPartCycle         = errors.New("part composition has a cycle")   // peça é, direta ou indiretamente, subcomponente de si mesma
+	ErrPartTooDeep       = errors.New("part composition is too deep")   // composição da peça excede a profundidade máxima
+	ErrInvalidPageToken  = errors.New("invalid page token")             // token de continuação de uma listagem paginada inválido
+)
@@ -11,9 +11,16 @@ import (
 
 // Estrutura PairImpl representa um par (elemento, quantidade).
 // Ela implementa as interfaces interfaces.Pair e fmt.Stringer (que define o método String() que altera o comportamento do print, útil para o propósito de log e debug, equivalente ao toString()).
+//
+// O par armazena uma cópia da peça (SubPart), criado com NewPairImpl, ou uma referência à peça
+// (Repository e Code), criado com NewPairRefImpl. A referência é preferível para peças de outros
+// repositórios, já que não congela o estado da peça no momento da criação do par e é muito menor
+// quando transmitida.
 type PairImpl struct {
-	SubPart  interfaces.Part // peça do par
-	Quantity int             // quantidada peça
+	SubPart    interfaces.Part // peça do par, nula caso o par seja uma referência
+	Repository string          // nome do repositório que contém a peça, caso o par seja uma referência
+	Code       string          // código da peça, caso o par seja uma referência
+	Quantity   int             // quantidada peça
 }
 
 // NewPairImpl retorna o ponteiro para uma estrutura PairImpl.
@@ -22,11 +29,38 @@ func NewPairImpl(subPart interfaces.Part, quantity int) *PairImpl {
 	return &PairImpl{SubPart: subPart, Quantity: quantity}
 }
 
-// GetElement retorna a propriedade SubPart da estrutura PairImpl.
+// NewPairRefImpl retorna o ponteiro para uma estrutura PairImpl que referencia uma peça.
+// Ela recebe como parâmetro o nome do repositório que contém a peça, o código da peça e a quantidade.
+func NewPairRefImpl(repository string, code string, quantity int) *PairImpl {
+	return &PairImpl{Repository: repository, Code: code, Quantity: quantity}
+}
+
+// GetPart retorna a propriedade SubPart da estrutura PairImpl, que é nula caso o par seja uma referência.
 func (p PairImpl) GetPart() interfaces.Part {
 	return p.SubPart
 }
 
+// GetPartCode retorna o código da peça do par.
+func (p PairImpl) GetPartCode() string {
+	if p.SubPart != nil {
+		return p.SubPart.GetCode()
+	}
+	return p.Code
+}
+
+// GetRepositoryName retorna o nome do repositório que contém a peça do par.
+func (p PairImpl) GetRepositoryName() string {
+	if p.SubPart != nil {
+		return p.SubPart.GetRepositoryName()
+	}
+	return p.Repository
+}
+
+// IsReference retorna true caso o par seja uma referência à peça, e não uma cópia.
+func (p PairImpl) IsReference() bool {
+	return p.SubPart == nil
+}
+
 // GetQuantity retorna a propriedade Quantity da estrutura PairImpl.
 func (p PairImpl) GetQuantity() int {
 	return p.Quantity
@@ -41,5 +75,8 @@ func (p *PairImpl) SetQuantity(quantity int) {
 // String retorna uma string que descreve a própria estrutura PairImpl como uma string
 func (p PairImpl) String() string {
 	// %#v mostra a estrutura com os atributos e seus respectivos valores
+	if p.IsReference() {
+		return fmt.Sprintf("Par{Ref{Repositório: %s, Código: %s}, Quantidade: %d]}", p.Repository, p.Code, p.GetQuantity())
+	}
 	return fmt.Sprintf("Par{%s, Quantidade: %d]}", p.SubPart, p.GetQuantity())
 }
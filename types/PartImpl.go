@@ -56,8 +56,12 @@ func (p PartImpl) IsPrimitive() bool {
 	return len(p.Subcomponents) == 0
 }
 
-// GetRepositoryName retorna o nome do servidor que contém a peça
+// GetRepositoryName retorna o nome do servidor que contém a peça, ou uma string vazia caso
+// a peça ainda não tenha sido armazenada num repositório.
 func (p PartImpl) GetRepositoryName() string {
+	if p.Ref == nil {
+		return ""
+	}
 	return p.Ref.GetName()
 }
 
@@ -1,34 +1,347 @@
 package types
 
-import "go-rpc/interfaces"
+import (
+	"fmt"
+	"go-rpc/interfaces"
+	"sort"
+	"strings"
+	"sync"
+)
 
 // Estrutura PartImpl representa uma repositório de peças.
-// Ela implementa a interface interfaces.PartRepository.
+// Ela implementa a interface interfaces.PartRepository e é segura para uso concorrente:
+// escritas são exclusivas e leituras podem ocorrer em paralelo.
+// As peças armazenadas não devem ser alteradas após a inserção.
+//
+// Além da lista de peças, na ordem de inserção, o repositório mantém um índice primário
+// pelo código, índices secundários pelo nome e pelo tipo (primitiva ou agregada) da peça e um
+// índice reverso dos subcomponentes (onde cada peça é utilizada), atualizados a cada escrita. O valor zero da estrutura é um repositório vazio pronto para uso.
 type PartRepositoryImpl struct {
-	parts []interfaces.Part // lista de peças
+	mu        sync.RWMutex               // protege a lista de peças e os índices
+	parts     []interfaces.Part          // lista de peças
+	byCode    map[string]int             // posição de cada peça na lista, indexada pelo código
+	codes     sortedSet                  // códigos em ordem lexicográfica, para listagens paginadas
+	byName    map[string]map[string]bool // códigos das peças, indexados pelo nome
+	names     sortedSet                  // nomes distintos em ordem lexicográfica, para buscas por prefixo
+	primitive map[string]bool            // códigos das peças primitivas
+	aggregate map[string]bool            // códigos das peças agregadas
+	usedBy    map[string]map[string]bool // códigos das peças que utilizam cada peça como subcomponente direto, indexados pelo código do subcomponente
 }
 
 // AddPart adiciona um objeto que implementa a interface interfaces.Part à
-// sua lista de peças.
-func (p *PartRepositoryImpl) AddPart(part interfaces.Part) {
+// sua lista de peças e atualiza os índices.
+// Retorna ErrPartAlreadyExists caso já exista uma peça com o mesmo código.
+func (p *PartRepositoryImpl) AddPart(part interfaces.Part) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.byCode[part.GetCode()]; ok {
+		return fmt.Errorf("%w: %s", ErrPartAlreadyExists, part.GetCode())
+	}
+
 	p.parts = append(p.parts, part)
+	p.index(part, len(p.parts)-1)
+	return nil
 }
 
 // GetPart retorna uma peça a partir do seu código.
 // Ela recebe como parâmetro uma string que representa um código e faz
-// uma busca linear O(n) na lista de peças, retornando-a caso seja encontrada.
+// uma busca O(1) no índice de códigos, retornando-a caso seja encontrada.
 // Retorna nil caso a peça não seja encontrada.
 func (p *PartRepositoryImpl) GetPart(code string) interfaces.Part {
-	for i := 0; i < len(p.parts); i++ {
-		// Compara o código da peça ao parâmetro
-		if p.parts[i].GetCode() == code {
-			return p.parts[i]
-		}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if i, ok := p.byCode[code]; ok {
+		return p.parts[i]
 	}
 	return nil
 }
 
-// GetParts retorna a lista de peças da estrutura PartImpl.
+// GetParts retorna uma cópia da lista de peças da estrutura PartImpl.
+// A cópia representa o estado do repositório no momento da chamada e não é
+// afetada por escritas posteriores.
 func (p *PartRepositoryImpl) GetParts() []interfaces.Part {
-	return p.parts
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	parts := make([]interfaces.Part, len(p.parts))
+	copy(parts, p.parts)
+	return parts
+}
+
+// Len retorna o número de peças do repositório, sem copiar a lista de peças como GetParts.
+func (p *PartRepositoryImpl) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.parts)
+}
+
+// ListParts retorna até limit peças com código maior que after, em ordem crescente de código.
+// Caso limit não seja positivo, retorna todas as peças seguintes. A ordem não depende da ordem de
+// inserção, de forma que uma listagem paginada, que informa em after o último código da página
+// anterior, não repete nem omite peças que permaneceram no repositório entre as páginas.
+func (p *PartRepositoryImpl) ListParts(after string, limit int) []interfaces.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	n := p.codes.first(after)
+	if n != nil && n.value == after {
+		n = n.successor()
+	}
+
+	var parts []interfaces.Part
+	for ; n != nil && (limit <= 0 || len(parts) < limit); n = n.successor() {
+		parts = append(parts, p.parts[p.byCode[n.value]])
+	}
+	if parts == nil {
+		parts = []interfaces.Part{}
+	}
+	return parts
+}
+
+// FindPartsByName retorna as peças cujo nome é exatamente igual ao nome informado,
+// na ordem de inserção.
+func (p *PartRepositoryImpl) FindPartsByName(name string) []interfaces.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.collect(p.byName[name])
+}
+
+// FindPartsByNamePrefix retorna as peças cujo nome começa com o prefixo informado,
+// na ordem de inserção. A busca é feita sobre o conjunto ordenado de nomes distintos.
+func (p *PartRepositoryImpl) FindPartsByNamePrefix(prefix string) []interfaces.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	codes := make(map[string]bool)
+	for n := p.names.first(prefix); n != nil && strings.HasPrefix(n.value, prefix); n = n.successor() {
+		for code := range p.byName[n.value] {
+			codes[code] = true
+		}
+	}
+	return p.collect(codes)
+}
+
+// GetPrimitiveParts retorna as peças primitivas (sem subcomponentes), na ordem de inserção.
+func (p *PartRepositoryImpl) GetPrimitiveParts() []interfaces.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.collect(p.primitive)
+}
+
+// GetAggregateParts retorna as peças agregadas (com subcomponentes), na ordem de inserção.
+func (p *PartRepositoryImpl) GetAggregateParts() []interfaces.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.collect(p.aggregate)
+}
+
+// UpdatePart substitui a peça que possui o mesmo código da peça recebida como parâmetro.
+// A peça antiga não é alterada, de forma que cópias obtidas por GetParts continuam válidas.
+// Como o código não muda, o índice de códigos não é alterado, e o de nomes apenas caso o nome mude.
+// Retorna ErrPartNotFound caso não exista peça com o código informado.
+func (p *PartRepositoryImpl) UpdatePart(part interfaces.Part) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i, ok := p.byCode[part.GetCode()]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPartNotFound, part.GetCode())
+	}
+	old := p.parts[i]
+	if old.GetName() != part.GetName() {
+		p.unindexName(old)
+		p.indexName(part)
+	}
+	p.unindexStructure(old)
+	p.parts[i] = part
+	p.indexStructure(part)
+	return nil
+}
+
+// DeletePart remove a peça com o código informado e retorna os códigos das peças removidas.
+// Caso a peça seja subcomponente de outras peças do repositório, retorna ErrPartInUse,
+// a menos que cascade seja verdadeiro. Nesse caso, todas as peças que utilizam a peça,
+// direta ou indiretamente, também são removidas.
+// Retorna ErrPartNotFound caso não exista peça com o código informado.
+func (p *PartRepositoryImpl) DeletePart(code string, cascade bool) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.byCode[code]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPartNotFound, code)
+	}
+
+	parents := p.parentsOf(code)
+	if len(parents) > 0 && !cascade {
+		return nil, fmt.Errorf("%w: used by %s", ErrPartInUse, strings.Join(parents, ", "))
+	}
+
+	// Percorre em largura as peças que utilizam a peça removida, direta ou indiretamente
+	deleted := []string{code}
+	visited := map[string]bool{code: true}
+	for i := 0; i < len(deleted); i++ {
+		for _, parent := range p.parentsOf(deleted[i]) {
+			if !visited[parent] {
+				visited[parent] = true
+				deleted = append(deleted, parent)
+			}
+		}
+	}
+
+	// Remove as peças mantendo a ordem das demais e atualiza as posições no índice de códigos
+	parts := p.parts[:0]
+	for _, part := range p.parts {
+		if visited[part.GetCode()] {
+			p.unindex(part)
+			continue
+		}
+		p.byCode[part.GetCode()] = len(parts)
+		parts = append(parts, part)
+	}
+	// Limpa o final do slice para não manter referências às peças removidas
+	for i := len(parts); i < len(p.parts); i++ {
+		p.parts[i] = nil
+	}
+	p.parts = parts
+
+	return deleted, nil
+}
+
+// WhereUsed retorna as peças do repositório que utilizam a peça com o código informado como
+// subcomponente direto, na ordem de inserção, consultando o índice reverso dos subcomponentes.
+// Caso recursive seja verdadeiro, retorna também as peças que a utilizam indiretamente, isto é,
+// que utilizam alguma peça que a utiliza. A peça não precisa pertencer ao repositório.
+func (p *PartRepositoryImpl) WhereUsed(code string, recursive bool) []interfaces.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !recursive {
+		return p.collect(p.usedBy[code])
+	}
+
+	// Percorre em largura as peças que utilizam a peça, direta ou indiretamente
+	visited := map[string]bool{code: true}
+	codes := make(map[string]bool)
+	queue := []string{code}
+	for i := 0; i < len(queue); i++ {
+		for parent := range p.usedBy[queue[i]] {
+			if !visited[parent] {
+				visited[parent] = true
+				codes[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return p.collect(codes)
+}
+
+// index inclui a peça, armazenada na posição i da lista, nos índices do repositório.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) index(part interfaces.Part, i int) {
+	if p.byCode == nil {
+		p.byCode = make(map[string]int)
+		p.byName = make(map[string]map[string]bool)
+		p.primitive = make(map[string]bool)
+		p.aggregate = make(map[string]bool)
+		p.usedBy = make(map[string]map[string]bool)
+	}
+
+	p.byCode[part.GetCode()] = i
+	p.codes.insert(part.GetCode())
+	p.indexName(part)
+	p.indexStructure(part)
+}
+
+// unindex remove a peça dos índices do repositório.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) unindex(part interfaces.Part) {
+	delete(p.byCode, part.GetCode())
+	p.codes.remove(part.GetCode())
+	p.unindexName(part)
+	p.unindexStructure(part)
+}
+
+// indexName inclui a peça no índice de nomes.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) indexName(part interfaces.Part) {
+	code, name := part.GetCode(), part.GetName()
+	if p.byName[name] == nil {
+		p.byName[name] = make(map[string]bool)
+		p.names.insert(name)
+	}
+	p.byName[name][code] = true
+}
+
+// unindexName remove a peça do índice de nomes.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) unindexName(part interfaces.Part) {
+	code, name := part.GetCode(), part.GetName()
+	delete(p.byName[name], code)
+	if len(p.byName[name]) == 0 {
+		delete(p.byName, name)
+		p.names.remove(name)
+	}
+}
+
+// indexStructure inclui a peça nos índices de tipo e no índice reverso dos subcomponentes.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) indexStructure(part interfaces.Part) {
+	code := part.GetCode()
+	if part.IsPrimitive() {
+		p.primitive[code] = true
+	} else {
+		p.aggregate[code] = true
+	}
+
+	for _, pair := range part.GetSubcomponents() {
+		sub := pair.GetPartCode()
+		if p.usedBy[sub] == nil {
+			p.usedBy[sub] = make(map[string]bool)
+		}
+		p.usedBy[sub][code] = true
+	}
+}
+
+// unindexStructure remove a peça dos índices de tipo e do índice reverso dos subcomponentes.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) unindexStructure(part interfaces.Part) {
+	code := part.GetCode()
+	delete(p.primitive, code)
+	delete(p.aggregate, code)
+
+	for _, pair := range part.GetSubcomponents() {
+		sub := pair.GetPartCode()
+		delete(p.usedBy[sub], code)
+		if len(p.usedBy[sub]) == 0 {
+			delete(p.usedBy, sub)
+		}
+	}
+}
+
+// collect retorna as peças cujos códigos pertencem ao conjunto informado, na ordem de inserção.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) collect(codes map[string]bool) []interfaces.Part {
+	positions := make([]int, 0, len(codes))
+	for code := range codes {
+		positions = append(positions, p.byCode[code])
+	}
+	sort.Ints(positions)
+
+	parts := make([]interfaces.Part, len(positions))
+	for i, pos := range positions {
+		parts[i] = p.parts[pos]
+	}
+	return parts
+}
+
+// parentsOf retorna os códigos das peças do repositório que possuem a peça informada
+// como subcomponente direto, na ordem de inserção, consultando o índice reverso dos subcomponentes.
+// Deve ser chamada com o mutex adquirido.
+func (p *PartRepositoryImpl) parentsOf(code string) []string {
+	parts := p.collect(p.usedBy[code])
+	parents := make([]string, len(parts))
+	for i, part := range parts {
+		parents[i] = part.GetCode()
+	}
+	return parents
 }
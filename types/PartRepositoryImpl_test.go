@@ -0,0 +1,222 @@
+package types
+
+import (
+	"fmt"
+	"go-rpc/interfaces"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// newPart retorna uma peça com o código e o nome informados.
+func newPart(code string, name string) *PartImpl {
+	part := NewPartImpl(name, "")
+	part.SetCode(code)
+	return part
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	const writers, readers, perWriter, perReader = 8, 8, 100, 50
+	repo := new(PartRepositoryImpl)
+	for i := 0; i < 10; i++ {
+		if err := repo.AddPart(newPart("seed"+strconv.Itoa(i), "seed")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				code := fmt.Sprintf("w%d-%d", w, i)
+				part := newPart(code, "part"+strconv.Itoa(i%10))
+				if err := repo.AddPart(part); err != nil {
+					t.Error(err)
+					return
+				}
+				seed := newPart("seed"+strconv.Itoa(i%10), "seed")
+				seed.SetSubcomponents([]interfaces.Pair{NewPairImpl(part, 1)})
+				if err := repo.UpdatePart(seed); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perReader; i++ {
+				for _, part := range repo.GetParts() {
+					if part == nil {
+						t.Error("GetParts returned a nil part")
+						return
+					}
+				}
+				repo.GetPart("seed0")
+				repo.FindPartsByNamePrefix("part")
+				repo.ListParts("w", 50)
+				repo.GetAggregateParts()
+				repo.WhereUsed("w0-0", true)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got, want := len(repo.GetParts()), 10+writers*perWriter; got != want {
+		t.Fatalf("repository has %d parts, want %d", got, want)
+	}
+	if got, want := repo.Len(), 10+writers*perWriter; got != want {
+		t.Fatalf("Len = %d, want %d", got, want)
+	}
+}
+
+func TestGetPartsSnapshot(t *testing.T) {
+	repo := new(PartRepositoryImpl)
+	for i := 0; i < 3; i++ {
+		if err := repo.AddPart(newPart("p"+strconv.Itoa(i), "part")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := repo.GetParts()
+	want := make([]interfaces.Part, len(snapshot))
+	copy(want, snapshot)
+
+	// Escritas posteriores não alteram a cópia
+	if err := repo.AddPart(newPart("p3", "part")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdatePart(newPart("p0", "renamed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.DeletePart("p1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshot) != len(want) {
+		t.Fatalf("snapshot has %d parts, want %d", len(snapshot), len(want))
+	}
+	for i := range want {
+		if snapshot[i] != want[i] {
+			t.Errorf("snapshot[%d] = %v, want %v", i, snapshot[i], want[i])
+		}
+	}
+	if snapshot[0].GetName() != "part" {
+		t.Errorf("snapshot[0] was modified by UpdatePart: %v", snapshot[0])
+	}
+}
+
+// codesOf retorna os códigos das peças informadas, na mesma ordem.
+func codesOf(parts []interfaces.Part) []string {
+	codes := make([]string, len(parts))
+	for i, part := range parts {
+		codes[i] = part.GetCode()
+	}
+	return codes
+}
+
+func TestIndexes(t *testing.T) {
+	repo := new(PartRepositoryImpl)
+	for _, part := range []*PartImpl{newPart("b1", "bolt"), newPart("b2", "bolt-m6"), newPart("n1", "nut"), newPart("b3", "bolt")} {
+		if err := repo.AddPart(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wheel := newPart("w1", "wheel")
+	wheel.SetSubcomponents([]interfaces.Pair{NewPairImpl(repo.GetPart("b1"), 4)})
+	if err := repo.AddPart(wheel); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		got   []interfaces.Part
+		want  []string
+	}{
+		{"FindPartsByName(bolt)", repo.FindPartsByName("bolt"), []string{"b1", "b3"}},
+		{"FindPartsByName(bol)", repo.FindPartsByName("bol"), []string{}},
+		{"FindPartsByNamePrefix(bolt)", repo.FindPartsByNamePrefix("bolt"), []string{"b1", "b2", "b3"}},
+		{"FindPartsByNamePrefix()", repo.FindPartsByNamePrefix(""), []string{"b1", "b2", "n1", "b3", "w1"}},
+		{"FindPartsByNamePrefix(x)", repo.FindPartsByNamePrefix("x"), []string{}},
+		{"GetPrimitiveParts", repo.GetPrimitiveParts(), []string{"b1", "b2", "n1", "b3"}},
+		{"GetAggregateParts", repo.GetAggregateParts(), []string{"w1"}},
+	}
+	for _, tt := range tests {
+		if got := codesOf(tt.got); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// Os índices acompanham as alterações: b3 é renomeada, w1 passa a ser primitiva e n1 é removida
+	if err := repo.UpdatePart(newPart("b3", "screw")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdatePart(newPart("w1", "wheel")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.DeletePart("n1", false); err != nil {
+		t.Fatal(err)
+	}
+	tests = []struct {
+		query string
+		got   []interfaces.Part
+		want  []string
+	}{
+		{"FindPartsByName(bolt)", repo.FindPartsByName("bolt"), []string{"b1"}},
+		{"FindPartsByNamePrefix(s)", repo.FindPartsByNamePrefix("s"), []string{"b3"}},
+		{"FindPartsByNamePrefix(n)", repo.FindPartsByNamePrefix("n"), []string{}},
+		{"GetPrimitiveParts", repo.GetPrimitiveParts(), []string{"b1", "b2", "b3", "w1"}},
+		{"GetAggregateParts", repo.GetAggregateParts(), []string{}},
+	}
+	for _, tt := range tests {
+		if got := codesOf(tt.got); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s after changes = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestWhereUsed(t *testing.T) {
+	// cart utiliza axle e frame, que utilizam wheel; trailer utiliza cart
+	repo := new(PartRepositoryImpl)
+	parts := []*PartImpl{newPart("wheel", "wheel"), newPart("axle", "axle"), newPart("frame", "frame"), newPart("cart", "cart"), newPart("trailer", "trailer")}
+	parts[1].SetSubcomponents([]interfaces.Pair{NewPairRefImpl("repo", "wheel", 2)})
+	parts[2].SetSubcomponents([]interfaces.Pair{NewPairRefImpl("repo", "wheel", 3)})
+	parts[3].SetSubcomponents([]interfaces.Pair{NewPairRefImpl("repo", "axle", 2), NewPairRefImpl("repo", "frame", 1)})
+	parts[4].SetSubcomponents([]interfaces.Pair{NewPairRefImpl("repo", "cart", 1)})
+	for _, part := range parts {
+		if err := repo.AddPart(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		code      string
+		recursive bool
+		want      []string
+	}{
+		{"wheel", false, []string{"axle", "frame"}},
+		{"wheel", true, []string{"axle", "frame", "cart", "trailer"}},
+		{"cart", false, []string{"trailer"}},
+		{"trailer", true, []string{}},
+		{"missing", true, []string{}},
+	}
+	for _, tt := range tests {
+		if got := codesOf(repo.WhereUsed(tt.code, tt.recursive)); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("WhereUsed(%s, %v) = %v, want %v", tt.code, tt.recursive, got, tt.want)
+		}
+	}
+
+	// O índice reverso acompanha as alterações: axle deixa de utilizar wheel
+	if err := repo.UpdatePart(newPart("axle", "axle")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := codesOf(repo.WhereUsed("wheel", true)), []string{"frame", "cart", "trailer"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("WhereUsed(wheel, true) after update = %v, want %v", got, want)
+	}
+}
@@ -3,9 +3,10 @@ package types
 // Estrutura RemoteRefImpl representa uma referência à um servidor.
 // Implementa a interface interfaces.RemoteRef e fmt.Stringer
 type RemoteRefImpl struct {
-	Host string // host do servidor
-	Port string // porta do servidor
-	Name string // nome do servidor
+	Host     string            `json:"host"`               // host do servidor
+	Port     string            `json:"port"`               // porta do servidor
+	Name     string            `json:"name"`               // nome do servidor
+	Metadata map[string]string `json:"metadata,omitempty"` // metadados do servidor
 }
 
 // NewRemoteRefImpl retorna o ponteiro para uma estrutura PartImpl.
@@ -30,6 +31,11 @@ func (r RemoteRefImpl) GetPort() string {
 	return r.Port
 }
 
+// GetMetadata retorna o valor do atributo metadata da estrutura RemoteRefImpl
+func (r RemoteRefImpl) GetMetadata() map[string]string {
+	return r.Metadata
+}
+
 // GetAddress retorna o endereço do servidor remoto no formato host:port
 func (r RemoteRefImpl) GetAddress() string {
 	return r.Host + ":" + r.Port
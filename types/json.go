@@ -0,0 +1,238 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-rpc/interfaces"
+)
+
+// Representação JSON dos tipos concretos das interfaces interfaces.Part, interfaces.Pair e
+// interfaces.RemoteRef, utilizada pelo codec JSON-RPC do servidor de repositório.
+//
+// Ao contrário do gob, que identifica o tipo concreto de um campo de interface através dos tipos
+// registrados em encoding.RegisterConcreteTypes, o JSON não carrega informação de tipo. Por isso,
+// todo valor armazenado num campo de interface é codificado como um objeto com o campo "type",
+// que identifica o tipo concreto, e os campos do próprio tipo:
+//
+//	{"type": "part", "code": "...", "name": "...", "description": "...",
+//	 "subcomponents": [{"type": "pair", "repository": "...", "code": "...", "quantity": 2}],
+//	 "ref": {"type": "remote_ref", "host": "...", "port": "...", "name": "..."}}
+//
+// As funções UnmarshalPart, UnmarshalPair e UnmarshalRemoteRef decodificam esses objetos no tipo
+// concreto indicado pelo discriminador, e recusam objetos sem discriminador ou com um tipo desconhecido.
+
+// Discriminadores dos tipos concretos na representação JSON.
+const (
+	PartType      = "part"       // PartImpl
+	PairType      = "pair"       // PairImpl
+	RemoteRefType = "remote_ref" // RemoteRefImpl
+)
+
+// Estrutura jsonPart representa a codificação JSON de PartImpl.
+type jsonPart struct {
+	Type          string            `json:"type"`
+	Code          string            `json:"code"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Subcomponents []json.RawMessage `json:"subcomponents,omitempty"`
+	Ref           json.RawMessage   `json:"ref,omitempty"`
+}
+
+// Estrutura jsonPair representa a codificação JSON de PairImpl. O campo part é preenchido apenas
+// quando o par armazena uma cópia da peça, e os campos repository e code quando é uma referência.
+type jsonPair struct {
+	Type       string          `json:"type"`
+	Part       json.RawMessage `json:"part,omitempty"`
+	Repository string          `json:"repository,omitempty"`
+	Code       string          `json:"code,omitempty"`
+	Quantity   int             `json:"quantity"`
+}
+
+// Estrutura jsonRemoteRef representa a codificação JSON de RemoteRefImpl num campo de interface.
+// A codificação da própria RemoteRefImpl, utilizada pela API do serviço de nomes, não possui o
+// discriminador, já que a API não utiliza campos de interface.
+type jsonRemoteRef struct {
+	Type string `json:"type"`
+	RemoteRefImpl
+}
+
+// MarshalJSON codifica a estrutura PartImpl em JSON, com o discriminador PartType.
+// Implementa a interface json.Marshaler.
+func (p PartImpl) MarshalJSON() ([]byte, error) {
+	out := jsonPart{Type: PartType, Code: p.Code, Name: p.Name, Description: p.Description}
+	for _, pair := range p.Subcomponents {
+		data, err := json.Marshal(pair)
+		if err != nil {
+			return nil, err
+		}
+		out.Subcomponents = append(out.Subcomponents, data)
+	}
+	if p.Ref != nil {
+		data, err := marshalRemoteRef(p.Ref)
+		if err != nil {
+			return nil, err
+		}
+		out.Ref = data
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodifica a estrutura PartImpl a partir da sua codificação JSON, decodificando
+// os subcomponentes e a referência de acordo com os seus discriminadores. Subcomponentes nulos são
+// recusados. Implementa a interface json.Unmarshaler.
+func (p *PartImpl) UnmarshalJSON(data []byte) error {
+	var in jsonPart
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Type != PartType {
+		return fmt.Errorf("unknown part type %q", in.Type)
+	}
+
+	part := PartImpl{Code: in.Code, Name: in.Name, Description: in.Description}
+	for _, raw := range in.Subcomponents {
+		pair, err := UnmarshalPair(raw)
+		if err != nil {
+			return err
+		}
+		if pair == nil {
+			return fmt.Errorf("null subcomponent in part %q", in.Code)
+		}
+		part.Subcomponents = append(part.Subcomponents, pair)
+	}
+	if len(in.Ref) > 0 && string(in.Ref) != "null" {
+		ref, err := UnmarshalRemoteRef(in.Ref)
+		if err != nil {
+			return err
+		}
+		part.Ref = ref
+	}
+	*p = part
+	return nil
+}
+
+// MarshalJSON codifica a estrutura PairImpl em JSON, com o discriminador PairType.
+// Implementa a interface json.Marshaler.
+func (p PairImpl) MarshalJSON() ([]byte, error) {
+	out := jsonPair{Type: PairType, Repository: p.Repository, Code: p.Code, Quantity: p.Quantity}
+	if p.SubPart != nil {
+		data, err := json.Marshal(p.SubPart)
+		if err != nil {
+			return nil, err
+		}
+		out.Part = data
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodifica a estrutura PairImpl a partir da sua codificação JSON.
+// Implementa a interface json.Unmarshaler.
+func (p *PairImpl) UnmarshalJSON(data []byte) error {
+	var in jsonPair
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Type != PairType {
+		return fmt.Errorf("unknown pair type %q", in.Type)
+	}
+
+	pair := PairImpl{Repository: in.Repository, Code: in.Code, Quantity: in.Quantity}
+	if len(in.Part) > 0 && string(in.Part) != "null" {
+		part, err := UnmarshalPart(in.Part)
+		if err != nil {
+			return err
+		}
+		pair.SubPart = part
+	}
+	*p = pair
+	return nil
+}
+
+// UnmarshalPart decodifica uma peça a partir da sua codificação JSON, no tipo concreto indicado
+// pelo seu discriminador. Um valor null é decodificado como uma peça nula.
+func UnmarshalPart(data []byte) (interfaces.Part, error) {
+	kind, err := discriminator(data)
+	if err != nil || kind == "" {
+		return nil, err
+	}
+	switch kind {
+	case PartType:
+		part := new(PartImpl)
+		if err := json.Unmarshal(data, part); err != nil {
+			return nil, err
+		}
+		return part, nil
+	default:
+		return nil, fmt.Errorf("unknown part type %q", kind)
+	}
+}
+
+// UnmarshalPair decodifica um par a partir da sua codificação JSON, no tipo concreto indicado
+// pelo seu discriminador. Um valor null é decodificado como um par nulo.
+func UnmarshalPair(data []byte) (interfaces.Pair, error) {
+	kind, err := discriminator(data)
+	if err != nil || kind == "" {
+		return nil, err
+	}
+	switch kind {
+	case PairType:
+		pair := new(PairImpl)
+		if err := json.Unmarshal(data, pair); err != nil {
+			return nil, err
+		}
+		return pair, nil
+	default:
+		return nil, fmt.Errorf("unknown pair type %q", kind)
+	}
+}
+
+// UnmarshalRemoteRef decodifica uma referência a um servidor remoto a partir da sua codificação
+// JSON, no tipo concreto indicado pelo seu discriminador. Um valor null é decodificado como uma
+// referência nula.
+func UnmarshalRemoteRef(data []byte) (interfaces.RemoteRef, error) {
+	kind, err := discriminator(data)
+	if err != nil || kind == "" {
+		return nil, err
+	}
+	switch kind {
+	case RemoteRefType:
+		var in jsonRemoteRef
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, err
+		}
+		// O valor, e não o ponteiro, é armazenado, assim como no registro do gob
+		return in.RemoteRefImpl, nil
+	default:
+		return nil, fmt.Errorf("unknown remote ref type %q", kind)
+	}
+}
+
+// marshalRemoteRef codifica uma referência a um servidor remoto em JSON, com o discriminador do seu tipo concreto.
+func marshalRemoteRef(ref interfaces.RemoteRef) ([]byte, error) {
+	switch r := ref.(type) {
+	case RemoteRefImpl:
+		return json.Marshal(jsonRemoteRef{Type: RemoteRefType, RemoteRefImpl: r})
+	case *RemoteRefImpl:
+		return json.Marshal(jsonRemoteRef{Type: RemoteRefType, RemoteRefImpl: *r})
+	default:
+		return nil, fmt.Errorf("unsupported remote ref type %T", ref)
+	}
+}
+
+// discriminator retorna o discriminador do objeto JSON data, ou uma string vazia caso data seja null.
+// Retorna um erro caso data não seja um objeto ou não possua o discriminador.
+func discriminator(data []byte) (string, error) {
+	if string(data) == "null" {
+		return "", nil
+	}
+	var head struct {
+		Type *string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return "", err
+	}
+	if head.Type == nil {
+		return "", fmt.Errorf("missing type discriminator in %s", data)
+	}
+	return *head.Type, nil
+}
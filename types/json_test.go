@@ -0,0 +1,17 @@
+package types
+
+import "testing"
+
+func TestUnmarshalPartRejectsNullSubcomponent(t *testing.T) {
+	data := []byte(`{"type":"part","code":"a","name":"frame","subcomponents":[{"type":"pair","repository":"r","code":"b","quantity":1},null]}`)
+	if part, err := UnmarshalPart(data); err == nil {
+		t.Fatalf("UnmarshalPart = %v, want an error", part)
+	}
+}
+
+func TestUnmarshalPartNull(t *testing.T) {
+	part, err := UnmarshalPart([]byte("null"))
+	if err != nil || part != nil {
+		t.Fatalf("UnmarshalPart(null) = %v, %v, want nil, nil", part, err)
+	}
+}
@@ -0,0 +1,116 @@
+package types
+
+// Número máximo de níveis de uma skip list, suficiente para 4^32 elementos
+const maxSkipLevel = 32
+
+// Estrutura sortedSet representa um conjunto de strings em ordem lexicográfica, implementado
+// como uma skip list: inserções, remoções e buscas custam O(log n) em média, ao contrário de
+// uma lista ordenada, em que inserções e remoções deslocam os elementos seguintes.
+// O valor zero da estrutura é um conjunto vazio pronto para uso. A estrutura não é segura para
+// uso concorrente.
+type sortedSet struct {
+	head  skipNode // nó inicial, sem valor, com um ponteiro por nível
+	level int      // número de níveis em uso
+	seed  uint64   // estado do gerador pseudoaleatório dos níveis dos nós
+}
+
+// Estrutura skipNode representa um elemento de uma skip list.
+type skipNode struct {
+	value string      // valor do elemento
+	next  []*skipNode // próximo nó de cada nível, do nível mais baixo ao mais alto
+}
+
+// first retorna o nó do menor elemento maior ou igual a s, ou nulo caso não haja nenhum.
+// Os elementos seguintes são obtidos com skipNode.successor.
+func (set *sortedSet) first(s string) *skipNode {
+	x := &set.head
+	for i := set.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].value < s {
+			x = x.next[i]
+		}
+	}
+	if set.level == 0 {
+		return nil
+	}
+	return x.next[0]
+}
+
+// insert inclui a string s no conjunto. Não faz nada caso ela já esteja presente.
+func (set *sortedSet) insert(s string) {
+	if set.head.next == nil {
+		set.head.next = make([]*skipNode, maxSkipLevel)
+	}
+
+	var prev [maxSkipLevel]*skipNode
+	x := &set.head
+	for i := set.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].value < s {
+			x = x.next[i]
+		}
+		prev[i] = x
+	}
+	if n := x.next[0]; set.level > 0 && n != nil && n.value == s {
+		return
+	}
+
+	level := set.randomLevel()
+	for i := set.level; i < level; i++ {
+		prev[i] = &set.head
+	}
+	if level > set.level {
+		set.level = level
+	}
+	n := &skipNode{value: s, next: make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = prev[i].next[i]
+		prev[i].next[i] = n
+	}
+}
+
+// remove retira a string s do conjunto. Não faz nada caso ela não esteja presente.
+func (set *sortedSet) remove(s string) {
+	var prev [maxSkipLevel]*skipNode
+	x := &set.head
+	for i := set.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].value < s {
+			x = x.next[i]
+		}
+		prev[i] = x
+	}
+	if set.level == 0 || x.next[0] == nil || x.next[0].value != s {
+		return
+	}
+
+	n := x.next[0]
+	for i := 0; i < len(n.next); i++ {
+		prev[i].next[i] = n.next[i]
+	}
+	for set.level > 0 && set.head.next[set.level-1] == nil {
+		set.level--
+	}
+}
+
+// randomLevel sorteia o número de níveis de um novo nó: cada nível adicional tem probabilidade
+// 1/4. Utiliza um gerador xorshift próprio, já que o conjunto é alterado com o mutex do
+// repositório adquirido e não precisa de um gerador seguro para uso concorrente.
+func (set *sortedSet) randomLevel() int {
+	if set.seed == 0 {
+		set.seed = 0x9e3779b97f4a7c15
+	}
+	level := 1
+	for level < maxSkipLevel {
+		set.seed ^= set.seed << 13
+		set.seed ^= set.seed >> 7
+		set.seed ^= set.seed << 17
+		if set.seed&3 != 0 {
+			break
+		}
+		level++
+	}
+	return level
+}
+
+// successor retorna o nó do elemento seguinte do conjunto, ou nulo caso este seja o último.
+func (n *skipNode) successor() *skipNode {
+	return n.next[0]
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// elements retorna os elementos do conjunto maiores ou iguais a from, em ordem.
+func elements(set *sortedSet, from string) []string {
+	var list []string
+	for n := set.first(from); n != nil; n = n.successor() {
+		list = append(list, n.value)
+	}
+	return list
+}
+
+func TestSortedSet(t *testing.T) {
+	var set sortedSet
+	if n := set.first(""); n != nil {
+		t.Fatalf("first of empty set = %q", n.value)
+	}
+	set.remove("missing")
+
+	rng := rand.New(rand.NewSource(1))
+	want := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		s := strconv.Itoa(rng.Intn(1000))
+		if rng.Intn(3) == 0 {
+			set.remove(s)
+			delete(want, s)
+		} else {
+			set.insert(s)
+			want[s] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(want))
+	for s := range want {
+		sorted = append(sorted, s)
+	}
+	sort.Strings(sorted)
+	got := elements(&set, "")
+	if len(got) != len(sorted) {
+		t.Fatalf("set has %d elements, want %d", len(got), len(sorted))
+	}
+	for i := range sorted {
+		if got[i] != sorted[i] {
+			t.Fatalf("element %d = %q, want %q", i, got[i], sorted[i])
+		}
+	}
+
+	// A busca começa no menor elemento maior ou igual ao valor informado
+	from := sorted[len(sorted)/2]
+	if got := elements(&set, from); len(got) != len(sorted)-len(sorted)/2 || got[0] != from {
+		t.Errorf("elements from %q = %v", from, got)
+	}
+	if got := elements(&set, from+"\x00"); len(got) > 0 && got[0] == from {
+		t.Errorf("elements after %q start with it", from)
+	}
+}